@@ -22,8 +22,8 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 	"regexp"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -61,11 +61,14 @@ func init() {
 
 	awsListCmd.PersistentFlags().String("region", DefaultAWSRegion, "AWS Region")
 	awsListCmd.PersistentFlags().BoolP("short", "s", false, "Short output, one line per certificate")
+	awsListCmd.PersistentFlags().Bool(
+		"batch-gcd", false, "Check whether any two RSA keys in the account share a prime factor")
 }
 
 func runAWSList(cmd *cobra.Command, args []string) {
 	region := pflaghelpers.MustGetString(cmd.Flags(), "region", false)
 	shortOutput := pflaghelpers.MustGetBool(cmd.Flags(), "short")
+	batchGCD := pflaghelpers.MustGetBool(cmd.Flags(), "batch-gcd")
 
 	iamSvc := iam.New(session.New(&aws.Config{
 		Region: aws.String(region),
@@ -89,6 +92,8 @@ func runAWSList(cmd *cobra.Command, args []string) {
 		fatal("No certificates found.")
 	}
 
+	selected := []*iam.ServerCertificate{}
+	chains := []*core.CertificateChain{}
 	for _, awsCertificate := range certificates {
 		meta := awsCertificate.ServerCertificateMetadata
 		if len(filters) != 0 {
@@ -108,19 +113,56 @@ func runAWSList(cmd *cobra.Command, args []string) {
 		if err != nil {
 			fatal("%s", err)
 		}
+		selected = append(selected, awsCertificate)
+		chains = append(chains, chain)
+	}
 
-		err = chain.Verify("")
+	// Batch GCD needs every key up front, before any warnings are written.
+	if batchGCD {
+		certs := []*core.Certificate{}
+		for _, chain := range chains {
+			certs = append(certs, chain.Certificates()...)
+		}
+		core.FindSharedFactors(certs)
+	}
 
-		if shortOutput {
-			results := "PASS"
+	jsonResults := []awsListResult{}
+	warnings := []core.Warning{}
+	summary := newTable("NAME", "RESULT", "EXPIRES", "SUBJECT", "WARNINGS")
+
+	for index, awsCertificate := range selected {
+		meta := awsCertificate.ServerCertificateMetadata
+		chain := chains[index]
+
+		err := chain.Verify("")
+		warnings = append(warnings, chain.Warnings()...)
+
+		if jsonOutput {
+			jsonResults = append(jsonResults, awsListResult{
+				ID:           *meta.ServerCertificateId,
+				Name:         *meta.ServerCertificateName,
+				UploadDate:   meta.UploadDate,
+				Chain:        chain.Summary(),
+				Verification: core.NewVerificationSummary(err),
+			})
+		} else if tableOutput {
+			summary.add(
+				plainCell(*meta.ServerCertificateName),
+				resultCell(err),
+				expirationCell(chain.Leaf),
+				plainCell(chain.Leaf.ReadableSubject()),
+				warningsCell(chain.Warnings()),
+			)
+		} else if shortOutput {
+			result := resultCell(err)
 			description := ""
 
 			if err != nil {
-				results = "FAIL"
 				description = fmt.Sprintf("%T", err)
 			}
 
-			msg("%-40s%-6s%s", *meta.ServerCertificateName, results, description)
+			msg("%-40s%s%s", *meta.ServerCertificateName,
+				colored(result.color, fmt.Sprintf("%-6s", result.text)), description)
 		} else {
 			title(*meta.ServerCertificateName)
 
@@ -128,7 +170,7 @@ func runAWSList(cmd *cobra.Command, args []string) {
 			msg("Name:        %s", *meta.ServerCertificateName)
 			msg("Uploaded at: %s", meta.UploadDate)
 
-			chain.InfoLines(80).Write(os.Stdout)
+			writeChainInfo(chain)
 
 			msg("")
 
@@ -143,6 +185,22 @@ func runAWSList(cmd *cobra.Command, args []string) {
 			msg("")
 		}
 	}
+
+	if jsonOutput {
+		writeJSON(jsonResults)
+	} else if tableOutput {
+		summary.write()
+	}
+
+	failOnWarnings(warnings)
+}
+
+type awsListResult struct {
+	ID           string                   `json:"iam_id"`
+	Name         string                   `json:"name"`
+	UploadDate   *time.Time               `json:"uploaded_at"`
+	Chain        *core.ChainSummary       `json:"chain"`
+	Verification core.VerificationSummary `json:"verification"`
 }
 
 func iamAllServerCertificates(iamSvc *iam.IAM) ([]*iam.ServerCertificate, error) {
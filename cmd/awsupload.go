@@ -22,7 +22,6 @@ package cmd
 
 import (
 	"io/ioutil"
-	"os"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -40,6 +39,9 @@ aws:upload uploads a new certificate to AWS IAM.
 
 It receives the certificate and key and downloads and checks the
 certificate chain, uploading a file in the correct format to AWS IAM.
+Intermediates come from the offline intermediate database when it has them,
+and from the certificates' AIA URLs otherwise; the output tells which source
+supplied each one.
 
 Simple usage would entail something like this:
 
@@ -77,6 +79,8 @@ func init() {
 	awsUploadCmd.PersistentFlags().String(
 		"chain", "",
 		"Certificate intermediates file (optional, will fetch from internet if able and absent)")
+	awsUploadCmd.PersistentFlags().Bool(
+		"explain", false, "Trace how intermediates are fetched from the internet")
 }
 
 func runAWSUpload(cmd *cobra.Command, args []string) {
@@ -85,6 +89,7 @@ func runAWSUpload(cmd *cobra.Command, args []string) {
 	privateKeyDataPath := pflaghelpers.MustGetString(cmd.Flags(), "key", false)
 	chainDataPath := pflaghelpers.MustGetString(cmd.Flags(), "chain", true)
 	uploadedName := pflaghelpers.MustGetString(cmd.Flags(), "name", false)
+	explain := pflaghelpers.MustGetBool(cmd.Flags(), "explain")
 
 	cert, err := core.CertificateWithKeyFromFiles(certDataPath, privateKeyDataPath)
 	if err != nil {
@@ -102,14 +107,27 @@ func runAWSUpload(cmd *cobra.Command, args []string) {
 		if err != nil {
 			fatal("Unable to build certificate chain from given file: %s", err)
 		}
+		for _, intermediate := range chain.Intermediates {
+			if intermediate.Source == "" {
+				intermediate.Source = chainDataPath
+			}
+		}
 	} else {
-		chain, err = core.ChainFromCertificateAndInternet(cert)
+		var trace *core.Trace
+		if explain {
+			trace = &core.Trace{}
+		}
+		chain, err = core.ChainFromCertificateAndInternetWithTrace(cert, trace)
+		if explain {
+			writeLines(trace.Lines(outputWidth))
+			msg("")
+		}
 		if err != nil {
 			fatal("Unable to build certificate chain from internet: %s", err)
 		}
 	}
 
-	chain.InfoLines(80).Write(os.Stdout)
+	writeChainInfo(chain)
 
 	msg("")
 
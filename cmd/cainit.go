@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+)
+
+var caInitCmd = &cobra.Command{
+	Use:   "ca:init",
+	Short: "Creates a local CA for staging and test environments",
+	Long: `
+ca:init creates a root and an intermediate certificate, with their keys, in a
+CA directory. Leaf certificates are then issued by the intermediate with
+ca:issue.
+
+Keys are given as type[:size], such as rsa:4096, ecdsa:P-384 or ed25519.
+Name constraints (--permit-dns, --exclude-dns) are placed on the
+intermediate, so a leaked intermediate key can't be used for other domains.
+
+To have chaintool trust the local root, pass --trust-store <dir>/root.crt.
+
+Examples:
+
+  chaintool ca:init --dir ./ca --name "Staging CA"
+  chaintool ca:init --dir ./ca --root-key rsa:4096 --permit-dns staging.example.com
+`,
+	Run: runCAInit,
+}
+
+func init() {
+	RootCmd.AddCommand(caInitCmd)
+
+	caInitCmd.PersistentFlags().String("dir", "ca", "Directory to store the CA in")
+	caInitCmd.PersistentFlags().String("name", "", "Name of the CA, used in the certificate subjects")
+	caInitCmd.PersistentFlags().String("root-key", "ecdsa:P-384", "Root key type and size")
+	caInitCmd.PersistentFlags().String("intermediate-key", "ecdsa:P-256", "Intermediate key type and size")
+	caInitCmd.PersistentFlags().Int("root-days", 3650, "Validity of the root certificate, in days")
+	caInitCmd.PersistentFlags().Int("intermediate-days", 1825, "Validity of the intermediate certificate, in days")
+	caInitCmd.PersistentFlags().Int("leaf-days", 90, "Default validity of issued certificates, in days")
+	caInitCmd.PersistentFlags().StringSlice("permit-dns", nil, "DNS domain the intermediate may issue for, may be repeated")
+	caInitCmd.PersistentFlags().StringSlice("exclude-dns", nil, "DNS domain the intermediate may not issue for, may be repeated")
+	caInitCmd.PersistentFlags().String(
+		"url", "http://localhost:8888", "Base URL of ca:serve, put in issued certificates for OCSP, CRLs and AIA")
+}
+
+func runCAInit(cmd *cobra.Command, args []string) {
+	dir := pflaghelpers.MustGetString(cmd.Flags(), "dir", false)
+
+	rootKey, err := core.ParseKeySpec(pflaghelpers.MustGetString(cmd.Flags(), "root-key", false))
+	if err != nil {
+		fatal("Invalid --root-key: %s", err)
+	}
+	intermediateKey, err := core.ParseKeySpec(
+		pflaghelpers.MustGetString(cmd.Flags(), "intermediate-key", false))
+	if err != nil {
+		fatal("Invalid --intermediate-key: %s", err)
+	}
+
+	config := core.LocalCAConfig{
+		Name:                     pflaghelpers.MustGetString(cmd.Flags(), "name", true),
+		RootKey:                  rootKey,
+		IntermediateKey:          intermediateKey,
+		RootValidityDays:         mustGetInt(cmd, "root-days"),
+		IntermediateValidityDays: mustGetInt(cmd, "intermediate-days"),
+		LeafValidityDays:         mustGetInt(cmd, "leaf-days"),
+		PermittedDNSDomains:      mustGetStringSlice(cmd, "permit-dns"),
+		ExcludedDNSDomains:       mustGetStringSlice(cmd, "exclude-dns"),
+		BaseURL:                  pflaghelpers.MustGetString(cmd.Flags(), "url", true),
+	}
+
+	ca, err := core.InitLocalCA(dir, config)
+	if err != nil {
+		fatal("%s", err)
+	}
+
+	title("Root")
+	writeCertificateInfo(ca.Root, "")
+	title("Intermediate")
+	writeCertificateInfo(ca.Intermediate, "")
+	msg("")
+	msg("CA created in %s", dir)
+	msg("Use --trust-store %s to trust it in other commands", ca.RootPath())
+	if config.BaseURL != "" {
+		msg("Run ca:serve to answer OCSP and CRL requests at %s", config.BaseURL)
+	}
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+)
+
+var caIssueCmd = &cobra.Command{
+	Use:   "ca:issue",
+	Short: "Issues a certificate from a local CA",
+	Long: `
+ca:issue issues a leaf certificate from a CA created with ca:init, either for
+an existing certificate request (--csr) or for a new key generated from the
+same subject flags as the csr command.
+
+Given an output base name (--out, by default the common name), it writes:
+
+  <out>.crt            the leaf certificate
+  <out>.chain.pem      the intermediate
+  <out>.fullchain.pem  the leaf followed by the intermediate
+  <out>.key            the private key, if one was generated
+
+Examples:
+
+  chaintool ca:issue --dir ./ca --cn www.staging.example.com --san staging.example.com
+  chaintool ca:issue --dir ./ca --csr www.csr --days 30
+`,
+	Run: runCAIssue,
+}
+
+func init() {
+	RootCmd.AddCommand(caIssueCmd)
+
+	caIssueCmd.PersistentFlags().String("dir", "ca", "Directory of the CA")
+	caIssueCmd.PersistentFlags().String("csr", "", "Certificate request to issue for")
+	caIssueCmd.PersistentFlags().String("template", "", "YAML template with the subject, names and key (optional)")
+	addCertificateRequestFlags(caIssueCmd.PersistentFlags())
+	caIssueCmd.PersistentFlags().Int("days", 0, "Validity in days (default from the CA configuration)")
+	caIssueCmd.PersistentFlags().String("out", "", "Base name of the output files (default <cn>)")
+	caIssueCmd.PersistentFlags().Bool("force", false, "Overwrite existing output files")
+}
+
+type outputFile struct {
+	path string
+	data []byte
+	perm os.FileMode
+}
+
+func runCAIssue(cmd *cobra.Command, args []string) {
+	dir := pflaghelpers.MustGetString(cmd.Flags(), "dir", false)
+	csrPath := pflaghelpers.MustGetString(cmd.Flags(), "csr", true)
+	templatePath := pflaghelpers.MustGetString(cmd.Flags(), "template", true)
+	days := mustGetInt(cmd, "days")
+	baseName := pflaghelpers.MustGetString(cmd.Flags(), "out", true)
+	force := pflaghelpers.MustGetBool(cmd.Flags(), "force")
+
+	ca, err := core.LoadLocalCA(dir)
+	if err != nil {
+		fatal("%s", err)
+	}
+
+	var csr *core.CertificateRequest
+	template := &core.CertificateRequestTemplate{}
+	if csrPath != "" {
+		csr, err = core.CertificateRequestFromFile(csrPath)
+		if err != nil {
+			fatal("%s", err)
+		}
+		template.CommonName = csr.Request.Subject.CommonName
+		template.SANs = csr.Request.DNSNames
+	} else {
+		if templatePath != "" {
+			template, err = core.LoadCertificateRequestTemplate(templatePath)
+			if err != nil {
+				fatal("%s", err)
+			}
+		}
+		applyCSRFlags(cmd, template)
+
+		if template.CommonName == "" && len(template.SANs) == 0 {
+			cmd.Usage()
+
+			msg("")
+			fatal("either --csr, a common name or at least one SAN is required")
+		}
+	}
+
+	if baseName == "" {
+		if template.CommonName == "" && len(template.SANs) == 0 {
+			fatal("The request has no DNS names, --out is required")
+		}
+		baseName = outputBaseName(template)
+	}
+
+	outputPaths := []string{baseName + ".crt", baseName + ".chain.pem", baseName + ".fullchain.pem"}
+	if csr == nil {
+		outputPaths = append(outputPaths, baseName+".key")
+	}
+	if !force {
+		for _, path := range outputPaths {
+			if _, err := os.Stat(path); err == nil {
+				fatal("%s already exists, refusing to overwrite it (use --force)", path)
+			}
+		}
+	}
+
+	var leaf *core.Certificate
+	if csr != nil {
+		leaf, err = ca.IssueFromRequest(csr, days)
+	} else {
+		leaf, err = ca.IssueFromTemplate(template, days)
+	}
+	if err != nil {
+		fatal("%s", err)
+	}
+
+	chain := ca.Chain(leaf)
+	intermediates := chain.IntermediatesToPEM()
+	outputs := []outputFile{
+		{outputPaths[0], leaf.CertificateToPEM(), 0644},
+		{outputPaths[1], intermediates, 0644},
+		{outputPaths[2], bytes.Join([][]byte{leaf.CertificateToPEM(), intermediates}, nil), 0644},
+	}
+	if csr == nil {
+		encodedKey, err := leaf.PrivateKeyToPEM()
+		if err != nil {
+			fatal("Unable to encode private key: %s", err)
+		}
+		outputs = append(outputs, outputFile{outputPaths[3], encodedKey, 0600})
+	}
+
+	for _, output := range outputs {
+		writeOutputFile(output.path, output.data, output.perm, force)
+		msg("Wrote %s", output.path)
+	}
+
+	msg("")
+
+	title("Certificate Information")
+
+	writeChainInfo(chain)
+
+	msg("")
+
+	title("Certificate Verification")
+
+	core.AddTrustedRoots([]*core.Certificate{ca.Root})
+	if err := chain.Verify(""); err != nil {
+		msg("Result: FAILED.")
+		msg("")
+		msg("%s", err)
+	} else {
+		msg("Result: PASSED against %s", ca.RootPath())
+	}
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"math/big"
+	"os"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+)
+
+var caRevokeCmd = &cobra.Command{
+	Use:   "ca:revoke <serial or certificate file>",
+	Short: "Revokes a certificate issued by a local CA",
+	Long: `
+ca:revoke marks a certificate issued with ca:issue as revoked in the CA's
+database and regenerates the CRL. ca:serve picks the change up for both OCSP
+and CRL requests.
+
+The certificate can be given by its serial number, in hex with or without
+colons, or as a certificate file.
+
+Examples:
+
+  chaintool ca:revoke --dir ./ca www.staging.example.com.crt --reason keyCompromise
+  chaintool ca:revoke --dir ./ca 5f:c3:f4:f5:83:1e:63:45:a2:f0:bf:ce:ca:31:36:a8
+`,
+	Run: runCARevoke,
+}
+
+func init() {
+	RootCmd.AddCommand(caRevokeCmd)
+
+	caRevokeCmd.PersistentFlags().String("dir", "ca", "Directory of the CA")
+	caRevokeCmd.PersistentFlags().String(
+		"reason", "unspecified", "Revocation reason, e.g. keyCompromise, superseded, cessationOfOperation")
+}
+
+func runCARevoke(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	dir := pflaghelpers.MustGetString(cmd.Flags(), "dir", false)
+	reason, err := core.ParseRevocationReason(pflaghelpers.MustGetString(cmd.Flags(), "reason", false))
+	if err != nil {
+		fatal("%s", err)
+	}
+
+	ca, err := core.LoadLocalCA(dir)
+	if err != nil {
+		fatal("%s", err)
+	}
+
+	var serial *big.Int
+	if _, statErr := os.Stat(args[0]); statErr == nil {
+		cert := &core.Certificate{}
+		if err := cert.LoadCertificateFromFile(args[0]); err != nil {
+			fatal("%s", err)
+		}
+		serial = cert.Certificate.SerialNumber
+	} else {
+		serial, err = core.ParseSerialNumber(args[0])
+		if err != nil {
+			fatal("%s is neither a certificate file nor a serial number", args[0])
+		}
+	}
+
+	record, err := ca.Revoke(serial, reason)
+	if err != nil {
+		fatal("%s", err)
+	}
+
+	msg("Revoked %s (%s), reason: %s", record.Serial, record.Subject, core.RevocationReasonName(reason))
+	msg("CRL updated in %s", ca.CRLPath())
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ocsp"
+)
+
+var caServeCmd = &cobra.Command{
+	Use:   "ca:serve",
+	Short: "Serves OCSP, CRLs and CA certificates for a local CA",
+	Long: `
+ca:serve runs the HTTP endpoints that certificates issued by a local CA point
+to:
+
+  /ocsp              OCSP responder (POST, or GET with a base64 request)
+  /intermediate.crl  CRL signed by the intermediate
+  /intermediate.crt  intermediate certificate (AIA)
+  /root.crt          root certificate
+
+By default it listens on the host and port of the CA's base URL, set with
+ca:init --url. Revocations made with ca:revoke are picked up without a
+restart.
+
+Example:
+
+  chaintool ca:serve --dir ./ca
+`,
+	Run: runCAServe,
+}
+
+func init() {
+	RootCmd.AddCommand(caServeCmd)
+
+	caServeCmd.PersistentFlags().String("dir", "ca", "Directory of the CA")
+	caServeCmd.PersistentFlags().String("listen", "", "Address to listen on (default from the CA's base URL)")
+}
+
+func runCAServe(cmd *cobra.Command, args []string) {
+	dir := pflaghelpers.MustGetString(cmd.Flags(), "dir", false)
+	listen := pflaghelpers.MustGetString(cmd.Flags(), "listen", true)
+
+	ca, err := core.LoadLocalCA(dir)
+	if err != nil {
+		fatal("%s", err)
+	}
+
+	if listen == "" {
+		listen = ":8888"
+		if baseURL, err := url.Parse(ca.Config.BaseURL); err == nil && baseURL.Host != "" {
+			listen = baseURL.Host
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ocsp", func(w http.ResponseWriter, r *http.Request) {
+		serveOCSP(ca, w, r)
+	})
+	mux.HandleFunc("/ocsp/", func(w http.ResponseWriter, r *http.Request) {
+		serveOCSP(ca, w, r)
+	})
+	mux.HandleFunc("/intermediate.crl", func(w http.ResponseWriter, r *http.Request) {
+		crl, err := ca.CurrentCRL()
+		if err != nil {
+			msg("CRL: %s", err)
+			http.Error(w, "Unable to generate CRL", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.Write(crl)
+	})
+	mux.HandleFunc("/intermediate.crt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pkix-cert")
+		w.Write(ca.Intermediate.Certificate.Raw)
+	})
+	mux.HandleFunc("/root.crt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pkix-cert")
+		w.Write(ca.Root.Certificate.Raw)
+	})
+
+	msg("Serving CA %s on %s", dir, listen)
+	if err := http.ListenAndServe(listen, logRequests(mux)); err != nil {
+		fatal("%s", err)
+	}
+}
+
+func serveOCSP(ca *core.LocalCA, w http.ResponseWriter, r *http.Request) {
+	var request []byte
+	var err error
+	switch r.Method {
+	case http.MethodPost:
+		request, err = ioutil.ReadAll(r.Body)
+	case http.MethodGet:
+		encoded, _ := url.PathUnescape(strings.TrimPrefix(r.URL.Path, "/ocsp/"))
+		request, err = base64.StdEncoding.DecodeString(encoded)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := ocsp.MalformedRequestErrorResponse
+	if err == nil {
+		response, err = ca.OCSPResponse(request)
+		if err != nil {
+			msg("OCSP: %s", err)
+			response = ocsp.MalformedRequestErrorResponse
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(response)
+}
+
+func logRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg("%s %s %s", r.RemoteAddr, r.Method, r.URL.Path)
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+)
+
+var coverageCmd = &cobra.Command{
+	Use:   "coverage [file...]",
+	Short: "Shows which certificates cover a set of hostnames",
+	Long: `
+coverage takes the hostnames you need to serve and a set of certificates,
+and shows which certificates cover each name, using the same wildcard rules
+browsers use: "*.example.com" covers "www.example.com" but neither
+"example.com" nor "a.b.example.com".
+
+It then lists names no valid certificate covers, names covered by more than
+one certificate, and SANs (or whole certificates) that don't cover any of the
+required names anymore.
+
+Certificates are read from the given files, and also from AWS IAM with
+--aws and from Heroku SSL endpoints with --heroku. CA certificates in the
+files are ignored.
+
+Examples:
+
+  chaintool coverage --name www.example.com --name api.example.com certs/*.crt
+  chaintool coverage --names-file hostnames.txt --aws --heroku
+`,
+	Run: runCoverage,
+}
+
+func init() {
+	RootCmd.AddCommand(coverageCmd)
+
+	coverageCmd.PersistentFlags().StringSlice(
+		"name", []string{}, "Hostname that must be covered (can be repeated)")
+	coverageCmd.PersistentFlags().String(
+		"names-file", "", "File with one required hostname per line")
+	coverageCmd.PersistentFlags().Bool(
+		"aws", false, "Include the server certificates in AWS IAM")
+	coverageCmd.PersistentFlags().String("region", DefaultAWSRegion, "AWS Region")
+	coverageCmd.PersistentFlags().Bool(
+		"heroku", false, "Include the certificates of Heroku SSL endpoints")
+}
+
+func runCoverage(cmd *cobra.Command, args []string) {
+	names := mustGetStringSlice(cmd, "name")
+	namesFile := pflaghelpers.MustGetString(cmd.Flags(), "names-file", true)
+	useAWS := pflaghelpers.MustGetBool(cmd.Flags(), "aws")
+	region := pflaghelpers.MustGetString(cmd.Flags(), "region", false)
+	useHeroku := pflaghelpers.MustGetBool(cmd.Flags(), "heroku")
+
+	if namesFile != "" {
+		names = append(names, readNamesFile(namesFile)...)
+	}
+	if len(names) <= 0 {
+		cmd.Usage()
+
+		msg("")
+		fatal("at least one hostname is required, use --name or --names-file")
+	}
+
+	certs := []*core.Certificate{}
+	for _, path := range args {
+		certs = append(certs, leafCertificatesFromFile(path)...)
+	}
+	if useAWS {
+		certs = append(certs, leafCertificatesFromIAM(region)...)
+	}
+	if useHeroku {
+		certs = append(certs, leafCertificatesFromHeroku()...)
+	}
+	if len(certs) <= 0 {
+		fatal("No certificates found, give files or use --aws or --heroku.")
+	}
+
+	report := core.AnalyzeCoverage(names, certs)
+
+	if jsonOutput {
+		writeCoverageJSON(report)
+		return
+	}
+
+	title("Coverage")
+
+	for _, coverage := range report.Names {
+		if len(coverage.CoveredBy) == 0 {
+			msg("%s: NOT COVERED", coverage.Name)
+		} else {
+			msg("%s:", coverage.Name)
+		}
+		for _, cert := range coverage.CoveredBy {
+			msg("  - %s", describeCoverageCertificate(cert))
+		}
+		for _, cert := range coverage.ExpiredBy {
+			msg("  - %s (expired)", describeCoverageCertificate(cert))
+		}
+	}
+
+	msg("")
+
+	title("Problems")
+
+	problems := 0
+
+	for _, coverage := range report.Uncovered() {
+		problems++
+		if len(coverage.ExpiredBy) > 0 {
+			msg("- %s is only covered by expired certificates", coverage.Name)
+		} else {
+			msg("- %s isn't covered by any certificate", coverage.Name)
+		}
+	}
+
+	for _, coverage := range report.CoveredSeveralTimes() {
+		problems++
+		msg("- %s is covered by %d certificates", coverage.Name, len(coverage.CoveredBy))
+	}
+
+	for _, cert := range report.Certificates {
+		unneeded := report.UnneededSANs(cert)
+		if len(unneeded) <= 0 {
+			continue
+		}
+		problems++
+		if len(unneeded) == len(cert.SANs()) {
+			msg("- %s doesn't cover any required name", describeCoverageCertificate(cert))
+		} else {
+			msg("- %s has SANs no longer needed: %s",
+				describeCoverageCertificate(cert), strings.Join(unneeded, ", "))
+		}
+	}
+
+	if problems == 0 {
+		msg("None. Yay!")
+	}
+}
+
+// readNamesFile reads one hostname per line, skipping blank lines and
+// comments starting with "#".
+func readNamesFile(path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		fatal("Unable to read names file: %s", err)
+	}
+	defer file.Close()
+
+	names := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	if err := scanner.Err(); err != nil {
+		fatal("Unable to read names file: %s", err)
+	}
+	return names
+}
+
+func describeCoverageCertificate(cert *core.Certificate) string {
+	if cert.Source == "" {
+		return cert.ReadableSubject()
+	}
+	return cert.ReadableSubject() + " [" + cert.Source + "]"
+}
+
+type coverageResult struct {
+	Names        []coverageNameResult        `json:"names"`
+	Certificates []coverageCertificateResult `json:"certificates"`
+}
+
+type coverageNameResult struct {
+	Name      string   `json:"name"`
+	CoveredBy []string `json:"covered_by"`
+	ExpiredBy []string `json:"expired_by"`
+}
+
+type coverageCertificateResult struct {
+	Certificate  *core.CertificateSummary `json:"certificate"`
+	Source       string                   `json:"source,omitempty"`
+	UnneededSANs []string                 `json:"unneeded_sans"`
+	Needed       bool                     `json:"needed"`
+}
+
+func writeCoverageJSON(report *core.CoverageReport) {
+	result := coverageResult{
+		Names:        []coverageNameResult{},
+		Certificates: []coverageCertificateResult{},
+	}
+	for _, coverage := range report.Names {
+		nameResult := coverageNameResult{
+			Name:      coverage.Name,
+			CoveredBy: []string{},
+			ExpiredBy: []string{},
+		}
+		for _, cert := range coverage.CoveredBy {
+			nameResult.CoveredBy = append(nameResult.CoveredBy, cert.ID())
+		}
+		for _, cert := range coverage.ExpiredBy {
+			nameResult.ExpiredBy = append(nameResult.ExpiredBy, cert.ID())
+		}
+		result.Names = append(result.Names, nameResult)
+	}
+	for _, cert := range report.Certificates {
+		unneeded := report.UnneededSANs(cert)
+		result.Certificates = append(result.Certificates, coverageCertificateResult{
+			Certificate:  cert.Summary(),
+			Source:       cert.Source,
+			UnneededSANs: unneeded,
+			Needed:       len(unneeded) < len(cert.SANs()),
+		})
+	}
+	writeJSON(result)
+}
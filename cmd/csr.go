@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"crypto"
+	"os"
+	"strings"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var csrCmd = &cobra.Command{
+	Use:   "csr",
+	Short: "Generates a private key and a certificate signing request",
+	Long: `
+csr generates a new private key (or reuses an existing one) and a
+certificate signing request ready to be sent to a CA.
+
+The subject and names can be given as flags or in a YAML template, with
+flags taking precedence:
+
+  common_name: www.example.com
+  organization: [Example Inc.]
+  country: [US]
+  sans: [www.example.com, example.com]
+  key:
+    type: ecdsa
+    curve: P-256
+
+The common name is added to the SANs if it isn't listed there already. The
+key is written with 0600 permissions and existing files are never
+overwritten unless --force is given.
+
+Examples:
+
+  chaintool csr --cn www.example.com --san example.com --key-type rsa
+  chaintool csr --template www.yaml --out-key www.key --out-csr www.csr
+  chaintool csr --cn www.example.com --key existing.key
+`,
+	Run: runCSR,
+}
+
+func init() {
+	RootCmd.AddCommand(csrCmd)
+
+	csrCmd.PersistentFlags().String("template", "", "YAML template with the subject, names and key (optional)")
+	addCertificateRequestFlags(csrCmd.PersistentFlags())
+	csrCmd.PersistentFlags().String("key", "", "Existing private key to use instead of generating one")
+
+	csrCmd.PersistentFlags().String("out-key", "", "Where to write the generated key (default <cn>.key)")
+	csrCmd.PersistentFlags().String("out-csr", "", "Where to write the request (default <cn>.csr)")
+	csrCmd.PersistentFlags().Bool("force", false, "Overwrite existing output files")
+}
+
+func runCSR(cmd *cobra.Command, args []string) {
+	templatePath := pflaghelpers.MustGetString(cmd.Flags(), "template", true)
+	existingKeyPath := pflaghelpers.MustGetString(cmd.Flags(), "key", true)
+	outKeyPath := pflaghelpers.MustGetString(cmd.Flags(), "out-key", true)
+	outCSRPath := pflaghelpers.MustGetString(cmd.Flags(), "out-csr", true)
+	force := pflaghelpers.MustGetBool(cmd.Flags(), "force")
+
+	template := &core.CertificateRequestTemplate{}
+	if templatePath != "" {
+		var err error
+		template, err = core.LoadCertificateRequestTemplate(templatePath)
+		if err != nil {
+			fatal("%s", err)
+		}
+	}
+	applyCSRFlags(cmd, template)
+
+	if template.CommonName == "" && len(template.SANs) == 0 {
+		cmd.Usage()
+
+		msg("")
+		fatal("a common name or at least one SAN is required")
+	}
+
+	baseName := outputBaseName(template)
+	if outKeyPath == "" {
+		outKeyPath = baseName + ".key"
+	}
+	if outCSRPath == "" {
+		outCSRPath = baseName + ".csr"
+	}
+
+	if !force {
+		outputs := []string{outCSRPath}
+		if existingKeyPath == "" {
+			outputs = append(outputs, outKeyPath)
+		}
+		for _, path := range outputs {
+			if _, err := os.Stat(path); err == nil {
+				fatal("%s already exists, refusing to overwrite it (use --force)", path)
+			}
+		}
+	}
+
+	var privateKey crypto.PrivateKey
+	var err error
+	if existingKeyPath != "" {
+		privateKey, err = core.LoadPrivateKeyFromFile(existingKeyPath)
+		if err != nil {
+			fatal("Unable to load private key: %s", err)
+		}
+	} else {
+		if template.Key.Type == "" {
+			template.Key.Type = core.KeyTypeRSA
+		}
+		privateKey, err = core.GeneratePrivateKey(template.Key)
+		if err != nil {
+			fatal("Unable to generate private key: %s", err)
+		}
+	}
+
+	csr, err := core.CreateCertificateRequest(template, privateKey)
+	if err != nil {
+		fatal("%s", err)
+	}
+
+	if existingKeyPath == "" {
+		encodedKey, err := core.EncodePrivateKeyPEM(privateKey)
+		if err != nil {
+			fatal("Unable to encode private key: %s", err)
+		}
+		writeOutputFile(outKeyPath, encodedKey, 0600, force)
+		msg("Private key (%s) written to %s", core.DescribePrivateKey(privateKey), outKeyPath)
+	}
+	writeOutputFile(outCSRPath, csr.ToPEM(), 0644, force)
+	msg("Certificate request written to %s", outCSRPath)
+
+	msg("")
+	writeLines(core.WarningLines(csr.Warnings(), outputWidth))
+
+	failOnWarnings(csr.Warnings())
+}
+
+// outputBaseName derives a file name from the request's first name.
+func outputBaseName(template *core.CertificateRequestTemplate) string {
+	baseName := template.CommonName
+	if baseName == "" {
+		baseName = template.SANs[0]
+	}
+	return strings.Replace(strings.Replace(baseName, "*", "wildcard", -1), "/", "_", -1)
+}
+
+// addCertificateRequestFlags registers the subject and key generation flags
+// read by applyCSRFlags.
+func addCertificateRequestFlags(flags *pflag.FlagSet) {
+	flags.String("cn", "", "Subject common name")
+	flags.StringSlice("san", nil, "Subject alternative name, may be repeated")
+	flags.StringSlice("org", nil, "Subject organization")
+	flags.StringSlice("ou", nil, "Subject organizational unit")
+	flags.StringSlice("country", nil, "Subject country")
+	flags.StringSlice("province", nil, "Subject state or province")
+	flags.StringSlice("locality", nil, "Subject locality")
+
+	flags.String("key-type", "", "Type of key to generate: rsa, ecdsa or ed25519 (default rsa)")
+	flags.Int("rsa-bits", 0, "RSA key size (default 2048)")
+	flags.String("curve", "", "ECDSA curve: P-256, P-384 or P-521 (default P-256)")
+}
+
+func applyCSRFlags(cmd *cobra.Command, template *core.CertificateRequestTemplate) {
+	if cn := pflaghelpers.MustGetString(cmd.Flags(), "cn", true); cn != "" {
+		template.CommonName = cn
+	}
+
+	stringSlices := map[string]*[]string{
+		"san":      &template.SANs,
+		"org":      &template.Organization,
+		"ou":       &template.OrganizationalUnit,
+		"country":  &template.Country,
+		"province": &template.Province,
+		"locality": &template.Locality,
+	}
+	for name, target := range stringSlices {
+		if cmd.Flags().Changed(name) {
+			*target = mustGetStringSlice(cmd, name)
+		}
+	}
+
+	if keyType := pflaghelpers.MustGetString(cmd.Flags(), "key-type", true); keyType != "" {
+		template.Key.Type = keyType
+	}
+	if bits := mustGetInt(cmd, "rsa-bits"); bits != 0 {
+		template.Key.Bits = bits
+	}
+	if curve := pflaghelpers.MustGetString(cmd.Flags(), "curve", true); curve != "" {
+		template.Key.Curve = curve
+	}
+}
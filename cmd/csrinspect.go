@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+)
+
+var csrInspectCmd = &cobra.Command{
+	Use:   "csr:inspect <request file>",
+	Short: "Shows a certificate request and checks it against keys and certificates",
+	Long: `
+csr:inspect shows the contents of a certificate signing request and checks
+its signature.
+
+Optionally, it checks that a private key matches the request, and that a
+certificate issued by a CA corresponds to it: same public key, every
+requested name covered and the same subject fields. It exits with an error
+status when either check fails.
+
+Example:
+
+  chaintool csr:inspect www.example.com.csr \
+    --key www.example.com.key \
+    --cert www.example.com.crt
+`,
+	Run: runCSRInspect,
+}
+
+func init() {
+	RootCmd.AddCommand(csrInspectCmd)
+
+	csrInspectCmd.PersistentFlags().String("key", "", "Private key to check against the request (optional)")
+	csrInspectCmd.PersistentFlags().String("cert", "", "Issued certificate to check against the request (optional)")
+}
+
+func runCSRInspect(cmd *cobra.Command, args []string) {
+	keyPath := pflaghelpers.MustGetString(cmd.Flags(), "key", true)
+	certPath := pflaghelpers.MustGetString(cmd.Flags(), "cert", true)
+
+	if len(args) != 1 {
+		cmd.Usage()
+
+		msg("")
+		fatal("certificate request file is required")
+	}
+
+	request, err := core.CertificateRequestFromFile(args[0])
+	if err != nil {
+		fatal("Unable to load certificate request: %s", err)
+	}
+
+	title("Certificate Request")
+
+	writeLines(request.InfoLines(outputWidth))
+	warnings := request.Warnings()
+	failed := false
+
+	if keyPath != "" {
+		msg("")
+		title("Private Key")
+
+		privateKey, err := core.LoadPrivateKeyFromFile(keyPath)
+		if err != nil {
+			fatal("Unable to load private key: %s", err)
+		}
+		if err := request.EnsureKeyMatches(privateKey); err != nil {
+			failed = true
+			msg("Result: FAILED.")
+			msg("")
+			msg("%s", err)
+		} else {
+			msg("Result: PASSED! The private key matches the request.")
+		}
+	}
+
+	if certPath != "" {
+		msg("")
+		title("Issued Certificate")
+
+		cert := &core.Certificate{}
+		if err := cert.LoadCertificateFromFile(certPath); err != nil {
+			fatal("Unable to load certificate: %s", err)
+		}
+
+		msg("Certificate: %s", cert.ReadableSubject())
+		differences := request.CompareWithCertificate(cert)
+		switch {
+		case core.CountWarnings(differences, core.SeverityWarning) > 0:
+			failed = true
+			msg("Result: FAILED.")
+		case len(differences) > 0:
+			msg("Result: PASSED, with the notes below.")
+		default:
+			msg("Result: PASSED! The certificate matches the request.")
+		}
+		writeLines(core.WarningLines(differences, outputWidth))
+		warnings = append(warnings, differences...)
+	}
+
+	failOnWarnings(warnings)
+	if failed {
+		os.Exit(1)
+	}
+}
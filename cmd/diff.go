@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Short: "Shows what changes when replacing a certificate or chain",
+	Long: `
+diff compares two certificates or chains, typically the current one and its
+renewal, and lists what changes: names, validity, key type and size, key
+reuse, signature algorithm, validation level and policies, issuer and chain
+path. Each change is flagged by risk, e.g. "New certificate drops
+www.example.com".
+
+Each side is a certificate or chain file, or a host[:port] to fetch the
+chain currently served from.
+
+Examples:
+
+  chaintool diff www.example.com new-fullchain.pem
+  chaintool diff old.crt new.crt
+`,
+	Run: runDiff,
+}
+
+func init() {
+	RootCmd.AddCommand(diffCmd)
+}
+
+type diffResult struct {
+	Old         *core.ChainSummary `json:"old"`
+	New         *core.ChainSummary `json:"new"`
+	Differences []core.Difference  `json:"differences"`
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	oldChain := loadChain(args[0])
+	newChain := loadChain(args[1])
+	differences := core.DiffChains(oldChain, newChain)
+
+	if jsonOutput {
+		writeJSON(diffResult{
+			Old:         oldChain.Summary(),
+			New:         newChain.Summary(),
+			Differences: differences,
+		})
+		return
+	}
+
+	msg("Old: %s (%s)", args[0], oldChain.Leaf.ReadableSubject())
+	msg("New: %s (%s)", args[1], newChain.Leaf.ReadableSubject())
+	msg("")
+
+	title("Differences")
+
+	writeLines(core.DifferenceLines(differences, outputWidth))
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <file|host[:port]>",
+	Short: "Traces how a chain is built and verified, step by step",
+	Long: `
+explain walks up the chain from the leaf and tells, for each certificate,
+which issuers were considered: the served intermediates, the trusted roots
+and, when none of those fit, the certificates its AIA URLs point to. For each
+candidate it shows whether the issuer name and key ID match and whether the
+signature verifies, and why the candidate was rejected. AIA fetches show the
+HTTP status and content type. It ends with the verification result, checking
+the hostname for servers.
+
+With --json, the steps are written as structured output instead.
+
+Examples:
+
+  chaintool explain www.example.com
+  chaintool explain --no-aia fullchain.pem
+`,
+	Run: runExplain,
+}
+
+func init() {
+	RootCmd.AddCommand(explainCmd)
+
+	explainCmd.PersistentFlags().Bool("no-aia", false, "Don't fetch issuers from AIA URLs")
+}
+
+type explainResult struct {
+	Source string            `json:"source"`
+	Steps  []*core.TraceStep `json:"steps"`
+}
+
+func runExplain(cmd *cobra.Command, args []string) {
+	noAIA := pflaghelpers.MustGetBool(cmd.Flags(), "no-aia")
+
+	if len(args) != 1 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	target := auditTarget(args[0])
+	trace := target.Chain.Explain(target.Hostname, !noAIA)
+
+	if jsonOutput {
+		writeJSON(explainResult{Source: target.Source, Steps: trace.Steps})
+		return
+	}
+
+	writeLines(trace.Lines(outputWidth))
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph <file|host[:port]>",
+	Short: "Exports the certificate graph as Graphviz DOT or Mermaid",
+	Long: `
+graph draws every certificate that could take part in a chain: the leaf, the
+intermediates served with it, the issuers its AIA extensions point to and the
+candidate roots, with an arrow from each certificate to each of its issuers.
+Cross-signed certificates show up as a certificate with several issuers.
+
+Nodes show the subject, short key ID, expiry and trust status; expired
+certificates get a red border and trusted roots a green one. Arrows on a
+path to a trusted root are bold. Broken links (a bad signature, an expired
+issuer or an issuer that can't be found) are red and dashed.
+
+Examples:
+
+  chaintool graph www.example.com | dot -Tsvg > chain.svg
+  chaintool graph --format mermaid --output chain.mmd fullchain.pem
+`,
+	Run: runGraph,
+}
+
+func init() {
+	RootCmd.AddCommand(graphCmd)
+
+	graphCmd.PersistentFlags().String("format", "dot", "Output format, dot or mermaid")
+	graphCmd.PersistentFlags().String("output", "", "Write the graph to this file instead of stdout")
+	graphCmd.PersistentFlags().Bool("no-aia", false, "Don't fetch issuers from AIA URLs")
+	graphCmd.PersistentFlags().Bool("force", false, "Overwrite an existing output file")
+}
+
+func runGraph(cmd *cobra.Command, args []string) {
+	format := pflaghelpers.MustGetString(cmd.Flags(), "format", false)
+	outputPath := pflaghelpers.MustGetString(cmd.Flags(), "output", true)
+	noAIA := pflaghelpers.MustGetBool(cmd.Flags(), "no-aia")
+	force := pflaghelpers.MustGetBool(cmd.Flags(), "force")
+
+	if len(args) != 1 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	if format != "dot" && format != "mermaid" {
+		fatal("Invalid --format '%s', expected dot or mermaid", format)
+	}
+
+	graph := core.NewCertificateGraph(loadChain(args[0]), !noAIA)
+	for _, err := range graph.Errors {
+		warning("%s", err)
+	}
+
+	output := graph.DOT()
+	if format == "mermaid" {
+		output = graph.Mermaid()
+	}
+
+	if outputPath == "" {
+		fmt.Print(output)
+		return
+	}
+	writeOutputFile(outputPath, []byte(output), 0644, force)
+	msg("Graph written to %s", outputPath)
+}
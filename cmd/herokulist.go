@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 	"os/user"
 	"path/filepath"
 	"strings"
@@ -88,7 +87,7 @@ func runHerokuList(cmd *cobra.Command, args []string) {
 					if err != nil {
 						fatal("Failed to parse cert data: %s", err)
 					}
-					chain.InfoLines(80).Write(os.Stdout)
+					writeChainInfo(chain)
 				}
 			}
 		}
@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"crypto"
+	"fmt"
+	"strings"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <file> [file...]",
+	Short: "Inspects local certificate, chain, key and bundle files",
+	Long: `
+inspect reads local files and shows the same information and verification
+results the verify command shows, without touching the network.
+
+Files may contain any mix of certificates, chains, private keys and
+certificate requests, in PEM, DER, PKCS#7 or PKCS#12 format. The format of
+each file is detected automatically. Certificates are assembled into a
+chain, keeping the order they're given in so misordered chains are reported,
+and private keys are matched to the certificates they belong to. Missing
+intermediates are added from the offline intermediate database (see
+intermediates:update), unless --no-complete is given.
+
+Example:
+
+  chaintool inspect --hostname www.example.com my_cert.crt my_chain.pem my_cert.key
+`,
+	Run: runInspect,
+}
+
+func init() {
+	RootCmd.AddCommand(inspectCmd)
+
+	inspectCmd.PersistentFlags().String(
+		"hostname", "", "Hostname to verify the certificate against (optional)")
+	inspectCmd.PersistentFlags().Bool(
+		"check-revocation", false, "Check the chain against OCSP responders and CRLs")
+	inspectCmd.PersistentFlags().Bool(
+		"batch-gcd", false, "Check whether any two of the given RSA keys share a prime factor")
+	inspectCmd.PersistentFlags().Bool(
+		"no-complete", false, "Don't complete the chain from the intermediate database")
+}
+
+func runInspect(cmd *cobra.Command, args []string) {
+	hostname := pflaghelpers.MustGetString(cmd.Flags(), "hostname", true)
+	checkRevocation := pflaghelpers.MustGetBool(cmd.Flags(), "check-revocation")
+	batchGCD := pflaghelpers.MustGetBool(cmd.Flags(), "batch-gcd")
+	noComplete := pflaghelpers.MustGetBool(cmd.Flags(), "no-complete")
+
+	if len(args) < 1 {
+		cmd.Usage()
+
+		msg("")
+		fatal("at least one file is required")
+	}
+
+	certs := []*core.Certificate{}
+	keys := []crypto.PrivateKey{}
+	csrs := []*core.CertificateRequest{}
+
+	fileSummaries := []string{}
+	for _, path := range args {
+		contents, err := core.LoadFileContents(path)
+		if err != nil {
+			fatal("%s", err)
+		}
+		fileSummaries = append(fileSummaries, contents.Summary())
+
+		certs = append(certs, contents.Certificates...)
+		keys = append(keys, contents.PrivateKeys...)
+		csrs = append(csrs, contents.CertificateRequests...)
+	}
+
+	if len(certs) <= 0 {
+		fatal("No certificates found in the given files.")
+	}
+
+	// Keys are matched first, so the certificate they belong to is picked
+	// as the leaf.
+	unmatchedKeys := core.MatchPrivateKeys(certs, keys)
+
+	chain, unrelated, err := core.ChainFromCertificates(certs)
+	if err != nil {
+		fatal("%s", err)
+	}
+	completed := 0
+	if !noComplete {
+		completed = core.CompleteChain(chain)
+	}
+
+	if batchGCD {
+		core.FindSharedFactors(certs)
+	}
+
+	if jsonOutput {
+		writeInspectJSON(fileSummaries, chain, unrelated, unmatchedKeys, hostname, checkRevocation)
+		failOnWarnings(inspectWarnings(chain, unrelated))
+		return
+	}
+
+	msg("Files:")
+	for _, summary := range fileSummaries {
+		msg("  - %s", summary)
+	}
+	if completed > 0 {
+		msg("")
+		msg("Warning: the chain in these files is incomplete. %d intermediate(s) were added from "+
+			"the intermediate database; include them when deploying.", completed)
+	}
+
+	msg("")
+
+	title("Certificate Information")
+
+	if tableOutput {
+		writeChainTable(chain, unrelated)
+	} else {
+		writeChainInfo(chain)
+
+		for index, cert := range unrelated {
+			msg("Unrelated Certificate #%d:", index+1)
+			writeCertificateInfo(cert, "  ")
+		}
+	}
+
+	if len(keys) > 0 {
+		msg("Private Keys:")
+		for _, cert := range append(chain.Certificates(), unrelated...) {
+			if cert.PrivateKey != nil {
+				msg("  - %s key matching %s", core.DescribePrivateKey(cert.PrivateKey),
+					positionName(chain, unrelated, cert))
+			}
+		}
+		for _, key := range unmatchedKeys {
+			msg("  - %s key not matching any certificate", core.DescribePrivateKey(key))
+		}
+	}
+
+	if len(csrs) > 0 {
+		msg("Certificate Requests:")
+		for _, csr := range csrs {
+			matches := []string{}
+			for _, cert := range append(chain.Certificates(), unrelated...) {
+				if cert.MatchesPublicKey(csr.Request.PublicKey) {
+					matches = append(matches, positionName(chain, unrelated, cert))
+				}
+			}
+			if len(matches) <= 0 {
+				matches = append(matches, "no certificate")
+			}
+			msg("  - %s (%s), key matches %s",
+				csr.Request.Subject.CommonName,
+				strings.Join(csr.SANs(), ", "),
+				strings.Join(matches, ", "))
+		}
+	}
+
+	msg("")
+
+	title("Certificate Verification")
+
+	err = chain.Verify(hostname)
+	if err != nil {
+		msg("Result: FAILED.")
+		msg("")
+		msg("%s", err)
+	} else {
+		msg("Result: PASSED!")
+
+		if checkRevocation {
+			msg("")
+			writeRevocationResults(chain)
+		}
+	}
+
+	failOnWarnings(inspectWarnings(chain, unrelated))
+}
+
+func inspectWarnings(chain *core.CertificateChain, unrelated []*core.Certificate) []core.Warning {
+	warnings := chain.Warnings()
+	for _, cert := range unrelated {
+		warnings = append(warnings, cert.Warnings()...)
+	}
+	return warnings
+}
+
+type inspectResult struct {
+	Files        []string                   `json:"files"`
+	Chain        *core.ChainSummary         `json:"chain"`
+	Unrelated    []*core.CertificateSummary `json:"unrelated"`
+	PrivateKeys  []inspectKeyResult         `json:"private_keys"`
+	Verification core.VerificationSummary   `json:"verification"`
+	Revocation   []*core.RevocationSummary  `json:"revocation,omitempty"`
+}
+
+type inspectKeyResult struct {
+	Description   string `json:"description"`
+	CertificateID string `json:"certificate_id,omitempty"`
+}
+
+func writeInspectJSON(
+	fileSummaries []string,
+	chain *core.CertificateChain,
+	unrelated []*core.Certificate,
+	unmatchedKeys []crypto.PrivateKey,
+	hostname string,
+	checkRevocation bool,
+) {
+	verifyErr := chain.Verify(hostname)
+	result := inspectResult{
+		Files:        fileSummaries,
+		Chain:        chain.Summary(),
+		Unrelated:    []*core.CertificateSummary{},
+		PrivateKeys:  []inspectKeyResult{},
+		Verification: core.NewVerificationSummary(verifyErr),
+	}
+	if checkRevocation && verifyErr == nil {
+		result.Revocation = revocationSummaries(chain)
+	}
+	for _, cert := range unrelated {
+		result.Unrelated = append(result.Unrelated, cert.Summary())
+	}
+	for _, cert := range append(chain.Certificates(), unrelated...) {
+		if cert.PrivateKey != nil {
+			result.PrivateKeys = append(result.PrivateKeys, inspectKeyResult{
+				Description:   core.DescribePrivateKey(cert.PrivateKey),
+				CertificateID: cert.ID(),
+			})
+		}
+	}
+	for _, key := range unmatchedKeys {
+		result.PrivateKeys = append(result.PrivateKeys, inspectKeyResult{
+			Description: core.DescribePrivateKey(key),
+		})
+	}
+	writeJSON(result)
+}
+
+func positionName(
+	chain *core.CertificateChain,
+	unrelated []*core.Certificate,
+	cert *core.Certificate,
+) string {
+	for index, other := range unrelated {
+		if cert == other {
+			return fmt.Sprintf("unrelated certificate #%d", index+1)
+		}
+	}
+	return chain.PositionName(cert)
+}
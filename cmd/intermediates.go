@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+)
+
+var intermediatesUpdateCmd = &cobra.Command{
+	Use:   "intermediates:update",
+	Short: "Imports a CCADB export into the offline intermediate database",
+	Long: `
+intermediates:update imports the publicly disclosed intermediate CA
+certificates from a CCADB CSV report that includes PEM data, such as
+Mozilla's intermediate certificates report, replacing the imported database.
+
+Chain building in aws:upload and inspect completes chains from this database
+without network access, before falling back to AIA URLs. Copy the report to
+air-gapped machines and import it there, or pass it directly with
+--intermediates-db.
+
+Example:
+
+  chaintool intermediates:update --ccadb MozillaIntermediateCerts.csv
+`,
+	Run: runIntermediatesUpdate,
+}
+
+func init() {
+	RootCmd.AddCommand(intermediatesUpdateCmd)
+
+	intermediatesUpdateCmd.PersistentFlags().String(
+		"ccadb", "", "CCADB CSV report to import (required)")
+	intermediatesUpdateCmd.PersistentFlags().String(
+		"output", "", "Database file to write (default: chaintool/intermediates.pem under the user config directory)")
+}
+
+func runIntermediatesUpdate(cmd *cobra.Command, args []string) {
+	ccadbPath := pflaghelpers.MustGetString(cmd.Flags(), "ccadb", false)
+	outputPath := pflaghelpers.MustGetString(cmd.Flags(), "output", true)
+
+	if outputPath == "" {
+		var err error
+		if outputPath, err = core.DefaultIntermediateDatabasePath(); err != nil {
+			fatal("%s", err)
+		}
+	}
+
+	imported, skipped, err := core.ImportIntermediateDatabase(ccadbPath, outputPath)
+	if err != nil {
+		fatal("%s", err)
+	}
+	msg("Imported %d intermediates into %s (%d expired, self-signed or unparseable rows skipped).",
+		imported, outputPath, skipped)
+}
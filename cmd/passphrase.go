@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"golang.org/x/term"
+)
+
+func initKeyPassphraseSource() {
+	sourcesGiven := 0
+	for _, given := range []bool{
+		keyPassphraseEnv != "", keyPassphraseFile != "", keyPassphraseStdin,
+	} {
+		if given {
+			sourcesGiven++
+		}
+	}
+	if sourcesGiven > 1 {
+		fatal("Only one of --key-passphrase-env, --key-passphrase-file and " +
+			"--key-passphrase-stdin may be given")
+	}
+
+	switch {
+	case keyPassphraseEnv != "":
+		core.KeyPassphraseSource = cachedPassphraseSource(passphraseFromEnv)
+	case keyPassphraseFile != "":
+		core.KeyPassphraseSource = cachedPassphraseSource(passphraseFromFile)
+	case keyPassphraseStdin:
+		core.KeyPassphraseSource = cachedPassphraseSource(passphraseFromStdin)
+	case term.IsTerminal(int(os.Stdin.Fd())):
+		core.KeyPassphraseSource = passphraseFromPrompt
+	}
+}
+
+// Non-interactive sources can only provide one passphrase, so read it once
+// and reuse it for every key.
+func cachedPassphraseSource(source func() ([]byte, error)) core.PassphraseSource {
+	var passphrase []byte
+	var err error
+	loaded := false
+	return func(keyDescription string) ([]byte, error) {
+		if !loaded {
+			passphrase, err = source()
+			loaded = true
+		}
+		return passphrase, err
+	}
+}
+
+func passphraseFromEnv() ([]byte, error) {
+	value, ok := os.LookupEnv(keyPassphraseEnv)
+	if !ok {
+		return nil, fmt.Errorf("Environment variable %s is not set", keyPassphraseEnv)
+	}
+	return []byte(value), nil
+}
+
+func passphraseFromFile() ([]byte, error) {
+	data, err := ioutil.ReadFile(keyPassphraseFile)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(string(data), "\r\n")), nil
+}
+
+func passphraseFromStdin() ([]byte, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return nil, fmt.Errorf("Unable to read from stdin: %s", err)
+	}
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+}
+
+func passphraseFromPrompt(keyDescription string) ([]byte, error) {
+	fmt.Fprintf(os.Stderr, "Enter passphrase for %s: ", keyDescription)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return passphrase, err
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"net"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+)
+
+var pinsCmd = &cobra.Command{
+	Use:   "pins [file...]",
+	Short: "Computes SPKI pins for every certificate in a chain",
+	Long: `
+pins computes the SHA-256 SPKI hashes (in the HPKP "pin-sha256" format) and
+SHA-256 fingerprints for every certificate in a chain.
+
+The chain can be read from local files (in any format inspect accepts) or
+fetched from a server with --host.
+
+Examples:
+
+  chaintool pins my_cert.crt my_chain.pem
+  chaintool pins --host www.example.com:443
+`,
+	Run: runPins,
+}
+
+func init() {
+	RootCmd.AddCommand(pinsCmd)
+
+	pinsCmd.PersistentFlags().String(
+		"host", "", "Fetch the chain from this hostname[:port] instead of files")
+}
+
+type pinResult struct {
+	ID                string `json:"id"`
+	SHA256Fingerprint string `json:"sha256_fingerprint"`
+	Position          string `json:"position"`
+	Subject           string `json:"subject"`
+	SPKISHA256        string `json:"spki_sha256"`
+}
+
+func runPins(cmd *cobra.Command, args []string) {
+	hostPort := pflaghelpers.MustGetString(cmd.Flags(), "host", true)
+
+	var chain *core.CertificateChain
+	if hostPort != "" {
+		host, port, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			host, port = hostPort, "443"
+		}
+		chain, err = core.FetchCertificateChain(host, port)
+		if err != nil {
+			fatal("Unable to fetch certificates: %s", err)
+		}
+	} else {
+		if len(args) < 1 {
+			cmd.Usage()
+
+			msg("")
+			fatal("either files or --host are required")
+		}
+
+		certs := []*core.Certificate{}
+		for _, path := range args {
+			contents, err := core.LoadFileContents(path)
+			if err != nil {
+				fatal("%s", err)
+			}
+			certs = append(certs, contents.Certificates...)
+		}
+
+		var err error
+		chain, _, err = core.ChainFromCertificates(certs)
+		if err != nil {
+			fatal("%s", err)
+		}
+	}
+
+	results := []pinResult{}
+	for _, cert := range chain.Certificates() {
+		results = append(results, pinResult{
+			ID:                cert.ID(),
+			SHA256Fingerprint: cert.SHA256Fingerprint(),
+			Position:          chain.PositionName(cert),
+			Subject:           cert.Certificate.Subject.CommonName,
+			SPKISHA256:        cert.SPKIHash(),
+		})
+	}
+
+	if jsonOutput {
+		writeJSON(results)
+		return
+	}
+
+	for _, result := range results {
+		msg("%s: %s", result.Position, result.Subject)
+		msg("  SHA-256:  %s", result.SHA256Fingerprint)
+		msg("  SPKI Pin: %s", core.SPKIPinFromHash(result.SPKISHA256))
+	}
+}
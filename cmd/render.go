@@ -0,0 +1,365 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"golang.org/x/term"
+	"golang.org/x/text/width"
+)
+
+var (
+	colorMode   string
+	widthFlag   int
+	tableOutput bool
+)
+
+// outputWidth is what output is wrapped to, and colorOutput whether it gets
+// ANSI colors. Both are decided once by initOutput.
+var (
+	outputWidth = 80
+	colorOutput = false
+)
+
+// Terminals wider than this still get prose wrapped here, as long lines are
+// hard to read. --width isn't limited.
+const maxDetectedWidth = 120
+
+const (
+	colorRed    = "31"
+	colorGreen  = "32"
+	colorYellow = "33"
+	colorCyan   = "36"
+	colorBold   = "1"
+)
+
+// initOutput colors and sizes output for the terminal on stdout. Piped output
+// stays plain and 80 columns wide, unless --color or --width say otherwise.
+// Colors can also be disabled with NO_COLOR (https://no-color.org).
+func initOutput() {
+	isTerminal := term.IsTerminal(int(os.Stdout.Fd()))
+
+	switch colorMode {
+	case "auto":
+		colorOutput = isTerminal && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+	case "always":
+		colorOutput = true
+	case "never":
+		colorOutput = false
+	default:
+		fatal("Invalid --color '%s', expected auto, always or never", colorMode)
+	}
+
+	switch {
+	case widthFlag < 0:
+		fatal("Invalid --width %d", widthFlag)
+	case widthFlag > 0:
+		outputWidth = widthFlag
+	case isTerminal:
+		if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+			outputWidth = width
+		} else if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+			outputWidth = width
+		}
+		if outputWidth > maxDetectedWidth {
+			outputWidth = maxDetectedWidth
+		}
+	}
+}
+
+func colored(color, text string) string {
+	if !colorOutput || color == "" || text == "" {
+		return text
+	}
+	return "\x1b[" + color + "m" + text + "\x1b[0m"
+}
+
+// highlightRules color parts of finished output lines. They run after
+// wrapping, so escape codes never count toward the line width.
+var highlightRules = []struct {
+	pattern *regexp.Regexp
+	color   func(match []string) string
+}{
+	{regexp.MustCompile(`\[error\]`), constantColor(colorRed + ";" + colorBold)},
+	{regexp.MustCompile(`\[warning\]`), constantColor(colorYellow)},
+	{regexp.MustCompile(`\[info\]`), constantColor(colorCyan)},
+	{regexp.MustCompile(`\bPASSED!`), constantColor(colorGreen + ";" + colorBold)},
+	{regexp.MustCompile(`\bFAILED\.`), constantColor(colorRed + ";" + colorBold)},
+	{regexp.MustCompile(`\bREVOKED\b`), constantColor(colorRed + ";" + colorBold)},
+	{regexp.MustCompile(`Expires in: +(-?[0-9.]+) days`), expirationColor},
+	{regexp.MustCompile(`^HIGH `), constantColor(colorRed + ";" + colorBold)},
+	{regexp.MustCompile(`^MEDIUM `), constantColor(colorYellow)},
+}
+
+func constantColor(color string) func([]string) string {
+	return func([]string) string {
+		return color
+	}
+}
+
+func expirationColor(match []string) string {
+	days, err := strconv.ParseFloat(match[1], 64)
+	switch {
+	case err != nil:
+		return ""
+	case days < 0:
+		return colorRed + ";" + colorBold
+	case days < float64(core.ExpirationThreshold()):
+		return colorYellow
+	default:
+		return ""
+	}
+}
+
+func highlight(line string) string {
+	if !colorOutput {
+		return line
+	}
+	for _, rule := range highlightRules {
+		line = rule.pattern.ReplaceAllStringFunc(line, func(text string) string {
+			if color := rule.color(rule.pattern.FindStringSubmatch(text)); color != "" {
+				return colored(color, text)
+			}
+			return text
+		})
+	}
+	return line
+}
+
+func writeLines(lines *core.Lines) {
+	for _, line := range lines.Lines {
+		fmt.Println(highlight(line))
+	}
+}
+
+// table is the compact view: one row per certificate, with columns sized to
+// their contents. When the rows don't fit the output width, the widest
+// columns are truncated.
+type table struct {
+	header []string
+	rows   [][]tableCell
+}
+
+type tableCell struct {
+	text  string
+	color string
+}
+
+func newTable(header ...string) *table {
+	return &table{header: header}
+}
+
+func (t *table) add(cells ...tableCell) {
+	t.rows = append(t.rows, cells)
+}
+
+func plainCell(text string) tableCell {
+	return tableCell{text: text}
+}
+
+const tableColumnGap = "  "
+
+func (t *table) write() {
+	for _, line := range t.format() {
+		fmt.Println(line)
+	}
+}
+
+func (t *table) format() []string {
+	widths := make([]int, len(t.header))
+	for index, name := range t.header {
+		widths[index] = displayWidth(name)
+	}
+	for _, row := range t.rows {
+		for index, cell := range row {
+			if width := displayWidth(cell.text); width > widths[index] {
+				widths[index] = width
+			}
+		}
+	}
+
+	// Shrink the widest column that's still wider than its header, one
+	// column at a time, until the table fits or nothing can shrink.
+	total := len(tableColumnGap) * (len(widths) - 1)
+	for _, width := range widths {
+		total += width
+	}
+	for ; total > outputWidth; total-- {
+		widest := -1
+		for index, width := range widths {
+			if width > displayWidth(t.header[index]) && (widest < 0 || width > widths[widest]) {
+				widest = index
+			}
+		}
+		if widest < 0 {
+			break
+		}
+		widths[widest]--
+	}
+
+	headerCells := []tableCell{}
+	for _, name := range t.header {
+		headerCells = append(headerCells, tableCell{text: name, color: colorBold})
+	}
+	lines := []string{formatRow(headerCells, widths)}
+	for _, row := range t.rows {
+		lines = append(lines, formatRow(row, widths))
+	}
+	return lines
+}
+
+func formatRow(cells []tableCell, widths []int) string {
+	formatted := []string{}
+	for index, cell := range cells {
+		text := truncateToWidth(cell.text, widths[index])
+		if index < len(cells)-1 {
+			text += strings.Repeat(" ", widths[index]-displayWidth(text))
+		}
+		formatted = append(formatted, colored(cell.color, text))
+	}
+	return strings.TrimRight(strings.Join(formatted, tableColumnGap), " ")
+}
+
+// runeWidth is how many terminal columns a rune takes: two for wide and
+// fullwidth East Asian characters, none for combining marks and one for
+// everything else.
+func runeWidth(r rune) int {
+	if unicode.Is(unicode.Mn, r) {
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func displayWidth(text string) int {
+	rv := 0
+	for _, r := range text {
+		rv += runeWidth(r)
+	}
+	return rv
+}
+
+// truncateToWidth cuts text to at most maxWidth columns, marking the cut
+// with a ~. Text is only cut between characters, so a wide character that
+// doesn't fit leaves the result a column short.
+func truncateToWidth(text string, maxWidth int) string {
+	if displayWidth(text) <= maxWidth {
+		return text
+	}
+	rv := strings.Builder{}
+	used := 0
+	for _, r := range text {
+		if used+runeWidth(r) > maxWidth-1 {
+			break
+		}
+		used += runeWidth(r)
+		rv.WriteRune(r)
+	}
+	return rv.String() + "~"
+}
+
+// writeChainTable is the compact version of writeChainInfo, also listing
+// certificates that aren't part of the chain.
+func writeChainTable(chain *core.CertificateChain, unrelated []*core.Certificate) {
+	t := newTable("POSITION", "SUBJECT", "EXPIRES", "KEY", "WARNINGS")
+	for _, cert := range append(chain.Certificates(), unrelated...) {
+		t.add(
+			plainCell(tablePosition(chain, cert)),
+			plainCell(cert.ReadableSubject()),
+			expirationCell(cert),
+			plainCell(tableKey(cert)),
+			warningsCell(chain.CertificateWarnings(cert)),
+		)
+	}
+	t.write()
+}
+
+func tablePosition(chain *core.CertificateChain, cert *core.Certificate) string {
+	if cert == chain.Leaf {
+		return "leaf"
+	}
+	for index, intermediate := range chain.Intermediates {
+		if cert == intermediate {
+			return fmt.Sprintf("int #%d", index+1)
+		}
+	}
+	return "unrelated"
+}
+
+func tableKey(cert *core.Certificate) string {
+	switch publicKey := cert.Certificate.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", publicKey.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + publicKey.Curve.Params().Name
+	default:
+		return cert.ReadablePublicKeyAlgorithm()
+	}
+}
+
+func expirationCell(cert *core.Certificate) tableCell {
+	days := cert.DaysToExpire()
+	text := cert.Certificate.NotAfter.Format("2006-01-02")
+	switch {
+	case days < 0:
+		return tableCell{text: fmt.Sprintf("%s (%.0fd)", text, days), color: colorRed + ";" + colorBold}
+	case days < float64(core.ExpirationThreshold()):
+		return tableCell{text: fmt.Sprintf("%s (%.0fd)", text, days), color: colorYellow}
+	default:
+		return plainCell(text)
+	}
+}
+
+// warningsCell counts warnings by severity, colored by the worst one.
+func warningsCell(warnings []core.Warning) tableCell {
+	if len(warnings) == 0 {
+		return tableCell{text: "none", color: colorGreen}
+	}
+
+	counts := map[core.Severity]int{}
+	worst := core.SeverityInfo
+	for _, warning := range warnings {
+		counts[warning.Severity()]++
+		if warning.Severity() > worst {
+			worst = warning.Severity()
+		}
+	}
+
+	parts := []string{}
+	for _, severity := range []core.Severity{core.SeverityError, core.SeverityWarning, core.SeverityInfo} {
+		if counts[severity] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[severity], severityAbbreviations[severity]))
+		}
+	}
+	return tableCell{text: strings.Join(parts, ", "), color: severityColors[worst]}
+}
+
+var severityAbbreviations = map[core.Severity]string{
+	core.SeverityInfo:    "info",
+	core.SeverityWarning: "warn",
+	core.SeverityError:   "err",
+}
+
+var severityColors = map[core.Severity]string{
+	core.SeverityInfo:    colorCyan,
+	core.SeverityWarning: colorYellow,
+	core.SeverityError:   colorRed + ";" + colorBold,
+}
+
+func resultCell(err error) tableCell {
+	if err != nil {
+		return tableCell{text: "FAIL", color: colorRed + ";" + colorBold}
+	}
+	return tableCell{text: "PASS", color: colorGreen + ";" + colorBold}
+}
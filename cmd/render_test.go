@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"www.example.com", 15},
+		{"Société Générale", 16},
+		{"Société", 7},
+		{"日本認証局", 10},
+		{"ＡＢＣ", 6},
+		{"ｱｲｳ", 3},
+	}
+	for _, test := range tests {
+		if got := displayWidth(test.text); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.text, got, test.want)
+		}
+	}
+}
+
+func TestTruncateToWidth(t *testing.T) {
+	tests := []struct {
+		text     string
+		maxWidth int
+		want     string
+	}{
+		{"www.example.com", 15, "www.example.com"},
+		{"www.example.com", 8, "www.exa~"},
+		{"Société Générale", 8, "Société~"},
+		{"日本認証局", 10, "日本認証局"},
+		{"日本認証局", 7, "日本認~"},
+		{"日本認証局", 6, "日本~"},
+	}
+	for _, test := range tests {
+		if got := truncateToWidth(test.text, test.maxWidth); got != test.want {
+			t.Errorf("%s to %d: got %q, want %q", test.text, test.maxWidth, got, test.want)
+		}
+	}
+}
+
+func TestTitleLine(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{"www.example.com", 21, "== www.example.com =="},
+		{"Société", 12, "== Société ="},
+		{"日本認証局", 16, "== 日本認証局 =="},
+		{"日本認証局", 8, "日本認証局"},
+	}
+	for _, test := range tests {
+		if got := titleLine(test.text, test.width); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestTableFormat(t *testing.T) {
+	defer func(width int, color bool) { outputWidth, colorOutput = width, color }(outputWidth, colorOutput)
+	colorOutput = false
+
+	table := newTable("POSITION", "SUBJECT", "ISSUER")
+	table.add(plainCell("leaf"), plainCell("日本認証局サーバー"), plainCell("Example Intermediate CA"))
+	table.add(plainCell("int #1"), plainCell("Example Intermediate CA"), plainCell("Root"))
+
+	tests := []struct {
+		width int
+		want  []string
+	}{
+		{
+			80,
+			[]string{
+				"POSITION  SUBJECT                  ISSUER",
+				"leaf      日本認証局サーバー       Example Intermediate CA",
+				"int #1    Example Intermediate CA  Root",
+			},
+		},
+		// Once the issuer is down to the subject's width, both shrink in
+		// turn.
+		{
+			50,
+			[]string{
+				"POSITION  SUBJECT              ISSUER",
+				"leaf      日本認証局サーバー   Example Intermedia~",
+				"int #1    Example Intermedia~  Root",
+			},
+		},
+		// Columns never get narrower than their header.
+		{
+			20,
+			[]string{
+				"POSITION  SUBJECT  ISSUER",
+				"leaf      日本認~  Examp~",
+				"int #1    Exampl~  Root",
+			},
+		},
+	}
+	for _, test := range tests {
+		outputWidth = test.width
+		if got := table.format(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("width %d: got\n%q\nwant\n%q", test.width, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bytes"
+	"net"
+	"os"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report [file|host[:port]...]",
+	Short: "Writes an HTML and/or Markdown certificate audit report",
+	Long: `
+report verifies and lints chains from any mix of sources and writes an audit
+report: a summary dashboard, the verification outcome of every chain, an
+expiry timeline, a breakdown of warnings by lint and the details of every
+distinct certificate.
+
+Chains are read from the given files or fetched from the given servers, and
+also from AWS IAM with --aws and from Heroku SSL endpoints with --heroku.
+
+The HTML report is a single self-contained page; the Markdown report suits
+wikis and tickets.
+
+Examples:
+
+  chaintool report --html audit.html --markdown audit.md www.example.com:443 certs/*.pem
+  chaintool report --aws --heroku --html audit.html
+`,
+	Run: runReport,
+}
+
+func init() {
+	RootCmd.AddCommand(reportCmd)
+
+	reportCmd.PersistentFlags().String("html", "", "Write the HTML report to this file")
+	reportCmd.PersistentFlags().String("markdown", "", "Write the Markdown report to this file")
+	reportCmd.PersistentFlags().Bool(
+		"aws", false, "Include the server certificates in AWS IAM")
+	reportCmd.PersistentFlags().String("region", DefaultAWSRegion, "AWS Region")
+	reportCmd.PersistentFlags().Bool(
+		"heroku", false, "Include the certificates of Heroku SSL endpoints")
+	reportCmd.PersistentFlags().Bool("force", false, "Overwrite existing report files")
+}
+
+// reportDetailWidth is what certificate details are wrapped to in reports,
+// independently of the terminal.
+const reportDetailWidth = 100
+
+func runReport(cmd *cobra.Command, args []string) {
+	htmlPath := pflaghelpers.MustGetString(cmd.Flags(), "html", true)
+	markdownPath := pflaghelpers.MustGetString(cmd.Flags(), "markdown", true)
+	useAWS := pflaghelpers.MustGetBool(cmd.Flags(), "aws")
+	region := pflaghelpers.MustGetString(cmd.Flags(), "region", false)
+	useHeroku := pflaghelpers.MustGetBool(cmd.Flags(), "heroku")
+	force := pflaghelpers.MustGetBool(cmd.Flags(), "force")
+
+	if htmlPath == "" && markdownPath == "" {
+		cmd.Usage()
+
+		msg("")
+		fatal("at least one of --html and --markdown is required")
+	}
+
+	targets := []*core.AuditTarget{}
+	for _, source := range args {
+		targets = append(targets, auditTarget(source))
+	}
+	if useAWS {
+		for _, chain := range chainsFromIAM(region) {
+			targets = append(targets, &core.AuditTarget{Source: chain.Leaf.Source, Chain: chain})
+		}
+	}
+	if useHeroku {
+		for _, chain := range chainsFromHeroku() {
+			targets = append(targets, &core.AuditTarget{Source: chain.Leaf.Source, Chain: chain})
+		}
+	}
+	if len(targets) <= 0 {
+		fatal("No certificates found, give files or servers, or use --aws or --heroku.")
+	}
+
+	report := core.NewReport(targets, reportDetailWidth)
+
+	if htmlPath != "" {
+		buffer := &bytes.Buffer{}
+		if err := report.WriteHTML(buffer); err != nil {
+			fatal("Unable to render the HTML report: %s", err)
+		}
+		writeOutputFile(htmlPath, buffer.Bytes(), 0644, force)
+		msg("HTML report written to %s", htmlPath)
+	}
+	if markdownPath != "" {
+		buffer := &bytes.Buffer{}
+		if err := report.WriteMarkdown(buffer); err != nil {
+			fatal("Unable to render the Markdown report: %s", err)
+		}
+		writeOutputFile(markdownPath, buffer.Bytes(), 0644, force)
+		msg("Markdown report written to %s", markdownPath)
+	}
+
+	summary := report.Summary
+	msg("%d chains (%d failed verification), %d certificates (%d expired, %d expiring soon), "+
+		"%d error(s), %d warning(s).",
+		summary.Chains, summary.Failed, summary.Certificates, summary.Expired, summary.ExpiringSoon,
+		summary.Errors, summary.Warnings)
+
+	warnings := []core.Warning{}
+	for _, cert := range report.Certificates {
+		warnings = append(warnings, cert.Warnings...)
+	}
+	failOnWarnings(warnings)
+}
+
+// auditTarget loads a chain like loadChain, verifying servers against their
+// hostname. Sources that can't be loaded, such as unreachable servers, are
+// kept as failed targets so that one of them doesn't stop the whole audit.
+func auditTarget(source string) *core.AuditTarget {
+	target := &core.AuditTarget{Source: source}
+	target.Chain, target.Err = chainFromSource(source)
+	if target.Err != nil {
+		warning("%s", target.Err)
+		return target
+	}
+	if _, err := os.Stat(source); err != nil {
+		if host, _, err := net.SplitHostPort(source); err == nil {
+			target.Hostname = host
+		} else {
+			target.Hostname = source
+		}
+	}
+	return target
+}
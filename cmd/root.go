@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/cesarkawakami/chaintool/core"
 	"github.com/cesarkawakami/pflaghelpers"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -33,6 +34,32 @@ const DefaultAWSRegion = "us-east-1"
 
 var cfgFile string
 
+var (
+	verboseOutput bool
+	jsonOutput    bool
+)
+
+var (
+	trustStores     []string
+	distrustDBs     []string
+	compromisedKeys []string
+	debianWeakKeys  []string
+	intermediateDBs []string
+)
+
+var (
+	aiaCacheDir    string
+	bypassAIACache bool
+	clearAIACache  bool
+	offline        bool
+)
+
+var (
+	keyPassphraseEnv   string
+	keyPassphraseFile  string
+	keyPassphraseStdin bool
+)
+
 // This represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
 	Use:   "chaintool",
@@ -72,6 +99,69 @@ func init() {
 
 	RootCmd.PersistentFlags().StringVar(
 		&cfgFile, "config", "", "config file (default is $HOME/.chaintool.yaml)")
+	RootCmd.PersistentFlags().BoolVarP(
+		&verboseOutput, "verbose", "v", false,
+		"show all certificate details (DNs, extensions, policies, key IDs)")
+	RootCmd.PersistentFlags().BoolVar(
+		&jsonOutput, "json", false,
+		"emit machine-readable JSON instead of text, identifying certificates by SHA-256 fingerprint")
+	RootCmd.PersistentFlags().BoolVar(
+		&tableOutput, "table", false,
+		"show certificates as a compact table, one row each (verify, inspect and aws:list)")
+	RootCmd.PersistentFlags().StringVar(
+		&colorMode, "color", "auto",
+		"color output: auto (only on terminals, unless NO_COLOR is set), always or never")
+	RootCmd.PersistentFlags().IntVar(
+		&widthFlag, "width", 0,
+		"wrap output to this many columns (default: terminal width, or 80 when piped)")
+	RootCmd.PersistentFlags().StringVar(
+		&keyPassphraseEnv, "key-passphrase-env", "",
+		"environment variable holding the passphrase for encrypted private keys")
+	RootCmd.PersistentFlags().StringVar(
+		&keyPassphraseFile, "key-passphrase-file", "",
+		"file holding the passphrase for encrypted private keys")
+	RootCmd.PersistentFlags().BoolVar(
+		&keyPassphraseStdin, "key-passphrase-stdin", false,
+		"read the passphrase for encrypted private keys from stdin")
+	RootCmd.PersistentFlags().StringSliceVar(
+		&trustStores, "trust-store", nil,
+		"PEM file of extra trusted roots, e.g. a local CA (can be given multiple times)")
+	RootCmd.PersistentFlags().StringSliceVar(
+		&distrustDBs, "distrust-db", nil,
+		"YAML file of extra distrusted CAs, added to the built-in list (can be given multiple times)")
+	RootCmd.PersistentFlags().StringSliceVar(
+		&compromisedKeys, "compromised-keys", nil,
+		"file of SPKI hashes of compromised keys, one per line (can be given multiple times)")
+	RootCmd.PersistentFlags().StringSliceVar(
+		&debianWeakKeys, "debian-weak-keys", nil,
+		"Debian weak key blocklist in openssl-blacklist format, if the openssl-blacklist package isn't installed (can be given multiple times)")
+	RootCmd.PersistentFlags().StringSliceVar(
+		&intermediateDBs, "intermediates-db", nil,
+		"PEM file or CCADB CSV report of intermediates for completing chains (can be given multiple times)")
+	RootCmd.PersistentFlags().StringVar(
+		&aiaCacheDir, "aia-cache", "",
+		"directory caching the issuers fetched from AIA URLs (default: chaintool/aia under the user cache directory)")
+	RootCmd.PersistentFlags().BoolVar(
+		&bypassAIACache, "bypass-aia-cache", false,
+		"fetch issuers from AIA URLs even if cached, refreshing the cache")
+	RootCmd.PersistentFlags().BoolVar(
+		&clearAIACache, "clear-aia-cache", false,
+		"empty the AIA cache before running")
+	RootCmd.PersistentFlags().BoolVar(
+		&offline, "offline", false,
+		"don't fetch issuers from AIA URLs, only use the ones in the AIA cache")
+	RootCmd.PersistentFlags().StringToIntVar(
+		&warningThresholds, "warning-threshold", nil,
+		"override a lint threshold, e.g. expiring-soon=30 (days) or key-too-short=3072 (bits)")
+	RootCmd.PersistentFlags().StringToStringVar(
+		&warningSeverities, "warning-severity", nil,
+		"override a lint severity, e.g. internal-name-in-san=info")
+	RootCmd.PersistentFlags().StringSliceVar(
+		&suppressedLints, "suppress", nil,
+		"lint ID to hide everywhere (can be given multiple times; scoped suppressions go in the config file)")
+	RootCmd.PersistentFlags().StringVar(
+		&failOn, "fail-on", "",
+		"exit with an error if there are warnings of this severity or higher (info, warning or error)")
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 }
@@ -80,14 +170,66 @@ func init() {
 func initConfig() {
 	if cfgFile != "" { // enable ability to specify config file via flag
 		viper.SetConfigFile(cfgFile)
+	} else {
+		// SetConfigName would discard the file given above.
+		viper.SetConfigName(".chaintool") // name of config file (without extension)
+		viper.AddConfigPath("$HOME")      // adding home directory as first search path
 	}
-
-	viper.SetConfigName(".chaintool") // name of config file (without extension)
-	viper.AddConfigPath("$HOME")      // adding home directory as first search path
-	viper.AutomaticEnv()              // read in environment variables that match
+	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+	}
+
+	initOutput()
+	initKeyPassphraseSource()
+	initWarningPolicy()
+	initAIACache()
+
+	for _, path := range trustStores {
+		if err := core.LoadTrustStore(path); err != nil {
+			fatal("%s", err)
+		}
+	}
+	for _, path := range distrustDBs {
+		if err := core.LoadDistrustDatabase(path); err != nil {
+			fatal("%s", err)
+		}
+	}
+	for _, path := range compromisedKeys {
+		if err := core.LoadCompromisedKeys(path); err != nil {
+			fatal("%s", err)
+		}
+	}
+	for _, path := range debianWeakKeys {
+		if err := core.LoadDebianWeakKeys(path); err != nil {
+			fatal("%s", err)
+		}
+	}
+	for _, path := range intermediateDBs {
+		if err := core.LoadIntermediateDatabase(path); err != nil {
+			fatal("%s", err)
+		}
+	}
+}
+
+func initAIACache() {
+	dir := aiaCacheDir
+	if dir == "" {
+		var err error
+		if dir, err = core.DefaultAIACacheDir(); err != nil {
+			warning("%s, not caching AIA fetches", err)
+		}
+	}
+	core.ConfigureAIACache(dir, bypassAIACache, offline)
+
+	if clearAIACache {
+		if err := core.ClearAIACache(); err != nil {
+			fatal("%s", err)
+		}
+		if dir != "" {
+			fmt.Fprintf(os.Stderr, "Cleared the AIA cache at %s\n", dir)
+		}
 	}
 }
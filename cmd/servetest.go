@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+)
+
+var serveTestCmd = &cobra.Command{
+	Use:   "serve-test",
+	Short: "Serves a given certificate chain over TLS for testing",
+	Long: `
+serve-test serves exactly the given leaf, chain and key on a local port, so
+verify, browsers and curl can be pointed at it to see what clients see.
+
+The certificates are sent in the order they appear in --cert followed by
+--chain, unless --misorder is given, which reverses the intermediates. A
+testpki fixture directory can be given with --fixture instead.
+
+Every request is answered with a short description of the handshake.
+
+Examples:
+
+  chaintool serve-test --cert www.crt --chain chain.pem --key www.key
+  chaintool serve-test --fixture testpki/wrong-order --listen 127.0.0.1:8443
+  chaintool serve-test --cert fullchain.pem --key www.key --max-tls 1.2 --staple-fetch
+  chaintool serve-test --cert fullchain.pem --key www.key --client-auth require --client-ca ca.pem
+`,
+	Run: runServeTest,
+}
+
+func init() {
+	RootCmd.AddCommand(serveTestCmd)
+
+	serveTestCmd.PersistentFlags().String("cert", "", "Leaf certificate, optionally followed by the chain")
+	serveTestCmd.PersistentFlags().String("chain", "", "Intermediate certificates to send after the leaf")
+	serveTestCmd.PersistentFlags().String("key", "", "Private key (default: a key found in --cert)")
+	serveTestCmd.PersistentFlags().String("fixture", "", "testpki fixture directory, instead of --cert and --key")
+	serveTestCmd.PersistentFlags().String("listen", "127.0.0.1:8443", "Address to listen on")
+
+	serveTestCmd.PersistentFlags().String("min-tls", "1.0", "Minimum protocol version: 1.0, 1.1, 1.2 or 1.3")
+	serveTestCmd.PersistentFlags().String("max-tls", "1.3", "Maximum protocol version: 1.0, 1.1, 1.2 or 1.3")
+	serveTestCmd.PersistentFlags().Bool("misorder", false, "Send the intermediates in reverse order")
+
+	serveTestCmd.PersistentFlags().String("staple", "", "DER OCSP response file to staple")
+	serveTestCmd.PersistentFlags().Bool("staple-fetch", false, "Fetch an OCSP response from the leaf's responder and staple it")
+
+	serveTestCmd.PersistentFlags().String("client-auth", "none", "Client certificates: none, request or require")
+	serveTestCmd.PersistentFlags().String("client-ca", "", "Roots to verify client certificates against (default: accept any)")
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func tlsVersionName(version uint16) string {
+	for name, v := range tlsVersions {
+		if v == version {
+			return "TLS " + name
+		}
+	}
+	return fmt.Sprintf("unknown (0x%04x)", version)
+}
+
+func mustParseTLSVersion(flag, name string) uint16 {
+	version, ok := tlsVersions[name]
+	if !ok {
+		fatal("Invalid --%s '%s', expected one of 1.0, 1.1, 1.2 or 1.3", flag, name)
+	}
+	return version
+}
+
+func runServeTest(cmd *cobra.Command, args []string) {
+	certPath := pflaghelpers.MustGetString(cmd.Flags(), "cert", true)
+	chainPath := pflaghelpers.MustGetString(cmd.Flags(), "chain", true)
+	keyPath := pflaghelpers.MustGetString(cmd.Flags(), "key", true)
+	fixtureDir := pflaghelpers.MustGetString(cmd.Flags(), "fixture", true)
+	listen := pflaghelpers.MustGetString(cmd.Flags(), "listen", false)
+	minVersion := mustParseTLSVersion("min-tls", pflaghelpers.MustGetString(cmd.Flags(), "min-tls", false))
+	maxVersion := mustParseTLSVersion("max-tls", pflaghelpers.MustGetString(cmd.Flags(), "max-tls", false))
+	misorder := pflaghelpers.MustGetBool(cmd.Flags(), "misorder")
+	staplePath := pflaghelpers.MustGetString(cmd.Flags(), "staple", true)
+	stapleFetch := pflaghelpers.MustGetBool(cmd.Flags(), "staple-fetch")
+	clientAuth := pflaghelpers.MustGetString(cmd.Flags(), "client-auth", false)
+	clientCAPath := pflaghelpers.MustGetString(cmd.Flags(), "client-ca", true)
+
+	if fixtureDir != "" {
+		if certPath != "" || keyPath != "" {
+			fatal("--fixture can't be combined with --cert or --key")
+		}
+		certPath = filepath.Join(fixtureDir, "fullchain.pem")
+		keyPath = filepath.Join(fixtureDir, "leaf.key")
+	}
+	if certPath == "" {
+		cmd.Usage()
+
+		msg("")
+		fatal("--cert or --fixture is required")
+	}
+	if minVersion > maxVersion {
+		fatal("--min-tls is above --max-tls")
+	}
+
+	certs, keys := loadServeTestFiles(certPath, chainPath, keyPath)
+	leaf := certs[0]
+	intermediates := certs[1:]
+
+	// The issuer is found by signature, since misordered or root-first
+	// chains don't start with it.
+	var ocspIssuer *core.Certificate
+	if stapleFetch {
+		if ocspIssuer = leaf.IssuerAmong(intermediates); ocspIssuer == nil {
+			fatal("--staple-fetch needs the leaf's issuer in the chain, but none of the given certificates issued it")
+		}
+	}
+
+	if misorder {
+		if len(intermediates) < 2 {
+			warning("--misorder has no effect with fewer than two intermediates")
+		}
+		for i, j := 0, len(intermediates)-1; i < j; i, j = i+1, j-1 {
+			intermediates[i], intermediates[j] = intermediates[j], intermediates[i]
+		}
+	}
+
+	core.MatchPrivateKeys([]*core.Certificate{leaf}, keys)
+	if leaf.PrivateKey == nil {
+		fatal("No private key matching the leaf certificate was found")
+	}
+
+	tlsCert := tls.Certificate{
+		Certificate: [][]byte{leaf.Certificate.Raw},
+		PrivateKey:  leaf.PrivateKey,
+		Leaf:        leaf.Certificate,
+	}
+	for _, cert := range intermediates {
+		tlsCert.Certificate = append(tlsCert.Certificate, cert.Certificate.Raw)
+	}
+
+	switch {
+	case staplePath != "" && stapleFetch:
+		fatal("--staple and --staple-fetch can't be combined")
+	case staplePath != "":
+		staple, err := ioutil.ReadFile(staplePath)
+		if err != nil {
+			fatal("Unable to read OCSP response: %s", err)
+		}
+		tlsCert.OCSPStaple = staple
+	case stapleFetch:
+		staple, err := leaf.FetchOCSPStaple(ocspIssuer)
+		if err != nil {
+			fatal("%s", err)
+		}
+		tlsCert.OCSPStaple = staple
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{tlsCert},
+		MinVersion:   minVersion,
+		MaxVersion:   maxVersion,
+	}
+
+	switch clientAuth {
+	case "none":
+		tlsConfig.ClientAuth = tls.NoClientCert
+	case "request":
+		tlsConfig.ClientAuth = tls.RequestClientCert
+	case "require":
+		tlsConfig.ClientAuth = tls.RequireAnyClientCert
+	default:
+		fatal("Invalid --client-auth '%s', expected one of none, request or require", clientAuth)
+	}
+	if clientCAPath != "" {
+		clientCAData, err := ioutil.ReadFile(clientCAPath)
+		if err != nil {
+			fatal("Unable to read client CAs: %s", err)
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(clientCAData) {
+			fatal("No certificates found in %s", clientCAPath)
+		}
+		if tlsConfig.ClientAuth == tls.RequireAnyClientCert {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		} else if tlsConfig.ClientAuth == tls.RequestClientCert {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	server := &http.Server{
+		Addr:      listen,
+		TLSConfig: tlsConfig,
+		Handler:   http.HandlerFunc(describeHandshake),
+	}
+
+	msg("Serving %s with %d intermediate(s) on https://%s/", leaf.ReadableSubject(), len(intermediates), listen)
+	msg("Protocols: %s to %s", tlsVersionName(minVersion), tlsVersionName(maxVersion))
+	if tlsCert.OCSPStaple != nil {
+		msg("Stapling an OCSP response (%d bytes)", len(tlsCert.OCSPStaple))
+	}
+	if err := server.ListenAndServeTLS("", ""); err != nil {
+		fatal("%s", err)
+	}
+}
+
+// loadServeTestFiles returns the certificates in file order, with the keys
+// found along the way.
+func loadServeTestFiles(certPath, chainPath, keyPath string) ([]*core.Certificate, []crypto.PrivateKey) {
+	certs := []*core.Certificate{}
+	keys := []crypto.PrivateKey{}
+	for _, path := range []string{certPath, chainPath, keyPath} {
+		if path == "" {
+			continue
+		}
+		contents, err := core.LoadFileContents(path)
+		if err != nil {
+			fatal("%s", err)
+		}
+		certs = append(certs, contents.Certificates...)
+		keys = append(keys, contents.PrivateKeys...)
+	}
+
+	if len(certs) == 0 {
+		fatal("No certificates found in %s", certPath)
+	}
+	return certs, keys
+}
+
+func describeHandshake(w http.ResponseWriter, r *http.Request) {
+	state := r.TLS
+	clientCert := "none"
+	if len(state.PeerCertificates) > 0 {
+		clientCert = (&core.Certificate{Certificate: state.PeerCertificates[0]}).ReadableSubject()
+	}
+
+	lines := []string{
+		fmt.Sprintf("Protocol:    %s", tlsVersionName(state.Version)),
+		fmt.Sprintf("Cipher:      %s", tls.CipherSuiteName(state.CipherSuite)),
+		fmt.Sprintf("SNI:         %s", state.ServerName),
+		fmt.Sprintf("Client cert: %s", clientCert),
+	}
+
+	msg("%s %s %s: %s, %s, SNI '%s', client cert: %s",
+		r.RemoteAddr, r.Method, r.URL.Path, tlsVersionName(state.Version),
+		tls.CipherSuiteName(state.CipherSuite), state.ServerName, clientCert)
+	fmt.Fprintln(w, strings.Join(lines, "\n"))
+}
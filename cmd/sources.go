@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/chaintool/heroku"
+)
+
+// loadChain reads a chain from a certificate file or, if no such file
+// exists, fetches it from a host[:port].
+func loadChain(source string) *core.CertificateChain {
+	chain, err := chainFromSource(source)
+	if err != nil {
+		fatal("%s", err)
+	}
+	return chain
+}
+
+// chainFromSource is loadChain, returning errors instead of exiting.
+func chainFromSource(source string) (*core.CertificateChain, error) {
+	if _, err := os.Stat(source); err == nil {
+		contents, err := core.LoadFileContents(source)
+		if err != nil {
+			return nil, err
+		}
+		core.MatchPrivateKeys(contents.Certificates, contents.PrivateKeys)
+		chain, unrelated, err := core.ChainFromCertificates(contents.Certificates)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", source, err)
+		}
+		if len(unrelated) > 0 {
+			warning("ignoring %d certificate(s) in %s not part of the chain", len(unrelated), source)
+		}
+		return chain, nil
+	}
+
+	host, port, err := net.SplitHostPort(source)
+	if err != nil {
+		host, port = source, "443"
+	}
+	chain, err := core.FetchCertificateChain(host, port)
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither a file nor a reachable server: %s", source, err)
+	}
+	return chain, nil
+}
+
+// leafCertificatesFromFile returns the non-CA certificates in a file.
+func leafCertificatesFromFile(path string) []*core.Certificate {
+	contents, err := core.LoadFileContents(path)
+	if err != nil {
+		fatal("%s", err)
+	}
+
+	leaves := []*core.Certificate{}
+	for _, cert := range contents.Certificates {
+		if !cert.Certificate.IsCA {
+			leaves = append(leaves, cert)
+		}
+	}
+	return leaves
+}
+
+func leafCertificatesFromIAM(region string) []*core.Certificate {
+	leaves := []*core.Certificate{}
+	for _, chain := range chainsFromIAM(region) {
+		leaves = append(leaves, chain.Leaf)
+	}
+	return leaves
+}
+
+func chainsFromIAM(region string) []*core.CertificateChain {
+	iamSvc := iam.New(session.New(&aws.Config{
+		Region: aws.String(region),
+	}))
+
+	certificates, err := iamAllServerCertificates(iamSvc)
+	if err != nil {
+		fatal("Unable to fetch certificates: %s", err)
+	}
+
+	chains := []*core.CertificateChain{}
+	for _, awsCertificate := range certificates {
+		chain, err := core.ChainFromAWS(awsCertificate)
+		if err != nil {
+			fatal("%s", err)
+		}
+		chains = append(chains, chain)
+	}
+	return chains
+}
+
+func leafCertificatesFromHeroku() []*core.Certificate {
+	leaves := []*core.Certificate{}
+	for _, chain := range chainsFromHeroku() {
+		leaves = append(leaves, chain.Leaf)
+	}
+	return leaves
+}
+
+// chainsFromHeroku returns the chains of every SSL endpoint the user can
+// see, skipping organization apps they haven't joined.
+func chainsFromHeroku() []*core.CertificateChain {
+	login, password, err := getHerokuLogin()
+	if err != nil {
+		msg("Unable to load Heroku credentials: %s", err)
+		fatal("Perhaps running `heroku login` would help?")
+	}
+
+	herokuClient := heroku.NewClient(login, password)
+
+	userAccount, err := herokuClient.Account()
+	if err != nil {
+		fatal("Unable to fetch user account data: %s", err)
+	}
+
+	apps, err := herokuClient.AllApps()
+	if err != nil {
+		fatal("Failed loading apps: %s", err)
+	}
+
+	chains := []*core.CertificateChain{}
+	for _, app := range apps {
+		if isOrganizationEmail(app.Owner.Email) {
+			collabs, err := herokuClient.AllOrganizationAppCollaborators(app.ID)
+			if err != nil {
+				fatal("Unable to fetch organization app collaborators: %s", err)
+			}
+
+			joined := false
+			for _, collab := range collabs {
+				if collab.User.Email == userAccount.Email {
+					joined = true
+					break
+				}
+			}
+			if !joined {
+				warning("skipping unjoined organization app %s", app.Name)
+				continue
+			}
+		}
+
+		sslEndpoints, err := herokuClient.AllSSLEndpoints(app.ID)
+		if err != nil {
+			fatal("Failed loading SSL Endpoints: %s", err)
+		}
+		for _, sslEndpoint := range sslEndpoints {
+			chain, err := core.ChainFromFullChainData([]byte(sslEndpoint.CertificateChain))
+			if err != nil {
+				fatal("Failed to parse cert data: %s", err)
+			}
+			chain.Leaf.Source = fmt.Sprintf("heroku:%s/%s", app.Name, sslEndpoint.CName)
+			chains = append(chains, chain)
+		}
+	}
+	return chains
+}
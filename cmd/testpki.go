@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
+	"github.com/spf13/cobra"
+)
+
+var testPKICmd = &cobra.Command{
+	Use:   "testpki [defect...]",
+	Short: "Generates test PKIs with deliberately broken chains",
+	Long: `
+testpki generates small PKIs (root, intermediates, leaf and key) with a
+chosen defect, to reproduce chain handling issues without real certificates.
+Each fixture goes in its own directory under --out, with a README.txt
+describing the defect, the expected behavior and how to check it.
+
+Without arguments, every defect is generated. Available defects:
+
+  ` + strings.Join(core.TestPKIDefects(), "\n  ") + `
+
+Examples:
+
+  chaintool testpki
+  chaintool testpki --out fixtures wrong-order included-root
+`,
+	Run: runTestPKI,
+}
+
+func init() {
+	RootCmd.AddCommand(testPKICmd)
+
+	testPKICmd.PersistentFlags().String("out", "testpki", "Directory to write the fixtures to")
+	testPKICmd.PersistentFlags().Bool("force", false, "Overwrite existing fixtures")
+}
+
+func runTestPKI(cmd *cobra.Command, args []string) {
+	outDir := pflaghelpers.MustGetString(cmd.Flags(), "out", false)
+	force := pflaghelpers.MustGetBool(cmd.Flags(), "force")
+
+	defects := args
+	if len(defects) == 0 {
+		defects = core.TestPKIDefects()
+	}
+
+	fixtures := []*core.TestPKIFixture{}
+	for _, defect := range defects {
+		fixture, err := core.GenerateTestPKI(defect)
+		if err != nil {
+			fatal("%s", err)
+		}
+		fixtures = append(fixtures, fixture)
+	}
+
+	for _, fixture := range fixtures {
+		dir := filepath.Join(outDir, fixture.Defect)
+		if _, err := os.Stat(dir); err == nil && !force {
+			fatal("%s already exists, refusing to overwrite it (use --force)", dir)
+		}
+		if err := fixture.WriteTo(dir); err != nil {
+			fatal("%s", err)
+		}
+		msg("%-26s %s", fixture.Defect, dir)
+	}
+}
@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/spf13/cobra"
 )
 
 func fatal(format string, a ...interface{}) {
@@ -11,26 +15,103 @@ func fatal(format string, a ...interface{}) {
 	os.Exit(1)
 }
 
+// warning goes to stderr, unlike msg, for commands whose output may be
+// piped.
+func warning(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", a...)
+}
+
 func msg(format string, a ...interface{}) {
-	fmt.Printf(format+"\n", a...)
+	fmt.Println(highlight(fmt.Sprintf(format, a...)))
 }
 
 func title(format string, a ...interface{}) {
-	formatted := fmt.Sprintf(format, a...)
+	fmt.Println(colored(colorBold, titleLine(fmt.Sprintf(format, a...), outputWidth)))
+}
+
+// titleLine centers text between runs of = filling width columns.
+func titleLine(text string, width int) string {
 	currentIsLeft := true
 	spacesToPrint := 2
-	for len(formatted) < 80 {
+	for textWidth := displayWidth(text); textWidth < width; textWidth++ {
 		charToAdd := "="
 		if spacesToPrint > 0 {
 			charToAdd = " "
 			spacesToPrint--
 		}
 		if currentIsLeft {
-			formatted = charToAdd + formatted
+			text = charToAdd + text
 		} else {
-			formatted = formatted + charToAdd
+			text = text + charToAdd
 		}
 		currentIsLeft = !currentIsLeft
 	}
-	fmt.Println(formatted)
+	return text
+}
+
+func writeChainInfo(chain *core.CertificateChain) {
+	if tableOutput {
+		writeChainTable(chain, nil)
+	} else if verboseOutput {
+		writeLines(chain.VerboseInfoLines(outputWidth))
+	} else {
+		writeLines(chain.InfoLines(outputWidth))
+	}
+}
+
+func writeCertificateInfo(cert *core.Certificate, indent string) {
+	if verboseOutput {
+		writeLines(cert.VerboseInfoLines(outputWidth - len(indent)).IndentedBy(indent))
+	} else {
+		writeLines(cert.InfoLines(outputWidth - len(indent)).IndentedBy(indent))
+	}
+}
+
+func writeJSON(v interface{}) {
+	encoded, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fatal("Unable to encode JSON output: %s", err)
+	}
+	fmt.Println(string(encoded))
+}
+
+func mustGetStringSlice(cmd *cobra.Command, name string) []string {
+	value, err := cmd.Flags().GetStringSlice(name)
+	if err != nil {
+		fatal("Internal error reading flag --%s: %s", name, err)
+	}
+	return value
+}
+
+func mustGetInt(cmd *cobra.Command, name string) int {
+	value, err := cmd.Flags().GetInt(name)
+	if err != nil {
+		fatal("Internal error reading flag --%s: %s", name, err)
+	}
+	return value
+}
+
+// writeOutputFile refuses to clobber existing files unless overwrite is set,
+// and creates new files with the given permissions from the start, so keys
+// are never readable by others, even briefly.
+func writeOutputFile(path string, data []byte, perm os.FileMode, overwrite bool) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(path, flags, perm)
+	if os.IsExist(err) {
+		fatal("%s already exists, refusing to overwrite it (use --force)", path)
+	} else if err != nil {
+		fatal("Unable to write %s: %s", path, err)
+	}
+	defer f.Close()
+
+	if err := f.Chmod(perm); err != nil {
+		fatal("Unable to set permissions on %s: %s", path, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		fatal("Unable to write %s: %s", path, err)
+	}
 }
@@ -25,6 +25,7 @@ import (
 	"os"
 
 	"github.com/cesarkawakami/chaintool/core"
+	"github.com/cesarkawakami/pflaghelpers"
 	"github.com/spf13/cobra"
 )
 
@@ -52,6 +53,16 @@ func init() {
 	// is called directly, e.g.:
 	// verifyCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 
+	verifyCmd.PersistentFlags().Bool(
+		"check-revocation", false, "Check the chain against OCSP responders and CRLs")
+}
+
+type verifyResult struct {
+	Host         string                    `json:"host"`
+	Port         string                    `json:"port"`
+	Chain        *core.ChainSummary        `json:"chain"`
+	Verification core.VerificationSummary  `json:"verification"`
+	Revocation   []*core.RevocationSummary `json:"revocation,omitempty"`
 }
 
 func runVerify(cmd *cobra.Command, args []string) {
@@ -65,21 +76,39 @@ func runVerify(cmd *cobra.Command, args []string) {
 		host, port, err = net.SplitHostPort(args[0] + ":443")
 		if err != nil {
 			fatal("'%s' is not in the 'hostname:port' format", args[0])
-		} else {
+		} else if !jsonOutput {
 			msg("Port not given, assuming 443.")
 		}
 	}
 
-	msg("")
-
-	title("Certificate Information")
+	checkRevocation := pflaghelpers.MustGetBool(cmd.Flags(), "check-revocation")
 
 	chain, err := core.FetchCertificateChain(host, port)
 	if err != nil {
 		fatal("Unable to fetch certificates: %s", err)
 	}
 
-	chain.InfoLines(80).Write(os.Stdout)
+	if jsonOutput {
+		verifyErr := chain.Verify(host)
+		result := verifyResult{
+			Host:         host,
+			Port:         port,
+			Chain:        chain.Summary(),
+			Verification: core.NewVerificationSummary(verifyErr),
+		}
+		if checkRevocation && verifyErr == nil {
+			result.Revocation = revocationSummaries(chain)
+		}
+		writeJSON(result)
+		failOnWarnings(chain.Warnings())
+		return
+	}
+
+	msg("")
+
+	title("Certificate Information")
+
+	writeChainInfo(chain)
 
 	msg("")
 
@@ -92,5 +121,42 @@ func runVerify(cmd *cobra.Command, args []string) {
 		msg("%s", err)
 	} else {
 		msg("Result: PASSED!")
+
+		if checkRevocation {
+			msg("")
+			writeRevocationResults(chain)
+		}
+	}
+
+	failOnWarnings(chain.Warnings())
+}
+
+func writeRevocationResults(chain *core.CertificateChain) {
+	title("Revocation")
+
+	results, err := chain.CheckRevocation()
+	if err != nil {
+		msg("%s", err)
+		return
+	}
+	for _, result := range results {
+		msg("%s:", result.Certificate.ReadableSubject())
+		msg("  %s", result.Description())
+		for _, err := range result.Errors {
+			msg("  - %s", err)
+		}
+	}
+}
+
+func revocationSummaries(chain *core.CertificateChain) []*core.RevocationSummary {
+	results, err := chain.CheckRevocation()
+	if err != nil {
+		fatal("%s", err)
+	}
+
+	summaries := []*core.RevocationSummary{}
+	for _, result := range results {
+		summaries = append(summaries, result.Summary())
 	}
+	return summaries
 }
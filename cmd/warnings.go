@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"github.com/cesarkawakami/chaintool/core"
+	"github.com/spf13/viper"
+)
+
+var (
+	warningThresholds map[string]int
+	warningSeverities map[string]string
+	suppressedLints   []string
+	failOn            string
+)
+
+// initWarningPolicy reads the "warnings" section of the config file, then
+// applies the flags on top of it:
+//
+//	warnings:
+//	  fail_on: error
+//	  thresholds:
+//	    expiring-soon: 30
+//	  severities:
+//	    internal-name-in-san: info
+//	  suppressions:
+//	    - id: obsolete-signature-algorithm
+//	      fingerprint: 9f86d081884c7d659a2feaa0c55ad015...
+//	      expires: 2027-01-31
+//	      reason: Legacy internal root, replaced in Q1
+func initWarningPolicy() {
+	policy := &core.WarningPolicy{}
+	if err := viper.UnmarshalKey("warnings", policy); err != nil {
+		fatal("Unable to read warning settings from the config file: %s", err)
+	}
+
+	if policy.Thresholds == nil {
+		policy.Thresholds = map[string]int{}
+	}
+	for id, threshold := range warningThresholds {
+		policy.Thresholds[id] = threshold
+	}
+	if policy.Severities == nil {
+		policy.Severities = map[string]string{}
+	}
+	for id, severity := range warningSeverities {
+		policy.Severities[id] = severity
+	}
+	for _, id := range suppressedLints {
+		policy.Suppressions = append(policy.Suppressions, core.WarningSuppression{ID: id})
+	}
+
+	if err := core.SetWarningPolicy(policy); err != nil {
+		fatal("%s", err)
+	}
+
+	if failOn == "" {
+		failOn = viper.GetString("warnings.fail_on")
+	}
+	if failOn != "" {
+		if _, err := core.ParseSeverity(failOn); err != nil {
+			fatal("Invalid --fail-on: %s", err)
+		}
+	}
+}
+
+// failOnWarnings exits with an error if --fail-on was given and any of the
+// warnings is at least that severe. Call it after writing the output.
+func failOnWarnings(warnings []core.Warning) {
+	if failOn == "" {
+		return
+	}
+	minimum, _ := core.ParseSeverity(failOn)
+
+	count := core.CountWarnings(warnings, minimum)
+	if count > 0 {
+		fatal("Failing: found %d warning(s) with severity %s or higher (--fail-on).", count, minimum)
+	}
+}
@@ -0,0 +1,245 @@
+package core
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The AIA cache keeps the issuer certificates fetched from AIA URLs, so that
+// building chains doesn't download the same intermediates on every run.
+// Certificates are stored once under certs/, named by the SHA-256 of their
+// DER encoding, and each URL gets an entry under urls/ pointing to one, with
+// the HTTP validators and expiry its response allowed.
+var (
+	aiaCacheDir    string
+	aiaCacheBypass bool
+	aiaOffline     bool
+)
+
+const (
+	// aiaCacheDefaultLifetime is how long responses without any caching
+	// headers are kept.
+	aiaCacheDefaultLifetime = 24 * time.Hour
+
+	// aiaCacheMaxHeuristicLifetime caps the lifetime guessed from
+	// Last-Modified.
+	aiaCacheMaxHeuristicLifetime = 7 * 24 * time.Hour
+)
+
+// DefaultAIACacheDir is the AIA cache under the user's cache directory, e.g.
+// ~/.cache/chaintool/aia on Linux.
+func DefaultAIACacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("Unable to locate the user cache directory: %s", err)
+	}
+	return filepath.Join(dir, "chaintool", "aia"), nil
+}
+
+// ConfigureAIACache sets where fetched issuers are cached; an empty dir
+// disables the cache. With bypass, cached certificates are ignored but
+// fetched ones are still stored. Offline, nothing is fetched, and cached
+// certificates are used even once stale.
+func ConfigureAIACache(dir string, bypass, offline bool) {
+	aiaCacheDir = dir
+	aiaCacheBypass = bypass
+	aiaOffline = offline
+}
+
+// ClearAIACache removes every cached certificate. Only the cache's own
+// subdirectories are removed, as the cache directory may be one the user
+// keeps other files in.
+func ClearAIACache() error {
+	if aiaCacheDir == "" {
+		return nil
+	}
+	for _, subdir := range []string{"urls", "certs"} {
+		if err := os.RemoveAll(filepath.Join(aiaCacheDir, subdir)); err != nil {
+			return fmt.Errorf("Unable to clear the AIA cache at %s: %s", aiaCacheDir, err)
+		}
+	}
+	return nil
+}
+
+type aiaCacheEntry struct {
+	URL          string    `json:"url"`
+	Certificate  string    `json:"certificate"`
+	ContentType  string    `json:"content_type,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+func aiaCacheHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func aiaCacheEntryPath(url string) string {
+	return filepath.Join(aiaCacheDir, "urls", aiaCacheHash([]byte(url))+".json")
+}
+
+func aiaCacheCertificatePath(hash string) string {
+	return filepath.Join(aiaCacheDir, "certs", hash+".der")
+}
+
+// lookupAIACache returns the entry for url and its certificate, or nils if
+// the cache is disabled or doesn't hold a usable copy.
+func lookupAIACache(url string) (*aiaCacheEntry, *x509.Certificate) {
+	if aiaCacheDir == "" || aiaCacheBypass {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(aiaCacheEntryPath(url))
+	if err != nil {
+		return nil, nil
+	}
+	entry := &aiaCacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil || entry.URL != url {
+		return nil, nil
+	}
+
+	der, err := ioutil.ReadFile(aiaCacheCertificatePath(entry.Certificate))
+	if err != nil || aiaCacheHash(der) != entry.Certificate {
+		return nil, nil
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil
+	}
+	return entry, cert
+}
+
+func (e *aiaCacheEntry) fresh() bool {
+	return time.Now().Before(e.ExpiresAt)
+}
+
+// storeAIACache records a fetched certificate, unless the response forbids
+// storing it. Failing to write the cache isn't worth failing the fetch for,
+// so errors are only warned about.
+func storeAIACache(url string, cert *x509.Certificate, header http.Header) {
+	if aiaCacheDir == "" {
+		return
+	}
+	expiresAt, storable := aiaCacheExpiry(header, time.Now())
+	if !storable {
+		return
+	}
+
+	entry := &aiaCacheEntry{
+		URL:          url,
+		Certificate:  aiaCacheHash(cert.Raw),
+		ContentType:  header.Get("Content-Type"),
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		FetchedAt:    time.Now().UTC(),
+		ExpiresAt:    expiresAt.UTC(),
+	}
+	if err := writeAIACacheFile(aiaCacheCertificatePath(entry.Certificate), cert.Raw); err != nil {
+		warning("Unable to cache %s: %s\n", url, err)
+		return
+	}
+	writeAIACacheEntry(entry)
+}
+
+// revalidated updates an entry after a 304 Not Modified response, whose
+// headers update the stored ones.
+func (e *aiaCacheEntry) revalidated(header http.Header) {
+	header = header.Clone()
+	if header.Get("Last-Modified") == "" && e.LastModified != "" {
+		header.Set("Last-Modified", e.LastModified)
+	}
+	expiresAt, storable := aiaCacheExpiry(header, time.Now())
+	if !storable {
+		return
+	}
+	e.ExpiresAt = expiresAt.UTC()
+	if etag := header.Get("ETag"); etag != "" {
+		e.ETag = etag
+	}
+	writeAIACacheEntry(e)
+}
+
+func writeAIACacheEntry(entry *aiaCacheEntry) {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err == nil {
+		err = writeAIACacheFile(aiaCacheEntryPath(entry.URL), data)
+	}
+	if err != nil {
+		warning("Unable to cache %s: %s\n", entry.URL, err)
+	}
+}
+
+// writeAIACacheFile writes through a temporary file, so concurrent runs
+// never read a partial file.
+func writeAIACacheFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// aiaCacheExpiry works out until when a response may be used without
+// revalidation, following RFC 9111: Cache-Control max-age first, then
+// Expires, then a tenth of the time since Last-Modified. Responses marked
+// no-store aren't storable, and no-cache ones must always be revalidated.
+func aiaCacheExpiry(header http.Header, now time.Time) (time.Time, bool) {
+	directives := map[string]string{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			parts := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+			name := strings.ToLower(parts[0])
+			if len(parts) == 2 {
+				directives[name] = strings.Trim(parts[1], `"`)
+			} else {
+				directives[name] = ""
+			}
+		}
+	}
+
+	if _, ok := directives["no-store"]; ok {
+		return time.Time{}, false
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return now, true
+	}
+	if maxAge, ok := directives["max-age"]; ok {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return now, true
+		}
+		age, _ := strconv.Atoi(header.Get("Age"))
+		return now.Add(time.Duration(seconds-age) * time.Second), true
+	}
+
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		date = now
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return now, true
+		}
+		return now.Add(expiresAt.Sub(date)), true
+	}
+	if lastModified, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		lifetime := date.Sub(lastModified) / 10
+		if lifetime > aiaCacheMaxHeuristicLifetime {
+			lifetime = aiaCacheMaxHeuristicLifetime
+		}
+		return now.Add(lifetime), true
+	}
+	return now.Add(aiaCacheDefaultLifetime), true
+}
@@ -0,0 +1,213 @@
+package core
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClearAIACache(t *testing.T) {
+	defer ConfigureAIACache(aiaCacheDir, aiaCacheBypass, aiaOffline)
+
+	// The cache may be pointed at a directory holding other files.
+	dir := t.TempDir()
+	ConfigureAIACache(dir, false, false)
+	if err := ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "other"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := newTestCertificate("Test Root", true, nil, testCertificateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const url = "http://aia.test/root.crt"
+	storeAIACache(url, root.Certificate, http.Header{"Cache-Control": {"max-age=3600"}})
+	if _, cached := lookupAIACache(url); cached == nil {
+		t.Fatal("the certificate wasn't cached")
+	}
+
+	if err := ClearAIACache(); err != nil {
+		t.Fatal(err)
+	}
+	if _, cached := lookupAIACache(url); cached != nil {
+		t.Error("the certificate is still cached")
+	}
+	for _, name := range []string{"urls", "certs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s: got %v, want it removed", name, err)
+		}
+	}
+	for _, name := range []string{"", "notes.txt", "other"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s wasn't kept: %s", name, err)
+		}
+	}
+
+	// Clearing an empty or disabled cache is fine.
+	if err := ClearAIACache(); err != nil {
+		t.Error(err)
+	}
+	ConfigureAIACache("", false, false)
+	if err := ClearAIACache(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAIACacheExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	httpTime := func(t time.Time) string { return t.Format(http.TimeFormat) }
+	tests := []struct {
+		name     string
+		header   http.Header
+		want     time.Time
+		storable bool
+	}{
+		{"no headers", http.Header{}, now.Add(aiaCacheDefaultLifetime), true},
+		{"no-store", http.Header{"Cache-Control": {"public, no-store"}}, time.Time{}, false},
+		{"no-cache", http.Header{"Cache-Control": {"no-cache"}}, now, true},
+		{"max-age", http.Header{"Cache-Control": {"max-age=3600"}}, now.Add(time.Hour), true},
+		{"max-age and age", http.Header{"Cache-Control": {"max-age=3600"}, "Age": {"600"}}, now.Add(50 * time.Minute), true},
+		{"max-age over expires", http.Header{
+			"Cache-Control": {"max-age=60"},
+			"Expires":       {httpTime(now.Add(time.Hour))},
+		}, now.Add(time.Minute), true},
+		{"expires", http.Header{
+			"Date":    {httpTime(now.Add(-time.Hour))},
+			"Expires": {httpTime(now.Add(time.Hour))},
+		}, now.Add(2 * time.Hour), true},
+		{"bad expires", http.Header{"Expires": {"0"}}, now, true},
+		{"last-modified", http.Header{
+			"Date":          {httpTime(now)},
+			"Last-Modified": {httpTime(now.Add(-10 * time.Hour))},
+		}, now.Add(time.Hour), true},
+		{"old last-modified", http.Header{
+			"Date":          {httpTime(now)},
+			"Last-Modified": {httpTime(now.AddDate(-1, 0, 0))},
+		}, now.Add(aiaCacheMaxHeuristicLifetime), true},
+	}
+	for _, test := range tests {
+		got, storable := aiaCacheExpiry(test.header, now)
+		if !got.Equal(test.want) || storable != test.storable {
+			t.Errorf("%s: got %s, %v, want %s, %v", test.name, got, storable, test.want, test.storable)
+		}
+	}
+}
+
+func TestFetchCertificateCache(t *testing.T) {
+	defer ConfigureAIACache(aiaCacheDir, aiaCacheBypass, aiaOffline)
+	dir := t.TempDir()
+	ConfigureAIACache(dir, false, false)
+
+	root, err := newTestCertificate("Test Root", true, nil, testCertificateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each path answers with its own caching headers, and 304 when the
+	// request's If-None-Match matches its ETag.
+	headers := map[string]http.Header{
+		"/fresh":   {"Cache-Control": {"max-age=3600"}},
+		"/stale":   {"Cache-Control": {"max-age=0"}, "Etag": {`"v1"`}},
+		"/nostore": {"Cache-Control": {"no-store"}},
+	}
+	var mu sync.Mutex
+	requests := map[string][]*http.Request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path] = append(requests[r.URL.Path], r)
+		mu.Unlock()
+
+		header, ok := headers[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		if etag := header.Get("ETag"); etag != "" && r.Header.Get("If-None-Match") == etag {
+			w.Header().Set("Cache-Control", "max-age=3600")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pkix-cert")
+		w.Write(root.Certificate.Raw)
+	}))
+	defer server.Close()
+
+	fetch := func(path string, wantRequests int) *certificateFetch {
+		t.Helper()
+		cert, fetch, err := fetchCertificate(server.URL + path)
+		if err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		if !cert.Equal(root.Certificate) {
+			t.Errorf("%s: got %s", path, cert.Subject)
+		}
+		if got := len(requests[path]); got != wantRequests {
+			t.Errorf("%s: got %d requests, want %d", path, got, wantRequests)
+		}
+		return fetch
+	}
+
+	// A fresh entry is used without asking the server.
+	if f := fetch("/fresh", 1); f.Status != http.StatusOK || f.Cached {
+		t.Errorf("/fresh: got %+v on the first fetch", f)
+	}
+	if f := fetch("/fresh", 1); !f.Cached || f.Status != 0 || f.ContentType != "application/pkix-cert" {
+		t.Errorf("/fresh: got %+v, want a cache hit", f)
+	}
+
+	// A stale one is revalidated, and a 304 extends it.
+	fetch("/stale", 1)
+	entry, _ := lookupAIACache(server.URL + "/stale")
+	if entry == nil || entry.fresh() {
+		t.Fatalf("/stale: got entry %+v, want a stale one", entry)
+	}
+	if f := fetch("/stale", 2); !f.Cached || f.Status != http.StatusNotModified {
+		t.Errorf("/stale: got %+v, want a revalidated copy", f)
+	}
+	if got := requests["/stale"][1].Header.Get("If-None-Match"); got != `"v1"` {
+		t.Errorf("/stale: got If-None-Match %q", got)
+	}
+	entry, _ = lookupAIACache(server.URL + "/stale")
+	if entry == nil || entry.ExpiresAt.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("/stale: got entry %+v, want it to expire in an hour", entry)
+	}
+	fetch("/stale", 2)
+
+	// no-store responses aren't kept.
+	fetch("/nostore", 1)
+	fetch("/nostore", 2)
+	if _, err := os.Stat(aiaCacheEntryPath(server.URL + "/nostore")); !os.IsNotExist(err) {
+		t.Errorf("/nostore: got %v, want no cache entry", err)
+	}
+
+	// Offline, stale entries are used as they are, and misses fail. Going
+	// around the cache stores the stale copy again.
+	ConfigureAIACache(dir, true, false)
+	fetch("/stale", 3)
+	ConfigureAIACache(dir, false, true)
+	if entry, _ := lookupAIACache(server.URL + "/stale"); entry == nil || entry.fresh() {
+		t.Fatalf("/stale: got entry %+v, want a stale one", entry)
+	}
+	if f := fetch("/stale", 3); !f.Cached {
+		t.Errorf("/stale: got %+v offline, want the cached copy", f)
+	}
+	if _, _, err := fetchCertificate(server.URL + "/missing"); err == nil ||
+		!strings.Contains(err.Error(), "not cached, and running offline") {
+		t.Errorf("/missing: got error %v", err)
+	}
+	if got := len(requests["/missing"]); got != 0 {
+		t.Errorf("/missing: got %d requests offline", got)
+	}
+}
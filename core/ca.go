@@ -0,0 +1,447 @@
+package core
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	caConfigFile       = "ca.yaml"
+	caRootCertFile     = "root.crt"
+	caRootKeyFile      = "root.key"
+	caIntermediateCert = "intermediate.crt"
+	caIntermediateKey  = "intermediate.key"
+	caIssuedDir        = "issued"
+	caIndexFile        = "index.yaml"
+	caCRLFile          = "intermediate.crl"
+	caLockFile         = "ca.lock"
+)
+
+type LocalCAConfig struct {
+	Name                     string   `yaml:"name"`
+	RootKey                  KeySpec  `yaml:"root_key"`
+	IntermediateKey          KeySpec  `yaml:"intermediate_key"`
+	RootValidityDays         int      `yaml:"root_validity_days"`
+	IntermediateValidityDays int      `yaml:"intermediate_validity_days"`
+	LeafValidityDays         int      `yaml:"leaf_validity_days"`
+	PermittedDNSDomains      []string `yaml:"permitted_dns_domains"`
+	ExcludedDNSDomains       []string `yaml:"excluded_dns_domains"`
+
+	// BaseURL is where ca:serve is reachable. Issued certificates point
+	// there for OCSP, CRLs and the issuer certificate.
+	BaseURL string `yaml:"base_url"`
+}
+
+func (c *LocalCAConfig) setDefaults() {
+	if c.Name == "" {
+		c.Name = "chaintool Local CA"
+	}
+	if c.RootKey.Type == "" {
+		c.RootKey.Type = KeyTypeECDSA
+	}
+	if c.IntermediateKey.Type == "" {
+		c.IntermediateKey.Type = KeyTypeECDSA
+	}
+	if c.RootValidityDays == 0 {
+		c.RootValidityDays = 3650
+	}
+	if c.IntermediateValidityDays == 0 {
+		c.IntermediateValidityDays = 1825
+	}
+	if c.LeafValidityDays == 0 {
+		c.LeafValidityDays = 90
+	}
+}
+
+func (c *LocalCAConfig) url(path string) string {
+	return strings.TrimSuffix(c.BaseURL, "/") + path
+}
+
+// LocalCA is a two-level CA (root and intermediate) stored in a directory,
+// meant for staging and test environments.
+type LocalCA struct {
+	Dir          string
+	Config       LocalCAConfig
+	Root         *Certificate
+	Intermediate *Certificate
+
+	// mu serializes access to the database and the CRL, which ca:serve
+	// reads and rewrites from concurrent requests. Other processes, such
+	// as ca:issue and ca:revoke, are kept out by the lock file (see lock).
+	mu sync.Mutex
+}
+
+func InitLocalCA(dir string, config LocalCAConfig) (*LocalCA, error) {
+	config.setDefaults()
+
+	if _, err := os.Stat(filepath.Join(dir, caConfigFile)); err == nil {
+		return nil, fmt.Errorf("A CA already exists in %s", dir)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, caIssuedDir), 0700); err != nil {
+		return nil, fmt.Errorf("Unable to create CA directory: %s", err)
+	}
+
+	rootKey, err := GeneratePrivateKey(config.RootKey)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to generate root key: %s", err)
+	}
+	intermediateKey, err := GeneratePrivateKey(config.IntermediateKey)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to generate intermediate key: %s", err)
+	}
+
+	now := time.Now()
+
+	rootTemplate := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:   config.Name + " Root",
+			Organization: []string{config.Name},
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(0, 0, config.RootValidityDays),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            1,
+	}
+	root, err := createCACertificate(rootTemplate, nil, rootKey, rootKey)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create root certificate: %s", err)
+	}
+
+	intermediateTemplate := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:   config.Name + " Intermediate",
+			Organization: []string{config.Name},
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(0, 0, config.IntermediateValidityDays),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		PermittedDNSDomains:   config.PermittedDNSDomains,
+		ExcludedDNSDomains:    config.ExcludedDNSDomains,
+	}
+	if config.BaseURL != "" {
+		intermediateTemplate.IssuingCertificateURL = []string{config.url("/root.crt")}
+	}
+	intermediate, err := createCACertificate(intermediateTemplate, root, intermediateKey, rootKey)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create intermediate certificate: %s", err)
+	}
+
+	ca := &LocalCA{
+		Dir:          dir,
+		Config:       config,
+		Root:         root,
+		Intermediate: intermediate,
+	}
+	if err := ca.save(); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+func LoadLocalCA(dir string) (*LocalCA, error) {
+	configData, err := ioutil.ReadFile(filepath.Join(dir, caConfigFile))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read CA configuration (is %s a CA directory?): %s", dir, err)
+	}
+
+	ca := &LocalCA{Dir: dir}
+	if err := yaml.Unmarshal(configData, &ca.Config); err != nil {
+		return nil, fmt.Errorf("Unable to parse CA configuration: %s", err)
+	}
+	ca.Config.setDefaults()
+
+	ca.Root, err = CertificateWithKeyFromFiles(
+		filepath.Join(dir, caRootCertFile), filepath.Join(dir, caRootKeyFile))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load root certificate: %s", err)
+	}
+	ca.Intermediate, err = CertificateWithKeyFromFiles(
+		filepath.Join(dir, caIntermediateCert), filepath.Join(dir, caIntermediateKey))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load intermediate certificate: %s", err)
+	}
+
+	return ca, nil
+}
+
+func (ca *LocalCA) save() error {
+	configData, err := yaml.Marshal(ca.Config)
+	if err != nil {
+		return err
+	}
+
+	rootKey, err := ca.Root.PrivateKeyToPEM()
+	if err != nil {
+		return err
+	}
+	intermediateKey, err := ca.Intermediate.PrivateKeyToPEM()
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		name string
+		data []byte
+		perm os.FileMode
+	}{
+		{caRootKeyFile, rootKey, 0600},
+		{caIntermediateKey, intermediateKey, 0600},
+		{caRootCertFile, ca.Root.CertificateToPEM(), 0644},
+		{caIntermediateCert, ca.Intermediate.CertificateToPEM(), 0644},
+		{caConfigFile, configData, 0644},
+	}
+	for _, file := range files {
+		if err := writeFileWithPerm(filepath.Join(ca.Dir, file.name), file.data, file.perm); err != nil {
+			return fmt.Errorf("Unable to write %s: %s", file.name, err)
+		}
+	}
+	return nil
+}
+
+func (ca *LocalCA) RootPath() string {
+	return filepath.Join(ca.Dir, caRootCertFile)
+}
+
+func (ca *LocalCA) CRLPath() string {
+	return filepath.Join(ca.Dir, caCRLFile)
+}
+
+// IssueFromRequest issues a leaf certificate for the subject, names and key
+// in the given request.
+func (ca *LocalCA) IssueFromRequest(request *CertificateRequest, validityDays int) (*Certificate, error) {
+	if err := request.CheckSignature(); err != nil {
+		return nil, err
+	}
+
+	csr := request.Request
+	if err := ca.checkNameConstraints(csr.DNSNames); err != nil {
+		return nil, err
+	}
+	if validityDays == 0 {
+		validityDays = ca.Config.LeafValidityDays
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		Subject:               csr.Subject,
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(0, 0, validityDays),
+		KeyUsage:              leafKeyUsage(csr.PublicKey),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              csr.DNSNames,
+		IPAddresses:           csr.IPAddresses,
+		EmailAddresses:        csr.EmailAddresses,
+		URIs:                  csr.URIs,
+		SubjectKeyId:          computedKeyID(csr.RawSubjectPublicKeyInfo),
+	}
+	if ca.Config.BaseURL != "" {
+		template.OCSPServer = []string{ca.Config.url("/ocsp")}
+		template.IssuingCertificateURL = []string{ca.Config.url("/intermediate.crt")}
+		template.CRLDistributionPoints = []string{ca.Config.url("/intermediate.crl")}
+	}
+
+	leaf, err := createCertificate(template, ca.Intermediate, csr.PublicKey, ca.Intermediate.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to issue certificate: %s", err)
+	}
+
+	if err := ca.recordIssued(leaf); err != nil {
+		return nil, err
+	}
+	return leaf, nil
+}
+
+// IssueFromTemplate generates a new key and issues a leaf certificate for it.
+func (ca *LocalCA) IssueFromTemplate(
+	template *CertificateRequestTemplate,
+	validityDays int,
+) (*Certificate, error) {
+	if template.Key.Type == "" {
+		template.Key.Type = KeyTypeRSA
+	}
+	privateKey, err := GeneratePrivateKey(template.Key)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to generate private key: %s", err)
+	}
+
+	request, err := CreateCertificateRequest(template, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, err := ca.IssueFromRequest(request, validityDays)
+	if err != nil {
+		return nil, err
+	}
+	leaf.PrivateKey = privateKey
+	return leaf, nil
+}
+
+func (ca *LocalCA) Chain(leaf *Certificate) *CertificateChain {
+	return &CertificateChain{
+		Leaf:          leaf,
+		Intermediates: []*Certificate{ca.Intermediate},
+	}
+}
+
+// checkNameConstraints refuses names the intermediate isn't allowed to sign
+// for, since the resulting certificate would never verify.
+func (ca *LocalCA) checkNameConstraints(dnsNames []string) error {
+	intermediate := ca.Intermediate.Certificate
+	for _, name := range dnsNames {
+		for _, domain := range intermediate.ExcludedDNSDomains {
+			if dnsNameInDomain(name, domain) {
+				return fmt.Errorf("%s is excluded by the CA's name constraints", name)
+			}
+		}
+
+		permitted := len(intermediate.PermittedDNSDomains) == 0
+		for _, domain := range intermediate.PermittedDNSDomains {
+			if dnsNameInDomain(name, domain) {
+				permitted = true
+			}
+		}
+		if !permitted {
+			return fmt.Errorf(
+				"%s isn't permitted by the CA's name constraints (%s)",
+				name, strings.Join(intermediate.PermittedDNSDomains, ", "))
+		}
+	}
+	return nil
+}
+
+// dnsNameInDomain follows RFC 5280: "example.com" covers itself and its
+// subdomains, ".example.com" only its subdomains.
+func dnsNameInDomain(name, domain string) bool {
+	name, domain = strings.ToLower(name), strings.ToLower(domain)
+	if strings.HasPrefix(domain, ".") {
+		return strings.HasSuffix(name, domain)
+	}
+	return name == domain || strings.HasSuffix(name, "."+domain)
+}
+
+// Key encipherment only makes sense for RSA key exchange.
+func leafKeyUsage(publicKey crypto.PublicKey) x509.KeyUsage {
+	if _, ok := publicKey.(*rsa.PublicKey); ok {
+		return x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	}
+	return x509.KeyUsageDigitalSignature
+}
+
+func randomSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+}
+
+// createCertificate signs template with the issuer's key. A nil issuer means
+// the certificate is self-signed.
+func createCertificate(
+	template *x509.Certificate,
+	issuer *Certificate,
+	publicKey crypto.PublicKey,
+	issuerKey crypto.PrivateKey,
+) (*Certificate, error) {
+	if template.SerialNumber == nil {
+		serial, err := randomSerialNumber()
+		if err != nil {
+			return nil, err
+		}
+		template.SerialNumber = serial
+	}
+
+	parent := template
+	if issuer != nil {
+		parent = issuer.Certificate
+	}
+
+	signer, ok := issuerKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("Unknown private key type: %T", issuerKey)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, publicKey, signer)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &Certificate{Certificate: cert}, nil
+}
+
+// createCACertificate is createCertificate for a freshly generated CA key,
+// which is kept alongside the resulting certificate.
+func createCACertificate(
+	template *x509.Certificate,
+	issuer *Certificate,
+	privateKey crypto.PrivateKey,
+	issuerKey crypto.PrivateKey,
+) (*Certificate, error) {
+	publicKey, err := publicKeyFromPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := createCertificate(template, issuer, publicKey, issuerKey)
+	if err != nil {
+		return nil, err
+	}
+	cert.PrivateKey = privateKey
+	return cert, nil
+}
+
+func writeFileWithPerm(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := f.Chmod(perm); err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// writeFileAtomic writes to a temporary file next to path and renames it
+// into place, so that readers never see a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDNSNameInDomain(t *testing.T) {
+	tests := []struct {
+		name   string
+		domain string
+		want   bool
+	}{
+		{"example.test", "example.test", true},
+		{"www.example.test", "example.test", true},
+		{"WWW.Example.TEST", "example.test", true},
+		{"badexample.test", "example.test", false},
+		{"example.test", ".example.test", false},
+		{"a.b.example.test", ".example.test", true},
+		{"example.org", "example.test", false},
+	}
+	for _, test := range tests {
+		if got := dnsNameInDomain(test.name, test.domain); got != test.want {
+			t.Errorf("%s in %s: got %v, want %v", test.name, test.domain, got, test.want)
+		}
+	}
+}
+
+func TestLocalCA(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := InitLocalCA(dir, LocalCAConfig{
+		Name:                "Test",
+		PermittedDNSDomains: []string{"example.test"},
+		ExcludedDNSDomains:  []string{"secret.example.test"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InitLocalCA(dir, LocalCAConfig{}); err == nil {
+		t.Error("InitLocalCA overwrote an existing CA")
+	}
+	for _, name := range []string{caRootKeyFile, caIntermediateKey} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("%s: got permissions %o, want 600", name, perm)
+		}
+	}
+
+	loaded, err := LoadLocalCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Root.ID() != ca.Root.ID() || loaded.Intermediate.ID() != ca.Intermediate.ID() {
+		t.Error("LoadLocalCA returned different certificates")
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(loaded.Root.Certificate)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(loaded.Intermediate.Certificate)
+
+	tests := []struct {
+		names []string
+		err   string
+	}{
+		{names: []string{"www.example.test", "example.test"}},
+		{names: []string{"www.example.org"}, err: "www.example.org isn't permitted"},
+		{names: []string{"www.example.test", "db.secret.example.test"}, err: "db.secret.example.test is excluded"},
+	}
+	for _, test := range tests {
+		leaf, err := loaded.IssueFromTemplate(&CertificateRequestTemplate{
+			SANs: test.names,
+			Key:  KeySpec{Type: KeyTypeECDSA},
+		}, 0)
+		if test.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+				t.Errorf("%v: got error %v, want %q", test.names, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %s", test.names, err)
+			continue
+		}
+		for _, name := range test.names {
+			if _, err := leaf.Certificate.Verify(x509.VerifyOptions{
+				DNSName:       name,
+				Roots:         roots,
+				Intermediates: intermediates,
+			}); err != nil {
+				t.Errorf("%s: %s", name, err)
+			}
+		}
+		if err := leaf.EnsureCertificateAndKeyMatch(); err != nil {
+			t.Errorf("%v: %s", test.names, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// IssuedRecord is an entry in the local CA's serial database.
+type IssuedRecord struct {
+	Serial           string     `yaml:"serial"`
+	Subject          string     `yaml:"subject"`
+	NotAfter         time.Time  `yaml:"not_after"`
+	RevokedAt        *time.Time `yaml:"revoked_at,omitempty"`
+	RevocationReason int        `yaml:"revocation_reason,omitempty"`
+}
+
+func (r *IssuedRecord) IsRevoked() bool {
+	return r.RevokedAt != nil
+}
+
+type caIndex struct {
+	CRLNumber int64           `yaml:"crl_number"`
+	Issued    []*IssuedRecord `yaml:"issued"`
+}
+
+func (index *caIndex) find(serial *big.Int) *IssuedRecord {
+	for _, record := range index.Issued {
+		if record.Serial == serial.Text(16) {
+			return record
+		}
+	}
+	return nil
+}
+
+// CRL reason codes from RFC 5280, section 5.3.1.
+var revocationReasons = map[string]int{
+	"unspecified":          0,
+	"keyCompromise":        1,
+	"cACompromise":         2,
+	"affiliationChanged":   3,
+	"superseded":           4,
+	"cessationOfOperation": 5,
+	"certificateHold":      6,
+	"privilegeWithdrawn":   9,
+	"aACompromise":         10,
+}
+
+func ParseRevocationReason(name string) (int, error) {
+	for reasonName, code := range revocationReasons {
+		if strings.EqualFold(reasonName, name) {
+			return code, nil
+		}
+	}
+
+	names := []string{}
+	for reasonName := range revocationReasons {
+		names = append(names, reasonName)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf(
+		"Unknown revocation reason '%s', expected one of %s", name, strings.Join(names, ", "))
+}
+
+func RevocationReasonName(code int) string {
+	for name, reasonCode := range revocationReasons {
+		if reasonCode == code {
+			return name
+		}
+	}
+	return fmt.Sprintf("unknown reason %d", code)
+}
+
+// ParseSerialNumber accepts serials in hex, with or without colons, as
+// shown by chaintool and OpenSSL.
+func ParseSerialNumber(s string) (*big.Int, error) {
+	serial, ok := new(big.Int).SetString(strings.Replace(s, ":", "", -1), 16)
+	if !ok {
+		return nil, fmt.Errorf("Invalid serial number '%s'", s)
+	}
+	return serial, nil
+}
+
+// lock takes the CA's in-process lock and its lock file, so that reading,
+// changing and saving the database is atomic across ca:issue, ca:revoke and
+// ca:serve. It returns the function releasing both.
+func (ca *LocalCA) lock() (func(), error) {
+	ca.mu.Lock()
+	f, err := os.OpenFile(filepath.Join(ca.Dir, caLockFile), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		ca.mu.Unlock()
+		return nil, fmt.Errorf("Unable to lock CA database: %s", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		ca.mu.Unlock()
+		return nil, fmt.Errorf("Unable to lock CA database: %s", err)
+	}
+
+	return func() {
+		// Closing the file releases the lock.
+		f.Close()
+		ca.mu.Unlock()
+	}, nil
+}
+
+func (ca *LocalCA) loadIndex() (*caIndex, error) {
+	index := &caIndex{}
+	data, err := ioutil.ReadFile(filepath.Join(ca.Dir, caIndexFile))
+	if os.IsNotExist(err) {
+		return index, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Unable to read CA database: %s", err)
+	}
+
+	if err := yaml.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("Unable to parse CA database: %s", err)
+	}
+	return index, nil
+}
+
+func (ca *LocalCA) saveIndex(index *caIndex) error {
+	data, err := yaml.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(ca.Dir, caIndexFile), data, 0644); err != nil {
+		return fmt.Errorf("Unable to write CA database: %s", err)
+	}
+	return nil
+}
+
+func (ca *LocalCA) recordIssued(cert *Certificate) error {
+	serial := cert.Certificate.SerialNumber.Text(16)
+	path := filepath.Join(ca.Dir, caIssuedDir, serial+".crt")
+	if err := writeFileWithPerm(path, cert.CertificateToPEM(), 0644); err != nil {
+		return fmt.Errorf("Unable to record issued certificate: %s", err)
+	}
+
+	unlock, err := ca.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	index, err := ca.loadIndex()
+	if err != nil {
+		return err
+	}
+	index.Issued = append(index.Issued, &IssuedRecord{
+		Serial:   serial,
+		Subject:  cert.Certificate.Subject.CommonName,
+		NotAfter: cert.Certificate.NotAfter,
+	})
+	return ca.saveIndex(index)
+}
+
+func (ca *LocalCA) IssuedCertificates() ([]*IssuedRecord, error) {
+	unlock, err := ca.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	index, err := ca.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	return index.Issued, nil
+}
+
+// Revoke marks a certificate issued by this CA as revoked and regenerates
+// the CRL.
+func (ca *LocalCA) Revoke(serial *big.Int, reason int) (*IssuedRecord, error) {
+	unlock, err := ca.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	index, err := ca.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	record := index.find(serial)
+	if record == nil {
+		return nil, fmt.Errorf("No certificate with serial %s was issued by this CA", serial.Text(16))
+	}
+	if record.IsRevoked() {
+		return nil, fmt.Errorf(
+			"Certificate %s was already revoked at %s", record.Serial, record.RevokedAt)
+	}
+
+	now := time.Now().UTC()
+	record.RevokedAt = &now
+	record.RevocationReason = reason
+	if err := ca.saveIndex(index); err != nil {
+		return nil, err
+	}
+
+	if err := ca.writeCRL(); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
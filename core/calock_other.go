@@ -0,0 +1,12 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package core
+
+import "os"
+
+// lockFile does nothing where file locks aren't available, leaving only the
+// in-process lock.
+func lockFile(f *os.File) error {
+	return nil
+}
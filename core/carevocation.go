@@ -0,0 +1,200 @@
+package core
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	crlValidity  = 7 * 24 * time.Hour
+	ocspValidity = 24 * time.Hour
+)
+
+// CreateCRL signs a fresh CRL listing every revoked certificate that hasn't
+// expired yet, bumping the CRL number.
+func (ca *LocalCA) CreateCRL() ([]byte, error) {
+	unlock, err := ca.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return ca.createCRL()
+}
+
+func (ca *LocalCA) createCRL() ([]byte, error) {
+	index, err := ca.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entries := []x509.RevocationListEntry{}
+	for _, record := range index.Issued {
+		if !record.IsRevoked() || record.NotAfter.Before(now) {
+			continue
+		}
+		serial, err := ParseSerialNumber(record.Serial)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: *record.RevokedAt,
+			ReasonCode:     record.RevocationReason,
+		})
+	}
+
+	index.CRLNumber++
+	template := &x509.RevocationList{
+		Number:                    big.NewInt(index.CRLNumber),
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(crlValidity),
+		RevokedCertificateEntries: entries,
+	}
+
+	signer, ok := ca.Intermediate.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("Unknown private key type: %T", ca.Intermediate.PrivateKey)
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, template, ca.Intermediate.Certificate, signer)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create CRL: %s", err)
+	}
+
+	if err := ca.saveIndex(index); err != nil {
+		return nil, err
+	}
+	return crl, nil
+}
+
+// WriteCRL creates a CRL and stores it in the CA directory.
+func (ca *LocalCA) WriteCRL() error {
+	unlock, err := ca.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return ca.writeCRL()
+}
+
+func (ca *LocalCA) writeCRL() error {
+	crl, err := ca.createCRL()
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(ca.CRLPath(), crl, 0644); err != nil {
+		return fmt.Errorf("Unable to write CRL: %s", err)
+	}
+	return nil
+}
+
+// OCSPResponse answers a DER-encoded OCSP request for certificates issued by
+// the intermediate. Malformed requests yield an error, which callers should
+// answer with ocsp.MalformedRequestErrorResponse.
+func (ca *LocalCA) OCSPResponse(requestDER []byte) ([]byte, error) {
+	request, err := ocsp.ParseRequest(requestDER)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid OCSP request: %s", err)
+	}
+
+	issuerKeyHash, err := publicKeyHash(ca.Intermediate.Certificate, request.HashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(issuerKeyHash, request.IssuerKeyHash) {
+		return ocsp.UnauthorizedErrorResponse, nil
+	}
+
+	unlock, err := ca.lock()
+	if err != nil {
+		return nil, err
+	}
+	index, err := ca.loadIndex()
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := ocsp.Response{
+		SerialNumber: request.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(ocspValidity),
+	}
+	switch record := index.find(request.SerialNumber); {
+	case record == nil:
+		template.Status = ocsp.Unknown
+	case record.IsRevoked():
+		template.Status = ocsp.Revoked
+		template.RevokedAt = *record.RevokedAt
+		template.RevocationReason = record.RevocationReason
+	default:
+		template.Status = ocsp.Good
+	}
+
+	signer, ok := ca.Intermediate.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("Unknown private key type: %T", ca.Intermediate.PrivateKey)
+	}
+	response, err := ocsp.CreateResponse(
+		ca.Intermediate.Certificate, ca.Intermediate.Certificate, template, signer)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to sign OCSP response: %s", err)
+	}
+	return response, nil
+}
+
+// publicKeyHash is the issuer key hash used in OCSP certificate IDs: the hash
+// of the subject public key bits, without the algorithm identifier.
+func publicKeyHash(cert *x509.Certificate, hash crypto.Hash) ([]byte, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, err
+	}
+	if !hash.Available() {
+		return nil, fmt.Errorf("Unsupported OCSP hash algorithm")
+	}
+
+	h := hash.New()
+	h.Write(spki.PublicKey.RightAlign())
+	return h.Sum(nil), nil
+}
+
+// CurrentCRL returns the stored CRL, regenerating it when the database
+// changed since it was written or when it's halfway through its validity.
+func (ca *LocalCA) CurrentCRL() ([]byte, error) {
+	unlock, err := ca.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	crlInfo, err := os.Stat(ca.CRLPath())
+	if err == nil {
+		indexInfo, indexErr := os.Stat(filepath.Join(ca.Dir, caIndexFile))
+		indexChanged := indexErr == nil && indexInfo.ModTime().After(crlInfo.ModTime())
+		if !indexChanged && time.Since(crlInfo.ModTime()) < crlValidity/2 {
+			return ioutil.ReadFile(ca.CRLPath())
+		}
+	}
+
+	if err := ca.writeCRL(); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(ca.CRLPath())
+}
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"math/big"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// TestLocalCAConcurrentRevocation mimics ca:serve answering CRL and OCSP
+// requests while certificates are being revoked.
+func TestLocalCAConcurrentRevocation(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := InitLocalCA(dir, LocalCAConfig{Name: "Test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaves := []*Certificate{}
+	for i := 0; i < 32; i++ {
+		leaf, err := ca.IssueFromTemplate(&CertificateRequestTemplate{
+			SANs: []string{"www.example.test"},
+			Key:  KeySpec{Type: KeyTypeECDSA},
+		}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*len(leaves))
+	for _, leaf := range leaves {
+		leaf := leaf
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if _, err := ca.Revoke(leaf.Certificate.SerialNumber, 1); err != nil {
+				errs <- err
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			crl, err := ca.CurrentCRL()
+			if err == nil {
+				_, err = x509.ParseRevocationList(crl)
+			}
+			if err != nil {
+				errs <- err
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			request, err := ocsp.CreateRequest(leaf.Certificate, ca.Intermediate.Certificate, nil)
+			if err == nil {
+				_, err = ca.OCSPResponse(request)
+			}
+			if err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	crlData, err := ca.CurrentCRL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := x509.ParseRevocationList(crlData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked := map[string]bool{}
+	for _, entry := range crl.RevokedCertificateEntries {
+		revoked[entry.SerialNumber.String()] = true
+	}
+	for _, leaf := range leaves {
+		if !revoked[leaf.Certificate.SerialNumber.String()] {
+			t.Errorf("%s is missing from the CRL", leaf.Certificate.SerialNumber)
+		}
+	}
+	if crl.Number.Cmp(big.NewInt(int64(len(leaves)))) < 0 {
+		t.Errorf("got CRL number %s, want at least %d", crl.Number, len(leaves))
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if file.Name()[0] == '.' {
+			t.Errorf("temporary file %s was left behind", file.Name())
+		}
+	}
+}
+
+// TestLocalCAConcurrentProcesses mimics ca:issue, ca:revoke and ca:serve
+// running at once: each LocalCA loaded from the directory has its own
+// in-process lock, so only the lock file keeps their updates apart.
+func TestLocalCAConcurrentProcesses(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := InitLocalCA(dir, LocalCAConfig{Name: "Test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	load := func() *LocalCA {
+		ca, err := LoadLocalCA(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ca
+	}
+
+	revoked := []*Certificate{}
+	for i := 0; i < 16; i++ {
+		leaf, err := ca.IssueFromTemplate(&CertificateRequestTemplate{
+			SANs: []string{"www.example.test"},
+			Key:  KeySpec{Type: KeyTypeECDSA},
+		}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		revoked = append(revoked, leaf)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*len(revoked))
+	for _, leaf := range revoked {
+		leaf := leaf
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if _, err := load().Revoke(leaf.Certificate.SerialNumber, 1); err != nil {
+				errs <- err
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := load().IssueFromTemplate(&CertificateRequestTemplate{
+				SANs: []string{"www.example.test"},
+				Key:  KeySpec{Type: KeyTypeECDSA},
+			}, 0); err != nil {
+				errs <- err
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := load().CreateCRL(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	index, err := ca.loadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Issued) != 2*len(revoked) {
+		t.Errorf("got %d issued certificates, want %d", len(index.Issued), 2*len(revoked))
+	}
+	for _, leaf := range revoked {
+		if record := index.find(leaf.Certificate.SerialNumber); record == nil || !record.IsRevoked() {
+			t.Errorf("%s wasn't recorded as revoked", leaf.Certificate.SerialNumber)
+		}
+	}
+	// Each revocation and CreateCRL call bumps the CRL number once.
+	if index.CRLNumber != int64(2*len(revoked)) {
+		t.Errorf("got CRL number %d, want %d", index.CRLNumber, 2*len(revoked))
+	}
+}
@@ -0,0 +1,566 @@
+package core
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// caWarningTriers check the intermediate at the given index of a chain,
+// together with the certificates next to it.
+var caWarningTriers = []func(chain *CertificateChain, index int) Warning{
+	TryNotCAWarning,
+	TryOutOfOrderWarning,
+	TryRootInChainWarning,
+	TryPathLengthExceededWarning,
+	TryMissingCertSignWarning,
+	TryKeyIDMismatchWarning,
+	TryIncompatibleEKUWarning,
+	TrySignatureKeyMismatchWarning,
+	TryDistrustedIssuerWarning,
+}
+
+func (c *CertificateChain) intermediateWarnings(index int) []Warning {
+	rv := c.Intermediates[index].lintWarnings()
+	for _, trier := range caWarningTriers {
+		w := trier(c, index)
+		if w != nil {
+			rv = append(rv, w)
+		}
+	}
+	return warningPolicy.apply(c.Intermediates[index], rv)
+}
+
+// issuedBy returns the certificate the intermediate at index issued: the
+// leaf or the previous intermediate.
+func (c *CertificateChain) issuedBy(index int) *Certificate {
+	if index == 0 {
+		return c.Leaf
+	}
+	return c.Intermediates[index-1]
+}
+
+// issuerOf returns the certificate that issued the intermediate at index:
+// the next intermediate or, for the last one, the root found when verifying
+// the chain. It returns nil when the issuer isn't known.
+func (c *CertificateChain) issuerOf(index int) *Certificate {
+	cert := c.Intermediates[index]
+	if index+1 < len(c.Intermediates) {
+		if next := c.Intermediates[index+1]; cert.isIssuedBy(next) {
+			return next
+		}
+		return nil
+	}
+	if cert.IsSelfSigned() {
+		return cert
+	}
+	if root := c.verifiedRoot(); root != nil && cert.isIssuedBy(root) {
+		return root
+	}
+	return nil
+}
+
+// verifiedRoot returns the root found when verifying the chain, or nil if
+// verification fails.
+func (c *CertificateChain) verifiedRoot() *Certificate {
+	if c.Leaf == nil {
+		return nil
+	}
+	verifiedChains, err := c.Leaf.Certificate.Verify(c.verifyOptions(""))
+	if err != nil || len(verifiedChains) == 0 {
+		return nil
+	}
+	verifiedChain := verifiedChains[0]
+	return &Certificate{Certificate: verifiedChain[len(verifiedChain)-1]}
+}
+
+type NotCAWarning struct {
+	position string
+}
+
+func (w NotCAWarning) ID() string {
+	return "ca-not-ca"
+}
+
+func (w NotCAWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w NotCAWarning) Title() string {
+	return "Intermediate isn't a CA."
+}
+
+func (w NotCAWarning) Description() string {
+	return formatDescription(`
+This certificate (%s) doesn't have CA:TRUE in its Basic Constraints,
+so clients won't accept the certificates it issued. Either the chain
+includes the wrong certificate or it's out of order.
+`, w.position)
+}
+
+func TryNotCAWarning(chain *CertificateChain, index int) Warning {
+	cert := chain.Intermediates[index].Certificate
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return NotCAWarning{position: chain.PositionName(chain.Intermediates[index])}
+	}
+	return nil
+}
+
+type OutOfOrderWarning struct {
+	position       string
+	issuedPosition string
+	childPosition  string
+}
+
+func (w OutOfOrderWarning) ID() string {
+	return "chain-out-of-order"
+}
+
+func (w OutOfOrderWarning) Severity() Severity {
+	return SeverityWarning
+}
+
+func (w OutOfOrderWarning) Title() string {
+	return "Chain is out of order."
+}
+
+func (w OutOfOrderWarning) Description() string {
+	return formatDescription(`
+This certificate (%s) issued the %s, but comes right after the %s, which
+it didn't issue. Each certificate should be followed by its issuer. Most
+browsers reorder the chain, but some older clients and TLS libraries
+reject it.
+`, w.position, w.issuedPosition, w.childPosition)
+}
+
+func TryOutOfOrderWarning(chain *CertificateChain, index int) Warning {
+	cert := chain.Intermediates[index]
+	child := chain.issuedBy(index)
+	if child == nil || child.isIssuedBy(cert) {
+		return nil
+	}
+	for _, other := range chain.Certificates() {
+		if other != cert && other.isIssuedBy(cert) {
+			return OutOfOrderWarning{
+				position:       chain.PositionName(cert),
+				issuedPosition: chain.PositionName(other),
+				childPosition:  chain.PositionName(child),
+			}
+		}
+	}
+	return nil
+}
+
+type RootInChainWarning struct {
+	position string
+}
+
+func (w RootInChainWarning) ID() string {
+	return "root-in-chain"
+}
+
+func (w RootInChainWarning) Severity() Severity {
+	return SeverityInfo
+}
+
+func (w RootInChainWarning) Title() string {
+	return "Chain includes a root certificate."
+}
+
+func (w RootInChainWarning) Description() string {
+	return formatDescription(`
+This certificate (%s) is a self-signed root. Clients only trust the roots
+they already have, so sending it doesn't help verification and only adds
+bytes to every handshake. It can be left out of the chain.
+`, w.position)
+}
+
+func TryRootInChainWarning(chain *CertificateChain, index int) Warning {
+	cert := chain.Intermediates[index]
+	if cert.IsSelfSigned() {
+		return RootInChainWarning{position: chain.PositionName(cert)}
+	}
+	return nil
+}
+
+type PathLengthExceededWarning struct {
+	position   string
+	maxPathLen int
+	below      int
+}
+
+func (w PathLengthExceededWarning) ID() string {
+	return "path-length-exceeded"
+}
+
+func (w PathLengthExceededWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w PathLengthExceededWarning) Title() string {
+	return "Path length constraint exceeded."
+}
+
+func (w PathLengthExceededWarning) Description() string {
+	return formatDescription(`
+This certificate (%s) allows at most %d intermediates below it, but the
+chain has %d. Clients will reject the chain.
+`, w.position, w.maxPathLen, w.below)
+}
+
+func TryPathLengthExceededWarning(chain *CertificateChain, index int) Warning {
+	cert := chain.Intermediates[index].Certificate
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return nil
+	}
+	hasConstraint := cert.MaxPathLen > 0 || (cert.MaxPathLen == 0 && cert.MaxPathLenZero)
+	if !hasConstraint {
+		return nil
+	}
+
+	// Self-issued intermediates, such as key rollover certificates, don't
+	// count towards the limit.
+	below := 0
+	for _, other := range chain.Intermediates[:index] {
+		if !bytes.Equal(other.Certificate.RawSubject, other.Certificate.RawIssuer) {
+			below++
+		}
+	}
+	if below > cert.MaxPathLen {
+		return PathLengthExceededWarning{
+			position:   chain.PositionName(chain.Intermediates[index]),
+			maxPathLen: cert.MaxPathLen,
+			below:      below,
+		}
+	}
+	return nil
+}
+
+type MissingCertSignWarning struct {
+	position    string
+	noExtension bool
+}
+
+func (w MissingCertSignWarning) ID() string {
+	return "ca-missing-cert-sign"
+}
+
+// Severity is lower without the extension at all: clients only check for
+// keyCertSign when Key Usage is present, so the chain still validates.
+func (w MissingCertSignWarning) Severity() Severity {
+	if w.noExtension {
+		return SeverityWarning
+	}
+	return SeverityError
+}
+
+func (w MissingCertSignWarning) Title() string {
+	return "Missing keyCertSign key usage."
+}
+
+func (w MissingCertSignWarning) Description() string {
+	if w.noExtension {
+		return formatDescription(`
+This certificate (%s) has no Key Usage extension. Clients accept it, but
+CA certificates are required to list keyCertSign.
+`, w.position)
+	}
+	return formatDescription(`
+The Key Usage of this certificate (%s) doesn't include keyCertSign, so
+it can't be used to issue certificates and clients will reject the chain.
+`, w.position)
+}
+
+func TryMissingCertSignWarning(chain *CertificateChain, index int) Warning {
+	usage := chain.Intermediates[index].Certificate.KeyUsage
+	position := chain.PositionName(chain.Intermediates[index])
+	if usage == 0 {
+		return MissingCertSignWarning{position: position, noExtension: true}
+	}
+	if usage&x509.KeyUsageCertSign == 0 {
+		return MissingCertSignWarning{position: position}
+	}
+	return nil
+}
+
+type KeyIDMismatchWarning struct {
+	position       string
+	childPosition  string
+	authorityKeyID []byte
+	subjectKeyID   []byte
+}
+
+func (w KeyIDMismatchWarning) ID() string {
+	return "key-id-mismatch"
+}
+
+func (w KeyIDMismatchWarning) Severity() Severity {
+	return SeverityWarning
+}
+
+func (w KeyIDMismatchWarning) Title() string {
+	return "Authority key ID doesn't match."
+}
+
+func (w KeyIDMismatchWarning) Description() string {
+	return formatDescription(`
+The %s has authority key ID %x, but this certificate (%s) has subject
+key ID %x. Clients building paths by key ID may not find this certificate
+as its issuer.
+`, w.childPosition, w.authorityKeyID, w.position, w.subjectKeyID)
+}
+
+func TryKeyIDMismatchWarning(chain *CertificateChain, index int) Warning {
+	cert := chain.Intermediates[index]
+	child := chain.issuedBy(index)
+	if child == nil || !child.isIssuedBy(cert) {
+		return nil
+	}
+
+	authorityKeyID := child.Certificate.AuthorityKeyId
+	subjectKeyID := cert.Certificate.SubjectKeyId
+	if len(authorityKeyID) == 0 || len(subjectKeyID) == 0 {
+		return nil
+	}
+	if !bytes.Equal(authorityKeyID, subjectKeyID) {
+		return KeyIDMismatchWarning{
+			position:       chain.PositionName(cert),
+			childPosition:  chain.PositionName(child),
+			authorityKeyID: authorityKeyID,
+			subjectKeyID:   subjectKeyID,
+		}
+	}
+	return nil
+}
+
+type IncompatibleEKUWarning struct {
+	position string
+}
+
+func (w IncompatibleEKUWarning) ID() string {
+	return "eku-incompatible"
+}
+
+func (w IncompatibleEKUWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w IncompatibleEKUWarning) Title() string {
+	return "Intermediate doesn't allow serverAuth."
+}
+
+func (w IncompatibleEKUWarning) Description() string {
+	return formatDescription(`
+This certificate (%s) restricts its Extended Key Usage without
+including serverAuth. Clients apply the restriction to the whole chain
+below it, so they'll reject the leaf for TLS servers.
+`, w.position)
+}
+
+func TryIncompatibleEKUWarning(chain *CertificateChain, index int) Warning {
+	cert := chain.Intermediates[index].Certificate
+	if len(cert.ExtKeyUsage) == 0 && len(cert.UnknownExtKeyUsage) == 0 {
+		return nil
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageServerAuth || usage == x509.ExtKeyUsageAny {
+			return nil
+		}
+	}
+	return IncompatibleEKUWarning{position: chain.PositionName(chain.Intermediates[index])}
+}
+
+// signatureKeyAlgorithm is the key type a signature algorithm needs.
+func signatureKeyAlgorithm(algorithm x509.SignatureAlgorithm) x509.PublicKeyAlgorithm {
+	switch algorithm {
+	case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.SHA256WithRSA,
+		x509.SHA384WithRSA, x509.SHA512WithRSA, x509.SHA256WithRSAPSS,
+		x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS:
+		return x509.RSA
+	case x509.DSAWithSHA1, x509.DSAWithSHA256:
+		return x509.DSA
+	case x509.ECDSAWithSHA1, x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512:
+		return x509.ECDSA
+	case x509.PureEd25519:
+		return x509.Ed25519
+	default:
+		return x509.UnknownPublicKeyAlgorithm
+	}
+}
+
+type SignatureKeyMismatchWarning struct {
+	position  string
+	algorithm string
+	issuerKey string
+}
+
+func (w SignatureKeyMismatchWarning) ID() string {
+	return "signature-key-mismatch"
+}
+
+func (w SignatureKeyMismatchWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w SignatureKeyMismatchWarning) Title() string {
+	return "Signature algorithm doesn't match the issuer's key."
+}
+
+func (w SignatureKeyMismatchWarning) Description() string {
+	return formatDescription(`
+This certificate (%s) is signed with %s, but its issuer's key is %s. The
+signature can't be valid.
+`, w.position, w.algorithm, w.issuerKey)
+}
+
+func TrySignatureKeyMismatchWarning(chain *CertificateChain, index int) Warning {
+	cert := chain.Intermediates[index]
+	issuer := chain.issuerOf(index)
+	if issuer == nil {
+		return nil
+	}
+
+	needed := signatureKeyAlgorithm(cert.Certificate.SignatureAlgorithm)
+	if needed == x509.UnknownPublicKeyAlgorithm || needed == issuer.Certificate.PublicKeyAlgorithm {
+		return nil
+	}
+	return SignatureKeyMismatchWarning{
+		position:  chain.PositionName(cert),
+		algorithm: cert.ReadableSignatureAlgorithm(),
+		issuerKey: issuer.ReadablePublicKeyAlgorithm(),
+	}
+}
+
+type DistrustedIssuerWarning struct {
+	position string
+	anchor   string
+	ca       *DistrustedCA
+	issuedAt time.Time
+}
+
+func (w DistrustedIssuerWarning) ID() string {
+	return "distrusted-issuer"
+}
+
+// Severity depends on whether any client rejects the leaf already, will
+// reject it later, or only rejects certificates issued after it.
+func (w DistrustedIssuerWarning) Severity() Severity {
+	severity := SeverityInfo
+	now := time.Now()
+	for _, distrust := range w.ca.Distrusts {
+		if !distrust.Affects(w.issuedAt) {
+			continue
+		}
+		since, _ := distrust.since()
+		if now.Before(since) {
+			severity = SeverityWarning
+		} else {
+			return SeverityError
+		}
+	}
+	return severity
+}
+
+func (w DistrustedIssuerWarning) Title() string {
+	return "Chain goes through a distrusted CA."
+}
+
+func (w DistrustedIssuerWarning) Description() string {
+	now := time.Now()
+	clients := []string{}
+	for _, distrust := range w.ca.Distrusts {
+		since, _ := distrust.since()
+		switch {
+		case !distrust.Affects(w.issuedAt):
+			clients = append(clients, fmt.Sprintf(
+				"%s only rejects certificates issued after %s, so it accepts the leaf but won't accept a renewal from this CA",
+				distrust.Client, distrust.IssuedAfter))
+		case now.Before(since):
+			clients = append(clients, fmt.Sprintf(
+				"%s will reject the leaf starting %s", distrust.Client, distrust.Since))
+		default:
+			clients = append(clients, fmt.Sprintf(
+				"%s rejects the leaf since %s", distrust.Client, distrust.Since))
+		}
+	}
+
+	return formatDescription(`
+This certificate (%s) %s, part of the %s PKI, which browsers distrusted
+(%s). %s.
+`, w.position, w.anchor, w.ca.Name, w.ca.Reason, strings.Join(clients, "; "))
+}
+
+func TryDistrustedIssuerWarning(chain *CertificateChain, index int) Warning {
+	cert := chain.Intermediates[index]
+	if chain.Leaf == nil {
+		return nil
+	}
+
+	warning := DistrustedIssuerWarning{
+		position: chain.PositionName(cert),
+		issuedAt: chain.Leaf.Certificate.NotBefore,
+	}
+	ca := findDistrustedCA(cert.SPKIHash())
+	if ca == nil {
+		ca = findDistrustedCAByName(cert.Certificate.Subject, cert.Certificate.SubjectKeyId)
+	}
+	if ca != nil {
+		warning.anchor = "is " + distrustName(cert.Certificate.Subject)
+		warning.ca = ca
+		return warning
+	}
+
+	if cert != chain.pathEnd() {
+		return nil
+	}
+	return chain.tryDistrustedIssuerOf(cert, warning)
+}
+
+// TryLeafDistrustedIssuerWarning checks the leaf's issuer when no served
+// intermediate leads away from it, such as for a leaf served alone.
+func TryLeafDistrustedIssuerWarning(chain *CertificateChain) Warning {
+	if chain.Leaf == nil || chain.pathEnd() != chain.Leaf {
+		return nil
+	}
+	return chain.tryDistrustedIssuerOf(chain.Leaf, DistrustedIssuerWarning{
+		position: chain.PositionName(chain.Leaf),
+		issuedAt: chain.Leaf.Certificate.NotBefore,
+	})
+}
+
+// tryDistrustedIssuerOf looks up the issuer of the last certificate of the
+// path. Roots are rarely served and the distrusted ones are gone from the
+// trust store, so the issuer is matched by the AKI and issuer name first,
+// and only then by the SPKI of the root found when verifying.
+func (c *CertificateChain) tryDistrustedIssuerOf(cert *Certificate, warning DistrustedIssuerWarning) Warning {
+	if cert.IsSelfSigned() {
+		return nil
+	}
+
+	issuer := cert.Certificate.Issuer
+	if ca := findDistrustedCAByName(issuer, cert.Certificate.AuthorityKeyId); ca != nil {
+		warning.anchor = "was issued by " + distrustName(issuer)
+		warning.ca = ca
+		return warning
+	}
+	if root := c.verifiedRoot(); root != nil && cert.isIssuedBy(root) {
+		if ca := findDistrustedCA(root.SPKIHash()); ca != nil {
+			warning.anchor = "was issued by " + distrustName(root.Certificate.Subject)
+			warning.ca = ca
+			return warning
+		}
+	}
+	return nil
+}
+
+// distrustName names a CA by its Common Name, or its whole subject for the
+// few roots without one.
+func distrustName(name pkix.Name) string {
+	if name.CommonName != "" {
+		return name.CommonName
+	}
+	return name.String()
+}
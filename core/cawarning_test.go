@@ -0,0 +1,118 @@
+package core
+
+import (
+	"crypto/x509"
+	"reflect"
+	"testing"
+)
+
+func TestIntermediateLints(t *testing.T) {
+	root, err := newTestCertificate("Test Root", true, nil, testCertificateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate := func(issuer *Certificate, mutate func(template *x509.Certificate)) *Certificate {
+		cert, err := newTestCertificate("Test Intermediate", true, issuer, testCertificateOptions{mutate: mutate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	leaf := func(issuer *Certificate, options testCertificateOptions) *Certificate {
+		cert, err := newTestCertificate(testPKIHostname, false, issuer, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	chain := func(leaf *Certificate, intermediates ...*Certificate) *CertificateChain {
+		return &CertificateChain{Leaf: leaf, Intermediates: intermediates}
+	}
+
+	good := intermediate(root, nil)
+	notCA := intermediate(root, func(template *x509.Certificate) { template.IsCA = false })
+	noCertSign := intermediate(root, func(template *x509.Certificate) {
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+	})
+	noKeyUsage := intermediate(root, func(template *x509.Certificate) { template.KeyUsage = 0 })
+	clientOnly := intermediate(root, func(template *x509.Certificate) {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	})
+	serverAuth := intermediate(root, func(template *x509.Certificate) {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	})
+	pathLenZero := intermediate(root, func(template *x509.Certificate) { template.MaxPathLenZero = true })
+	// Self-issued certificates don't count towards path length limits, so
+	// this one needs its own name.
+	belowPathLenZero, err := newTestCertificate("Test Sub Intermediate", true, pathLenZero, testCertificateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		chain *CertificateChain
+		want  []string
+	}{
+		{"good", chain(leaf(good, testCertificateOptions{}), good), []string{}},
+		{"not a CA", chain(leaf(notCA, testCertificateOptions{}), notCA), []string{"ca-not-ca"}},
+		{"no keyCertSign", chain(leaf(noCertSign, testCertificateOptions{}), noCertSign), []string{"ca-missing-cert-sign"}},
+		{"no key usage", chain(leaf(noKeyUsage, testCertificateOptions{}), noKeyUsage), []string{"ca-missing-cert-sign"}},
+		{"client auth only", chain(leaf(clientOnly, testCertificateOptions{}), clientOnly), []string{"eku-incompatible"}},
+		{"server auth", chain(leaf(serverAuth, testCertificateOptions{}), serverAuth), []string{}},
+		{
+			"path length exceeded",
+			chain(leaf(belowPathLenZero, testCertificateOptions{}), belowPathLenZero, pathLenZero),
+			[]string{"path-length-exceeded"},
+		},
+		{
+			"key ID mismatch",
+			chain(leaf(good, testCertificateOptions{
+				noKeyIDs: true,
+				mutate: func(template *x509.Certificate) {
+					template.AuthorityKeyId = []byte{1, 2, 3, 4}
+				},
+			}), good),
+			[]string{"key-id-mismatch"},
+		},
+	}
+	for _, test := range tests {
+		got := []string{}
+		for index := range test.chain.Intermediates {
+			for _, w := range test.chain.intermediateWarnings(index) {
+				got = append(got, w.ID())
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// Without keyCertSign, clients still accept a CA with no Key Usage
+	// extension at all.
+	severities := map[*Certificate]Severity{noCertSign: SeverityError, noKeyUsage: SeverityWarning}
+	for cert, want := range severities {
+		if got := TryMissingCertSignWarning(chain(nil, cert), 0).Severity(); got != want {
+			t.Errorf("%s: got severity %s, want %s", cert.Certificate.KeyUsage, got, want)
+		}
+	}
+}
+
+func TestSignatureKeyAlgorithm(t *testing.T) {
+	tests := []struct {
+		algorithm x509.SignatureAlgorithm
+		want      x509.PublicKeyAlgorithm
+	}{
+		{x509.SHA256WithRSA, x509.RSA},
+		{x509.SHA256WithRSAPSS, x509.RSA},
+		{x509.ECDSAWithSHA384, x509.ECDSA},
+		{x509.PureEd25519, x509.Ed25519},
+		{x509.DSAWithSHA256, x509.DSA},
+		{x509.UnknownSignatureAlgorithm, x509.UnknownPublicKeyAlgorithm},
+	}
+	for _, test := range tests {
+		if got := signatureKeyAlgorithm(test.algorithm); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.algorithm, got, test.want)
+		}
+	}
+}
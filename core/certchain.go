@@ -28,6 +28,7 @@ func FetchCertificateChain(host, port string) (*CertificateChain, error) {
 
 	connState := conn.ConnectionState()
 
+	source := net.JoinHostPort(host, port)
 	rv := &CertificateChain{}
 	isFirst := true
 	for _, cert := range connState.PeerCertificates {
@@ -35,10 +36,12 @@ func FetchCertificateChain(host, port string) (*CertificateChain, error) {
 			isFirst = false
 			rv.Leaf = &Certificate{
 				Certificate: cert,
+				Source:      source,
 			}
 		} else {
 			rv.Intermediates = append(rv.Intermediates, &Certificate{
 				Certificate: cert,
+				Source:      source,
 			})
 		}
 	}
@@ -55,8 +58,13 @@ func ChainFromAWS(awsCertificate *iam.ServerCertificate) (*CertificateChain, err
 	if err != nil {
 		return nil, fmt.Errorf("Unable to parse certificate body: %s", err)
 	}
+	source := ""
+	if meta := awsCertificate.ServerCertificateMetadata; meta != nil && meta.ServerCertificateName != nil {
+		source = "iam:" + *meta.ServerCertificateName
+	}
 	rv.Leaf = &Certificate{
 		Certificate: leafX509,
+		Source:      source,
 	}
 
 	if awsCertificate.CertificateChain != nil {
@@ -67,6 +75,7 @@ func ChainFromAWS(awsCertificate *iam.ServerCertificate) (*CertificateChain, err
 		for _, cert := range intermediatesX509 {
 			rv.Intermediates = append(rv.Intermediates, &Certificate{
 				Certificate: cert,
+				Source:      source,
 			})
 		}
 	}
@@ -91,6 +100,25 @@ func ChainFromCertificateAndIntermediatesData(
 		intermediatesPool.AddCert(cert)
 	}
 
+	// Issuers missing from the given intermediates may be in the database,
+	// each added once, so cross-signs can't loop.
+	fromDatabase := map[string]*Certificate{}
+	pending := []*Certificate{leaf}
+	for _, cert := range intermediatesX509 {
+		pending = append(pending, &Certificate{Certificate: cert})
+	}
+	for len(pending) > 0 {
+		cert := pending[0]
+		pending = pending[1:]
+		for _, candidate := range intermediateCandidates(cert) {
+			if _, ok := fromDatabase[string(candidate.Certificate.Raw)]; !ok && cert.isIssuedBy(candidate) {
+				fromDatabase[string(candidate.Certificate.Raw)] = candidate
+				intermediatesPool.AddCert(candidate.Certificate)
+				pending = append(pending, candidate)
+			}
+		}
+	}
+
 	verifiedChains, err := leaf.Certificate.Verify(x509.VerifyOptions{
 		Roots:         MustCertPool(),
 		CurrentTime:   time.Now(),
@@ -115,8 +143,11 @@ func ChainFromCertificateAndIntermediatesData(
 		cert := &Certificate{
 			Certificate: x509Cert,
 		}
+		if databaseCert, ok := fromDatabase[string(x509Cert.Raw)]; ok {
+			cert = databaseCert
+		}
 
-		if cert.IsBundled() {
+		if cert.IsTrusted() {
 			break
 		}
 
@@ -127,15 +158,29 @@ func ChainFromCertificateAndIntermediatesData(
 }
 
 func ChainFromCertificateAndInternet(leaf *Certificate) (*CertificateChain, error) {
+	return ChainFromCertificateAndInternetWithTrace(leaf, nil)
+}
+
+// ChainFromCertificateAndInternetWithTrace builds the chain up to a trusted
+// root, taking each issuer from the intermediate database or else following
+// AIA URLs, and recording each fetch and candidate issuer in trace, which may
+// be nil. It gives up on untrusted self-signed certificates and after
+// maxTraceDepth certificates, in case issuers loop.
+func ChainFromCertificateAndInternetWithTrace(leaf *Certificate, trace *Trace) (*CertificateChain, error) {
 	rv := &CertificateChain{
 		Leaf: leaf,
 	}
 
 	isLeafCert := true
 	currentCert := leaf
-	var err error
-	for {
-		if currentCert.IsBundled() {
+	for depth := 0; ; depth++ {
+		if currentCert.IsTrusted() {
+			trace.record(&TraceStep{
+				Kind:        TraceRoot,
+				Certificate: currentCert.ReadableSubject(),
+				Accepted:    true,
+				Reason:      "it's a trusted root",
+			})
 			break
 		}
 
@@ -144,26 +189,57 @@ func ChainFromCertificateAndInternet(leaf *Certificate) (*CertificateChain, erro
 		}
 		isLeafCert = false
 
-		if len(currentCert.Certificate.IssuingCertificateURL) == 0 {
+		if currentCert.IsSelfSigned() {
+			trace.failure(currentCert, "it's self-signed, but not a trusted root")
+			return nil, fmt.Errorf(
+				"Error fetching intermediates: reached %s, which isn't a trusted root",
+				currentCert.ReadableSubject())
+		}
+		if depth >= maxTraceDepth {
+			trace.failure(currentCert, "depth limit reached, with no root within %d certificates", maxTraceDepth)
+			return nil, fmt.Errorf(
+				"Error fetching intermediates: no trusted root within %d certificates", maxTraceDepth)
+		}
+
+		trace.search(currentCert)
+
+		issuer := trace.considerDatabase(currentCert)
+		if issuer == nil && len(currentCert.Certificate.IssuingCertificateURL) == 0 {
+			trace.failure(currentCert, "its issuer isn't in the intermediate database, and it has no AIA URL to fetch it from")
 			return nil, fmt.Errorf(
 				"Error fetching intermediates: cert for %s doesn't point to parent",
 				currentCert.ReadableSubject())
 		}
 
-		success := false
-		err = fmt.Errorf(
-			"Error fetching intermediates: cert for %s doesn't point to parent",
-			currentCert.ReadableSubject())
+		var err error
 		for _, url := range currentCert.Certificate.IssuingCertificateURL {
-			currentCert, err = CertificateFromURL(url)
-			if err == nil {
-				success = true
+			if issuer != nil {
 				break
 			}
+			var fetched *Certificate
+			fetched, err = trace.fetchIssuer(currentCert, url)
+			if err != nil {
+				continue
+			}
+			if !trace.considerIssuer(currentCert, fetched, "AIA") {
+				err = fmt.Errorf("%s, fetched from %s, isn't a valid issuer of %s",
+					fetched.ReadableSubject(), url, currentCert.ReadableSubject())
+				continue
+			}
+			issuer = fetched
 		}
-		if !success {
+		if issuer == nil {
+			trace.failure(currentCert, "none of its AIA URLs gave a valid issuer")
 			return nil, fmt.Errorf("Unable to fetch next cert in chain: %s", err)
 		}
+
+		trace.record(&TraceStep{
+			Kind:        TraceIssuer,
+			Certificate: currentCert.ReadableSubject(),
+			Candidate:   issuer.ReadableSubject(),
+			Accepted:    true,
+		})
+		currentCert = issuer
 	}
 
 	return rv, nil
@@ -193,25 +269,191 @@ func ChainFromFullChainData(chainData []byte) (*CertificateChain, error) {
 	return rv, nil
 }
 
+// ChainFromCertificates builds a chain from a loose set of certificates,
+// starting from one that doesn't issue any of the others. When several
+// don't, such as with an unrelated certificate in a bundle, the leaf is the
+// one with a private key attached (see MatchPrivateKeys), or else the one
+// with the longest chain among the others. Intermediates keep the order
+// they were given in, so misordered files show up in the chain warnings.
+// Certificates that don't belong to the chain are returned separately.
+func ChainFromCertificates(certs []*Certificate) (*CertificateChain, []*Certificate, error) {
+	if len(certs) <= 0 {
+		return nil, nil, fmt.Errorf("Found no certificates in the given files.")
+	}
+
+	var leaf *Certificate
+	var inChain map[*Certificate]bool
+	for _, candidate := range certs {
+		issuesOthers := false
+		for _, other := range certs {
+			if other != candidate && other.isIssuedBy(candidate) {
+				issuesOthers = true
+				break
+			}
+		}
+		if issuesOthers {
+			continue
+		}
+
+		members := chainMembers(candidate, certs)
+		switch {
+		case leaf == nil,
+			leaf.PrivateKey == nil && candidate.PrivateKey != nil,
+			(leaf.PrivateKey == nil) == (candidate.PrivateKey == nil) && len(members) > len(inChain):
+			leaf, inChain = candidate, members
+		}
+	}
+	if leaf == nil {
+		leaf, inChain = certs[0], chainMembers(certs[0], certs)
+	}
+
+	rv := &CertificateChain{
+		Leaf: leaf,
+	}
+	remaining := []*Certificate{}
+	for _, cert := range certs {
+		switch {
+		case cert == leaf:
+		case inChain[cert]:
+			rv.Intermediates = append(rv.Intermediates, cert)
+		default:
+			remaining = append(remaining, cert)
+		}
+	}
+
+	return rv, remaining, nil
+}
+
+// chainMembers follows issuers from leaf through certs, returning the
+// certificates reached, leaf included.
+func chainMembers(leaf *Certificate, certs []*Certificate) map[*Certificate]bool {
+	rv := map[*Certificate]bool{leaf: true}
+	current := leaf
+	for !current.IsSelfSigned() {
+		found := false
+		for _, cert := range certs {
+			if !rv[cert] && current.isIssuedBy(cert) {
+				rv[cert] = true
+				current = cert
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return rv
+}
+
+// pathEnd follows issuers from the leaf through the intermediates, whatever
+// order they're in, and returns the last certificate reached.
+func (c *CertificateChain) pathEnd() *Certificate {
+	current := c.Leaf
+	visited := map[*Certificate]bool{current: true}
+	for !current.IsSelfSigned() {
+		found := false
+		for _, cert := range c.Intermediates {
+			if !visited[cert] && current.isIssuedBy(cert) {
+				visited[cert] = true
+				current = cert
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return current
+}
+
+func (c *CertificateChain) Certificates() []*Certificate {
+	rv := []*Certificate{}
+	if c.Leaf != nil {
+		rv = append(rv, c.Leaf)
+	}
+	return append(rv, c.Intermediates...)
+}
+
+func (c *CertificateChain) PositionName(cert *Certificate) string {
+	if cert == c.Leaf {
+		return "leaf certificate"
+	}
+	for index, intermediate := range c.Intermediates {
+		if cert == intermediate {
+			return fmt.Sprintf("intermediate #%d", index+1)
+		}
+	}
+	return "unrelated certificate"
+}
+
+// CertificateWarnings returns the warnings for a certificate of the chain,
+// including the ones that depend on its position.
+func (c *CertificateChain) CertificateWarnings(cert *Certificate) []Warning {
+	if cert == c.Leaf {
+		rv := cert.LeafWarnings()
+		if w := TryLeafDistrustedIssuerWarning(c); w != nil {
+			rv = append(rv, warningPolicy.apply(cert, []Warning{w})...)
+		}
+		return rv
+	}
+	for index, intermediate := range c.Intermediates {
+		if cert == intermediate {
+			return c.intermediateWarnings(index)
+		}
+	}
+	return cert.Warnings()
+}
+
+// Warnings returns the warnings for every certificate of the chain.
+func (c *CertificateChain) Warnings() []Warning {
+	rv := []Warning{}
+	for _, cert := range c.Certificates() {
+		rv = append(rv, c.CertificateWarnings(cert)...)
+	}
+	return rv
+}
+
+// CertificateInfoLines describes one certificate of the chain, with the
+// warnings that depend on its position.
+func (c *CertificateChain) CertificateInfoLines(cert *Certificate, wrapLength int, verbose bool) *Lines {
+	return cert.infoLines(wrapLength, verbose, c.CertificateWarnings(cert))
+}
+
 func (c *CertificateChain) InfoLines(wrapLength int) *Lines {
+	return c.infoLines(wrapLength, false)
+}
+
+func (c *CertificateChain) VerboseInfoLines(wrapLength int) *Lines {
+	return c.infoLines(wrapLength, true)
+}
+
+func (c *CertificateChain) infoLines(wrapLength int, verbose bool) *Lines {
 	lines := NewLines()
 
 	if c.Leaf != nil {
 		lines.Print("Leaf Certificate:")
-		lines.AppendLines(c.Leaf.InfoLines(wrapLength - 2).IndentedBy("  "))
+		lines.AppendLines(c.Leaf.infoLines(
+			wrapLength-2, verbose, c.CertificateWarnings(c.Leaf)).IndentedBy("  "))
 	} else {
 		lines.Print("No Leaf Certificate Present")
 	}
 
 	for index, cert := range c.Intermediates {
-		lines.Print("Intermediate #%d:", index+1)
-		lines.AppendLines(cert.InfoLines(wrapLength - 2).IndentedBy("  "))
+		if cert.IsSelfSigned() {
+			lines.Print("Intermediate #%d (root):", index+1)
+		} else {
+			lines.Print("Intermediate #%d:", index+1)
+		}
+		lines.AppendLines(cert.infoLines(
+			wrapLength-2, verbose, c.CertificateWarnings(cert)).IndentedBy("  "))
 	}
 
 	return lines
 }
 
-func (c *CertificateChain) Verify(dnsName string) error {
+func (c *CertificateChain) verifyOptions(dnsName string) x509.VerifyOptions {
 	verifyOptions := x509.VerifyOptions{
 		Roots:         MustCertPool(),
 		CurrentTime:   time.Now(),
@@ -226,7 +468,11 @@ func (c *CertificateChain) Verify(dnsName string) error {
 		verifyOptions.Intermediates.AddCert(cert.Certificate)
 	}
 
-	_, err := c.Leaf.Certificate.Verify(verifyOptions)
+	return verifyOptions
+}
+
+func (c *CertificateChain) Verify(dnsName string) error {
+	_, err := c.Leaf.Certificate.Verify(c.verifyOptions(dnsName))
 	switch err := err.(type) {
 	case nil:
 		return nil
@@ -236,6 +482,11 @@ func (c *CertificateChain) Verify(dnsName string) error {
 			Hostname:    dnsName,
 		}
 	case x509.UnknownAuthorityError:
+		for _, cert := range c.Certificates() {
+			if !cert.IsBundled() && isObsoleteSignatureAlgorithm(cert.Certificate.SignatureAlgorithm) {
+				return ObsoleteSignatureError{Certificate: cert, Position: c.PositionName(cert)}
+			}
+		}
 		return UnknownAuthorityError{}
 	default:
 		return err
@@ -287,6 +538,27 @@ see intermittent connection problems, so this should be solved anyway.
 `)
 }
 
+type ObsoleteSignatureError struct {
+	Certificate *Certificate
+	Position    string
+}
+
+func (e ObsoleteSignatureError) Error() string {
+	return formatVerifyError(`
+The %s, which is:
+
+    %s
+
+Is signed with %s, which clients no longer accept. No trusted chain can
+be built through it, so it must be reissued with a SHA-256 or stronger
+signature.
+`,
+		e.Position,
+		e.Certificate.ReadableSubject(),
+		e.Certificate.ReadableSignatureAlgorithm(),
+	)
+}
+
 func formatVerifyError(format string, a ...interface{}) string {
 	return strings.Trim(fmt.Sprintf(format, a...), " \n")
 }
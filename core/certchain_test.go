@@ -0,0 +1,109 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestChainFromCertificates(t *testing.T) {
+	fixture := func(name string) *Certificate {
+		return loadCertificateFixture(t, filepath.Join("testdata", "pkcs12", name))
+	}
+	leaf, intermediate, root, unrelated := fixture("leaf.crt"), fixture("intermediate.crt"),
+		fixture("root.crt"), fixture("unrelated.crt")
+	keyedLeaf, keyedUnrelated := fixture("leaf.crt"), fixture("unrelated.crt")
+	keyedLeaf.PrivateKey = "attached"
+	keyedUnrelated.PrivateKey = "attached"
+
+	tests := []struct {
+		name              string
+		certs             []*Certificate
+		wantLeaf          *Certificate
+		wantIntermediates []*Certificate
+		wantUnrelated     []*Certificate
+	}{
+		{
+			name:              "in order",
+			certs:             []*Certificate{leaf, intermediate, root},
+			wantLeaf:          leaf,
+			wantIntermediates: []*Certificate{intermediate, root},
+		},
+		{
+			name:              "file order is kept",
+			certs:             []*Certificate{root, leaf, intermediate},
+			wantLeaf:          leaf,
+			wantIntermediates: []*Certificate{root, intermediate},
+		},
+		{
+			name:              "unrelated certificate first",
+			certs:             []*Certificate{unrelated, intermediate, keyedLeaf},
+			wantLeaf:          keyedLeaf,
+			wantIntermediates: []*Certificate{intermediate},
+			wantUnrelated:     []*Certificate{unrelated},
+		},
+		{
+			name:              "unrelated certificate without keys",
+			certs:             []*Certificate{unrelated, leaf, intermediate},
+			wantLeaf:          leaf,
+			wantIntermediates: []*Certificate{intermediate},
+			wantUnrelated:     []*Certificate{unrelated},
+		},
+		{
+			name:              "private key wins over chain length",
+			certs:             []*Certificate{leaf, intermediate, keyedUnrelated},
+			wantLeaf:          keyedUnrelated,
+			wantUnrelated:     []*Certificate{leaf, intermediate},
+			wantIntermediates: nil,
+		},
+		{
+			name:          "only unrelated certificates",
+			certs:         []*Certificate{unrelated, root},
+			wantLeaf:      unrelated,
+			wantUnrelated: []*Certificate{root},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chain, remaining, err := ChainFromCertificates(test.certs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if chain.Leaf != test.wantLeaf {
+				t.Errorf("got leaf %s, want %s", chain.Leaf.ReadableSubject(), test.wantLeaf.ReadableSubject())
+			}
+			assertSameCertificates(t, "intermediates", chain.Intermediates, test.wantIntermediates)
+			assertSameCertificates(t, "unrelated", remaining, test.wantUnrelated)
+		})
+	}
+}
+
+func TestChainPathEnd(t *testing.T) {
+	fixture := func(name string) *Certificate {
+		return loadCertificateFixture(t, filepath.Join("testdata", "pkcs12", name))
+	}
+	leaf, intermediate, root := fixture("leaf.crt"), fixture("intermediate.crt"), fixture("root.crt")
+
+	chain := &CertificateChain{Leaf: leaf, Intermediates: []*Certificate{root, intermediate}}
+	if end := chain.pathEnd(); end != root {
+		t.Errorf("got path end %s, want the root", end.ReadableSubject())
+	}
+	chain = &CertificateChain{Leaf: leaf}
+	if end := chain.pathEnd(); end != leaf {
+		t.Errorf("got path end %s, want the leaf", end.ReadableSubject())
+	}
+}
+
+func assertSameCertificates(t *testing.T, what string, got, want []*Certificate) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("got %d %s, want %d", len(got), what, len(want))
+		return
+	}
+	for index := range got {
+		if got[index] != want[index] {
+			t.Errorf("%s #%d: got %s, want %s", what, index+1,
+				got[index].ReadableSubject(), want[index].ReadableSubject())
+		}
+	}
+}
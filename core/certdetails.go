@@ -0,0 +1,290 @@
+package core
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+)
+
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "Digital Signature"},
+	{x509.KeyUsageContentCommitment, "Content Commitment"},
+	{x509.KeyUsageKeyEncipherment, "Key Encipherment"},
+	{x509.KeyUsageDataEncipherment, "Data Encipherment"},
+	{x509.KeyUsageKeyAgreement, "Key Agreement"},
+	{x509.KeyUsageCertSign, "Certificate Sign"},
+	{x509.KeyUsageCRLSign, "CRL Sign"},
+	{x509.KeyUsageEncipherOnly, "Encipher Only"},
+	{x509.KeyUsageDecipherOnly, "Decipher Only"},
+}
+
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "Any",
+	x509.ExtKeyUsageServerAuth:                     "TLS Web Server Authentication",
+	x509.ExtKeyUsageClientAuth:                     "TLS Web Client Authentication",
+	x509.ExtKeyUsageCodeSigning:                    "Code Signing",
+	x509.ExtKeyUsageEmailProtection:                "E-mail Protection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "IPSec End System",
+	x509.ExtKeyUsageIPSECTunnel:                    "IPSec Tunnel",
+	x509.ExtKeyUsageIPSECUser:                      "IPSec User",
+	x509.ExtKeyUsageTimeStamping:                   "Time Stamping",
+	x509.ExtKeyUsageOCSPSigning:                    "OCSP Signing",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "Microsoft Server Gated Crypto",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "Netscape Server Gated Crypto",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "Microsoft Commercial Code Signing",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "Microsoft Kernel Code Signing",
+}
+
+const (
+	ValidationLevelEV      = "EV"
+	ValidationLevelOV      = "OV"
+	ValidationLevelIV      = "IV"
+	ValidationLevelDV      = "DV"
+	ValidationLevelUnknown = "unknown"
+)
+
+var (
+	oidCABFExtendedValidation    = asn1.ObjectIdentifier{2, 23, 140, 1, 1}
+	oidCABFDomainValidated       = asn1.ObjectIdentifier{2, 23, 140, 1, 2, 1}
+	oidCABFOrganizationValidated = asn1.ObjectIdentifier{2, 23, 140, 1, 2, 2}
+	oidCABFIndividualValidated   = asn1.ObjectIdentifier{2, 23, 140, 1, 2, 3}
+	oidAnyPolicy                 = asn1.ObjectIdentifier{2, 5, 29, 32, 0}
+	oidExtensionNameConstraints  = asn1.ObjectIdentifier{2, 5, 29, 30}
+	oidExtensionBasicConstraints = asn1.ObjectIdentifier{2, 5, 29, 19}
+)
+
+// Policy OIDs some CAs used to mark EV certificates before the CA/B Forum
+// OID was widely adopted.
+var legacyEVPolicies = []asn1.ObjectIdentifier{
+	{1, 3, 6, 1, 4, 1, 4146, 1, 1},            // GlobalSign
+	{1, 3, 6, 1, 4, 1, 6449, 1, 2, 1, 5, 1},   // Sectigo / Comodo
+	{1, 3, 6, 1, 4, 1, 14370, 1, 6},           // GeoTrust
+	{1, 3, 6, 1, 4, 1, 8024, 0, 2, 100, 1, 2}, // QuoVadis
+	{2, 16, 840, 1, 113733, 1, 7, 23, 6},      // VeriSign / Symantec
+	{2, 16, 840, 1, 113733, 1, 7, 48, 1},      // Thawte
+	{2, 16, 840, 1, 114028, 10, 1, 2},         // Entrust
+	{2, 16, 840, 1, 114412, 2, 1},             // DigiCert
+	{2, 16, 840, 1, 114413, 1, 7, 23, 3},      // GoDaddy
+	{2, 16, 840, 1, 114414, 1, 7, 23, 3},      // Starfield
+	{2, 16, 578, 1, 26, 1, 3, 3},              // Buypass
+	{2, 16, 756, 1, 89, 1, 2, 1, 1},           // SwissSign
+	{1, 3, 6, 1, 4, 1, 782, 1, 2, 1, 8, 1},    // Network Solutions
+	{1, 3, 6, 1, 4, 1, 7879, 13, 24, 1},       // T-Systems
+}
+
+func (c *Certificate) ValidationLevel() string {
+	for _, policy := range c.Certificate.PolicyIdentifiers {
+		if policy.Equal(oidCABFExtendedValidation) {
+			return ValidationLevelEV
+		}
+		for _, evPolicy := range legacyEVPolicies {
+			if policy.Equal(evPolicy) {
+				return ValidationLevelEV
+			}
+		}
+	}
+	for _, policy := range c.Certificate.PolicyIdentifiers {
+		switch {
+		case policy.Equal(oidCABFOrganizationValidated):
+			return ValidationLevelOV
+		case policy.Equal(oidCABFIndividualValidated):
+			return ValidationLevelIV
+		case policy.Equal(oidCABFDomainValidated):
+			return ValidationLevelDV
+		}
+	}
+	return ValidationLevelUnknown
+}
+
+func (c *Certificate) ReadableSerialNumber() string {
+	return colonHex(c.Certificate.SerialNumber.Bytes())
+}
+
+func (c *Certificate) ReadableKeyUsage() []string {
+	rv := []string{}
+	for _, entry := range keyUsageNames {
+		if c.Certificate.KeyUsage&entry.usage != 0 {
+			rv = append(rv, entry.name)
+		}
+	}
+	return rv
+}
+
+func (c *Certificate) ReadableExtKeyUsage() []string {
+	rv := []string{}
+	for _, usage := range c.Certificate.ExtKeyUsage {
+		if name, ok := extKeyUsageNames[usage]; ok {
+			rv = append(rv, name)
+		} else {
+			rv = append(rv, fmt.Sprintf("Unknown (%d)", usage))
+		}
+	}
+	for _, oid := range c.Certificate.UnknownExtKeyUsage {
+		rv = append(rv, oid.String())
+	}
+	return rv
+}
+
+func (c *Certificate) ReadableBasicConstraints() string {
+	cert := c.Certificate
+	if !cert.BasicConstraintsValid {
+		return "not present"
+	}
+
+	rv := fmt.Sprintf("CA: %v", cert.IsCA)
+	if cert.IsCA {
+		if cert.MaxPathLen > 0 || cert.MaxPathLenZero {
+			rv += fmt.Sprintf(", path length: %d", cert.MaxPathLen)
+		} else {
+			rv += ", path length: unlimited"
+		}
+	}
+	if c.extensionIsCritical(oidExtensionBasicConstraints) {
+		rv += " (critical)"
+	}
+	return rv
+}
+
+func (c *Certificate) ReadableNameConstraints() []string {
+	cert := c.Certificate
+	rv := []string{}
+	add := func(kind, name string, values []string) {
+		for _, value := range values {
+			rv = append(rv, fmt.Sprintf("%s %s: %s", kind, name, value))
+		}
+	}
+
+	add("Permitted", "DNS", cert.PermittedDNSDomains)
+	add("Excluded", "DNS", cert.ExcludedDNSDomains)
+	for _, r := range cert.PermittedIPRanges {
+		rv = append(rv, "Permitted IP: "+r.String())
+	}
+	for _, r := range cert.ExcludedIPRanges {
+		rv = append(rv, "Excluded IP: "+r.String())
+	}
+	add("Permitted", "email", cert.PermittedEmailAddresses)
+	add("Excluded", "email", cert.ExcludedEmailAddresses)
+	add("Permitted", "URI", cert.PermittedURIDomains)
+	add("Excluded", "URI", cert.ExcludedURIDomains)
+
+	if len(rv) > 0 && c.extensionIsCritical(oidExtensionNameConstraints) {
+		rv = append(rv, "(critical)")
+	}
+	return rv
+}
+
+func (c *Certificate) ReadablePolicies() []string {
+	rv := []string{}
+	for _, policy := range c.Certificate.PolicyIdentifiers {
+		name := policy.String()
+		switch {
+		case policy.Equal(oidCABFExtendedValidation):
+			name += " (CA/B Forum EV)"
+		case policy.Equal(oidCABFOrganizationValidated):
+			name += " (CA/B Forum OV)"
+		case policy.Equal(oidCABFIndividualValidated):
+			name += " (CA/B Forum IV)"
+		case policy.Equal(oidCABFDomainValidated):
+			name += " (CA/B Forum DV)"
+		case policy.Equal(oidAnyPolicy):
+			name += " (any policy)"
+		}
+		rv = append(rv, name)
+	}
+	return rv
+}
+
+func (c *Certificate) extensionIsCritical(oid asn1.ObjectIdentifier) bool {
+	for _, extension := range c.Certificate.Extensions {
+		if extension.Id.Equal(oid) {
+			return extension.Critical
+		}
+	}
+	return false
+}
+
+func (c *Certificate) detailLines(wrapLength int) *Lines {
+	cert := c.Certificate
+	lines := NewLines()
+
+	lines.AppendLines(labeledLines("Subject DN:", []string{cert.Subject.String()}))
+	lines.AppendLines(labeledLines("Issuer DN:", []string{cert.Issuer.String()}))
+	lines.AppendLines(labeledLines("Serial:", []string{c.ReadableSerialNumber()}))
+	lines.AppendLines(labeledLines("SHA-1:", []string{c.SHA1Fingerprint()}))
+	lines.AppendLines(labeledLines("Valid from:", []string{cert.NotBefore.String()}))
+	lines.AppendLines(labeledLines("Key Usage:", c.ReadableKeyUsage()))
+	lines.Print("Ext. Key")
+	lines.AppendLines(labeledLines("Usage:", c.ReadableExtKeyUsage()))
+	lines.Print("Basic")
+	lines.AppendLines(labeledLines("Constraints:", []string{c.ReadableBasicConstraints()}))
+	if constraints := c.ReadableNameConstraints(); len(constraints) > 0 {
+		lines.Print("Name")
+		lines.AppendLines(labeledLines("Constraints:", constraints))
+	}
+	lines.AppendLines(labeledLines("Policies:", c.ReadablePolicies()))
+	lines.AppendLines(labeledLines("Validation:", []string{c.ValidationLevel()}))
+	lines.AppendLines(labeledLines("OCSP:", cert.OCSPServer))
+	lines.AppendLines(labeledLines("CA Issuers:", cert.IssuingCertificateURL))
+	lines.AppendLines(labeledLines("CRLs:", cert.CRLDistributionPoints))
+
+	ipAddresses := []string{}
+	for _, ip := range cert.IPAddresses {
+		ipAddresses = append(ipAddresses, ip.String())
+	}
+	lines.AppendLines(labeledLines("IP SANs:", ipAddresses))
+	lines.AppendLines(labeledLines("Email SANs:", cert.EmailAddresses))
+	uris := []string{}
+	for _, uri := range cert.URIs {
+		uris = append(uris, uri.String())
+	}
+	lines.AppendLines(labeledLines("URI SANs:", uris))
+
+	lines.Print("Subject Key")
+	if c.HasSubjectKeyIDExtension() {
+		lines.AppendLines(labeledLines("ID:", []string{colonHex(cert.SubjectKeyId)}))
+	} else {
+		lines.AppendLines(labeledLines("ID:", []string{
+			"not present, computed from key:", colonHex(c.SubjectKeyID()),
+		}))
+	}
+	lines.Print("Authority")
+	lines.AppendLines(labeledLines("Key ID:", []string{colonHexOrNone(cert.AuthorityKeyId)}))
+
+	return lines
+}
+
+// labeledLines prints values aligned to the same column as the other info
+// lines, one per line.
+func labeledLines(label string, values []string) *Lines {
+	lines := NewLines()
+
+	if len(values) == 0 {
+		values = []string{"none"}
+	}
+
+	prefix := fmt.Sprintf("%-13s", label)
+	for _, value := range values {
+		lines.Print("%s%s", prefix, value)
+		prefix = strings.Repeat(" ", 13)
+	}
+	return lines
+}
+
+func colonHex(data []byte) string {
+	parts := []string{}
+	for _, b := range data {
+		parts = append(parts, fmt.Sprintf("%02X", b))
+	}
+	return strings.Join(parts, ":")
+}
+
+func colonHexOrNone(data []byte) string {
+	if len(data) == 0 {
+		return "not present"
+	}
+	return colonHex(data)
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"reflect"
+	"testing"
+)
+
+func TestValidationLevel(t *testing.T) {
+	tests := []struct {
+		policies []asn1.ObjectIdentifier
+		want     string
+	}{
+		{nil, ValidationLevelUnknown},
+		{[]asn1.ObjectIdentifier{oidAnyPolicy}, ValidationLevelUnknown},
+		{[]asn1.ObjectIdentifier{oidCABFDomainValidated}, ValidationLevelDV},
+		{[]asn1.ObjectIdentifier{oidCABFOrganizationValidated}, ValidationLevelOV},
+		{[]asn1.ObjectIdentifier{oidCABFIndividualValidated}, ValidationLevelIV},
+		{[]asn1.ObjectIdentifier{oidCABFOrganizationValidated, oidCABFExtendedValidation}, ValidationLevelEV},
+		{[]asn1.ObjectIdentifier{{2, 16, 840, 1, 114412, 2, 1}}, ValidationLevelEV},
+	}
+	for _, test := range tests {
+		cert := &Certificate{Certificate: &x509.Certificate{PolicyIdentifiers: test.policies}}
+		if got := cert.ValidationLevel(); got != test.want {
+			t.Errorf("%v: got %s, want %s", test.policies, got, test.want)
+		}
+	}
+}
+
+func TestReadableBasicConstraints(t *testing.T) {
+	critical := []pkix.Extension{{Id: oidExtensionBasicConstraints, Critical: true}}
+	tests := []struct {
+		cert *x509.Certificate
+		want string
+	}{
+		{&x509.Certificate{}, "not present"},
+		{&x509.Certificate{BasicConstraintsValid: true}, "CA: false"},
+		{&x509.Certificate{BasicConstraintsValid: true, IsCA: true, MaxPathLen: -1}, "CA: true, path length: unlimited"},
+		{&x509.Certificate{BasicConstraintsValid: true, IsCA: true, MaxPathLenZero: true}, "CA: true, path length: 0"},
+		{
+			&x509.Certificate{BasicConstraintsValid: true, IsCA: true, MaxPathLen: 2, Extensions: critical},
+			"CA: true, path length: 2 (critical)",
+		},
+	}
+	for _, test := range tests {
+		if got := (&Certificate{Certificate: test.cert}).ReadableBasicConstraints(); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
+
+func TestReadableKeyUsage(t *testing.T) {
+	cert := &Certificate{Certificate: &x509.Certificate{
+		KeyUsage:           x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsage(99)},
+		UnknownExtKeyUsage: []asn1.ObjectIdentifier{{1, 2, 3}},
+	}}
+
+	if got, want := cert.ReadableKeyUsage(), []string{"Digital Signature", "Certificate Sign"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("key usage: got %v, want %v", got, want)
+	}
+	want := []string{"TLS Web Server Authentication", "Unknown (99)", "1.2.3"}
+	if got := cert.ReadableExtKeyUsage(); !reflect.DeepEqual(got, want) {
+		t.Errorf("extended key usage: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,253 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DiffRiskHigh   = "high"
+	DiffRiskMedium = "medium"
+	DiffRiskNone   = "none"
+)
+
+// Difference is one change between an old and a new certificate or chain.
+type Difference struct {
+	Risk    string `json:"risk"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Old     string `json:"old,omitempty"`
+	New     string `json:"new,omitempty"`
+}
+
+type chainDiffer struct {
+	differences []Difference
+}
+
+func (d *chainDiffer) add(risk, field, oldValue, newValue, format string, a ...interface{}) {
+	d.differences = append(d.differences, Difference{
+		Risk:    risk,
+		Field:   field,
+		Message: fmt.Sprintf(format, a...),
+		Old:     oldValue,
+		New:     newValue,
+	})
+}
+
+// DiffChains lists what changes when oldChain is replaced by newChain, as
+// when renewing a certificate, riskiest changes first.
+func DiffChains(oldChain, newChain *CertificateChain) []Difference {
+	d := &chainDiffer{differences: []Difference{}}
+	oldCert, newCert := oldChain.Leaf, newChain.Leaf
+
+	if oldCert.ID() == newCert.ID() {
+		d.add(DiffRiskNone, "certificate", "", "", "Both sides have the same leaf certificate")
+	} else {
+		d.diffNames(oldCert, newCert)
+		d.diffValidity(oldCert, newCert)
+		d.diffKeys(oldCert, newCert)
+		d.diffSignatureAlgorithm(oldCert, newCert)
+		d.diffValidationLevel(oldCert, newCert)
+		d.diffIssuer(oldCert, newCert)
+	}
+	d.diffChainPath(oldChain, newChain)
+
+	riskOrder := map[string]int{DiffRiskHigh: 0, DiffRiskMedium: 1, DiffRiskNone: 2}
+	sort.SliceStable(d.differences, func(i, j int) bool {
+		return riskOrder[d.differences[i].Risk] < riskOrder[d.differences[j].Risk]
+	})
+	return d.differences
+}
+
+func (d *chainDiffer) diffNames(oldCert, newCert *Certificate) {
+	oldNames, newNames := oldCert.SANs(), newCert.SANs()
+	for _, name := range oldNames {
+		if !containsName(newNames, name) {
+			if newCert.CoversName(name) {
+				d.add(DiffRiskNone, "names", name, "",
+					"New certificate no longer lists %s, but still covers it", name)
+			} else {
+				d.add(DiffRiskHigh, "names", name, "", "New certificate drops %s", name)
+			}
+		}
+	}
+	for _, name := range newNames {
+		if !containsName(oldNames, name) {
+			d.add(DiffRiskNone, "names", "", name, "New certificate adds %s", name)
+		}
+	}
+
+	if oldCN, newCN := oldCert.Certificate.Subject.CommonName, newCert.Certificate.Subject.CommonName; oldCN != newCN {
+		d.add(DiffRiskNone, "subject", oldCN, newCN, "Common name changes from %s to %s", oldCN, newCN)
+	}
+}
+
+func (d *chainDiffer) diffValidity(oldCert, newCert *Certificate) {
+	now := time.Now()
+	oldNotAfter, newNotAfter := oldCert.Certificate.NotAfter, newCert.Certificate.NotAfter
+	newNotBefore := newCert.Certificate.NotBefore
+	format := func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04:05 MST") }
+
+	switch {
+	case newNotAfter.Before(now):
+		d.add(DiffRiskHigh, "validity", format(oldNotAfter), format(newNotAfter),
+			"New certificate has already expired, at %s", format(newNotAfter))
+	case newNotBefore.After(now):
+		d.add(DiffRiskHigh, "validity", "", format(newNotBefore),
+			"New certificate isn't valid yet, it starts at %s", format(newNotBefore))
+	case newNotAfter.Before(oldNotAfter):
+		d.add(DiffRiskMedium, "validity", format(oldNotAfter), format(newNotAfter),
+			"New certificate expires before the old one (%s instead of %s)",
+			format(newNotAfter), format(oldNotAfter))
+	case !newNotAfter.Equal(oldNotAfter):
+		d.add(DiffRiskNone, "validity", format(oldNotAfter), format(newNotAfter),
+			"Expiration moves from %s to %s", format(oldNotAfter), format(newNotAfter))
+	}
+}
+
+func (d *chainDiffer) diffKeys(oldCert, newCert *Certificate) {
+	if oldCert.SPKIHash() == newCert.SPKIHash() {
+		d.add(DiffRiskMedium, "key", "", "",
+			"New certificate reuses the old key; if the renewal is due to a key compromise, it doesn't help")
+		return
+	}
+
+	oldKey, newKey := oldCert.Certificate.PublicKey, newCert.Certificate.PublicKey
+	oldDescription, newDescription := publicKeyDescription(oldKey), publicKeyDescription(newKey)
+	d.add(DiffRiskNone, "key", oldCert.SPKIHash(), newCert.SPKIHash(),
+		"New key (SPKI pins must be updated)")
+
+	switch {
+	case publicKeyAlgorithmName(oldKey) != publicKeyAlgorithmName(newKey):
+		d.add(DiffRiskMedium, "key_type", oldDescription, newDescription,
+			"Key type changes from %s to %s; old clients may not support it",
+			oldDescription, newDescription)
+	case publicKeySize(newKey) < publicKeySize(oldKey):
+		d.add(DiffRiskMedium, "key_type", oldDescription, newDescription,
+			"Key gets weaker, from %s to %s", oldDescription, newDescription)
+	case oldDescription != newDescription:
+		d.add(DiffRiskNone, "key_type", oldDescription, newDescription,
+			"Key changes from %s to %s", oldDescription, newDescription)
+	}
+}
+
+func (d *chainDiffer) diffSignatureAlgorithm(oldCert, newCert *Certificate) {
+	oldAlgorithm, newAlgorithm := oldCert.ReadableSignatureAlgorithm(), newCert.ReadableSignatureAlgorithm()
+	if oldAlgorithm == newAlgorithm {
+		return
+	}
+
+	if isObsoleteSignatureAlgorithm(newCert.Certificate.SignatureAlgorithm) {
+		d.add(DiffRiskHigh, "signature_algorithm", oldAlgorithm, newAlgorithm,
+			"New certificate is signed with obsolete %s", newAlgorithm)
+	} else {
+		d.add(DiffRiskNone, "signature_algorithm", oldAlgorithm, newAlgorithm,
+			"Signature algorithm changes from %s to %s", oldAlgorithm, newAlgorithm)
+	}
+}
+
+func (d *chainDiffer) diffValidationLevel(oldCert, newCert *Certificate) {
+	oldLevel, newLevel := oldCert.ValidationLevel(), newCert.ValidationLevel()
+	switch {
+	case oldLevel == newLevel:
+	case oldLevel == ValidationLevelEV:
+		d.add(DiffRiskMedium, "validation", oldLevel, newLevel,
+			"New certificate loses EV status (%s instead)", newLevel)
+	default:
+		d.add(DiffRiskNone, "validation", oldLevel, newLevel,
+			"Validation level changes from %s to %s", oldLevel, newLevel)
+	}
+
+	oldPolicies := strings.Join(oldCert.ReadablePolicies(), ", ")
+	newPolicies := strings.Join(newCert.ReadablePolicies(), ", ")
+	if oldPolicies != newPolicies {
+		d.add(DiffRiskNone, "policies", oldPolicies, newPolicies, "Certificate policies change")
+	}
+}
+
+func (d *chainDiffer) diffIssuer(oldCert, newCert *Certificate) {
+	oldIssuer, newIssuer := oldCert.ReadableIssuer(), newCert.ReadableIssuer()
+	if oldIssuer != newIssuer {
+		d.add(DiffRiskMedium, "issuer", oldIssuer, newIssuer,
+			"Issuer changes from %s to %s; the served chain must change too", oldIssuer, newIssuer)
+	}
+}
+
+func (d *chainDiffer) diffChainPath(oldChain, newChain *CertificateChain) {
+	switch {
+	case len(oldChain.Intermediates) == 0 && len(newChain.Intermediates) == 0:
+		d.add(DiffRiskNone, "chain", "", "",
+			"Chain paths not compared, since neither side includes intermediates")
+		return
+	case len(newChain.Intermediates) == 0 && !newChain.Leaf.IsSelfSigned():
+		oldPath := chainPath(oldChain)
+		d.add(DiffRiskMedium, "chain", oldPath, "",
+			"New chain drops every intermediate served with the old one (%s); clients "+
+				"that don't have them cached won't find a path to a trusted root", oldPath)
+		return
+	case len(oldChain.Intermediates) == 0 || len(newChain.Intermediates) == 0:
+		d.add(DiffRiskNone, "chain", "", "",
+			"Chain paths not compared, since only one side includes intermediates")
+		return
+	}
+
+	oldPath, newPath := chainPath(oldChain), chainPath(newChain)
+	if oldPath != newPath {
+		d.add(DiffRiskMedium, "chain", oldPath, newPath,
+			"Chain path changes from %s to %s", oldPath, newPath)
+	}
+	if !newChain.Leaf.isIssuedBy(newChain.Intermediates[0]) {
+		d.add(DiffRiskHigh, "chain", "", newPath,
+			"New chain doesn't start with the new certificate's issuer")
+	}
+}
+
+func chainPath(chain *CertificateChain) string {
+	subjects := []string{}
+	for _, cert := range chain.Intermediates {
+		subjects = append(subjects, cert.ReadableSubject())
+	}
+	return strings.Join(subjects, " -> ")
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func DifferenceLines(differences []Difference, wrapLength int) *Lines {
+	lines := NewLines()
+
+	if len(differences) <= 0 {
+		lines.Print("No differences.")
+		return lines
+	}
+
+	counts := map[string]int{}
+	for _, difference := range differences {
+		counts[difference.Risk]++
+
+		label := "-"
+		if difference.Risk != DiffRiskNone {
+			label = strings.ToUpper(difference.Risk)
+		}
+		prefix := fmt.Sprintf("%-8s", label)
+		for _, line := range wordWrapLines(difference.Message, wrapLength-8) {
+			lines.Print("%s%s", prefix, line)
+			prefix = strings.Repeat(" ", 8)
+		}
+	}
+
+	lines.Print("")
+	lines.Print(
+		"%d high-risk, %d medium-risk and %d other changes.",
+		counts[DiffRiskHigh], counts[DiffRiskMedium], counts[DiffRiskNone])
+
+	return lines
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffChainsChainPath(t *testing.T) {
+	fixture := func(name string) *Certificate {
+		return loadCertificateFixture(t, filepath.Join("testdata", "pkcs12", name))
+	}
+	leaf, intermediate, root, unrelated := fixture("leaf.crt"), fixture("intermediate.crt"),
+		fixture("root.crt"), fixture("unrelated.crt")
+	served := &CertificateChain{Leaf: leaf, Intermediates: []*Certificate{intermediate}}
+
+	tests := []struct {
+		name     string
+		oldChain *CertificateChain
+		newChain *CertificateChain
+		want     []string
+	}{
+		{
+			name:     "same path",
+			oldChain: served,
+			newChain: &CertificateChain{Leaf: leaf, Intermediates: []*Certificate{intermediate}},
+			want:     []string{},
+		},
+		{
+			name:     "intermediates dropped",
+			oldChain: served,
+			newChain: &CertificateChain{Leaf: leaf},
+			want:     []string{DiffRiskMedium},
+		},
+		{
+			name:     "self-signed replacement needs no intermediates",
+			oldChain: served,
+			newChain: &CertificateChain{Leaf: unrelated},
+			want:     []string{DiffRiskNone},
+		},
+		{
+			name:     "intermediates added",
+			oldChain: &CertificateChain{Leaf: leaf},
+			newChain: served,
+			want:     []string{DiffRiskNone},
+		},
+		{
+			name:     "neither side has intermediates",
+			oldChain: &CertificateChain{Leaf: leaf},
+			newChain: &CertificateChain{Leaf: leaf},
+			want:     []string{DiffRiskNone},
+		},
+		{
+			name:     "wrong issuer first",
+			oldChain: served,
+			newChain: &CertificateChain{Leaf: leaf, Intermediates: []*Certificate{root, intermediate}},
+			want:     []string{DiffRiskHigh, DiffRiskMedium},
+		},
+	}
+	for _, test := range tests {
+		got := []string{}
+		for _, difference := range DiffChains(test.oldChain, test.newChain) {
+			if difference.Field == "chain" {
+				got = append(got, difference.Risk)
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got chain risks %v, want %v", test.name, got, test.want)
+		}
+	}
+}
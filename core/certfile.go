@@ -0,0 +1,189 @@
+package core
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+type FileContents struct {
+	Path                string
+	Format              string
+	Certificates        []*Certificate
+	PrivateKeys         []crypto.PrivateKey
+	CertificateRequests []*CertificateRequest
+}
+
+func LoadFileContents(path string) (*FileContents, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := &FileContents{Path: path}
+	if err := rv.parse(data); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", path, err)
+	}
+	return rv, nil
+}
+
+func (f *FileContents) IsEmpty() bool {
+	return len(f.Certificates) == 0 &&
+		len(f.PrivateKeys) == 0 &&
+		len(f.CertificateRequests) == 0
+}
+
+func (f *FileContents) Summary() string {
+	parts := []string{}
+	if n := len(f.Certificates); n > 0 {
+		parts = append(parts, pluralize(n, "certificate"))
+	}
+	if n := len(f.PrivateKeys); n > 0 {
+		parts = append(parts, pluralize(n, "private key"))
+	}
+	if n := len(f.CertificateRequests); n > 0 {
+		parts = append(parts, pluralize(n, "certificate request"))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "nothing usable")
+	}
+	return fmt.Sprintf("%s: %s, %s", f.Path, f.Format, strings.Join(parts, ", "))
+}
+
+func (f *FileContents) parse(data []byte) error {
+	if block, _ := pem.Decode(data); block != nil {
+		f.Format = "PEM"
+		return f.parsePEM(data)
+	}
+
+	attempts := []keyParseAttempt{}
+
+	if certs, err := x509.ParseCertificates(data); err == nil {
+		f.Format = "DER certificate"
+		f.addCertificates(certs)
+		return nil
+	} else {
+		attempts = append(attempts, keyParseAttempt{"DER certificate", err})
+	}
+	if certs, err := parsePKCS7Certificates(data); err == nil {
+		f.Format = "PKCS#7"
+		f.addCertificates(certs)
+		return nil
+	} else {
+		attempts = append(attempts, keyParseAttempt{"PKCS#7", err})
+	}
+	if csr, err := x509.ParseCertificateRequest(data); err == nil {
+		f.Format = "DER certificate request"
+		f.CertificateRequests = append(f.CertificateRequests, &CertificateRequest{Request: csr})
+		return nil
+	} else {
+		attempts = append(attempts, keyParseAttempt{"DER certificate request", err})
+	}
+	if key, err := parsePrivateKey(data, f.Path); err == nil {
+		f.Format = "DER private key"
+		f.PrivateKeys = append(f.PrivateKeys, key)
+		return nil
+	} else if parseErr, ok := err.(PrivateKeyParseError); ok {
+		for _, attempt := range parseErr.attempts {
+			attempts = append(attempts, keyParseAttempt{
+				"DER private key (" + attempt.format + ")", attempt.err,
+			})
+		}
+	} else {
+		return err
+	}
+	if blocks, err := pkcs12ToPEM(data, ""); err == nil {
+		f.Format = "PKCS#12"
+		return f.parsePEM(pemBlocksToBytes(blocks))
+	} else if err == errIncorrectPassphrase {
+		blocks, err := f.decryptPKCS12(data)
+		if err != nil {
+			return fmt.Errorf("Unable to decrypt PKCS#12 archive: %s", err)
+		}
+		f.Format = "PKCS#12"
+		return f.parsePEM(pemBlocksToBytes(blocks))
+	} else {
+		attempts = append(attempts, keyParseAttempt{"PKCS#12", err})
+	}
+
+	lines := []string{"Unrecognized file format. Formats tried:"}
+	for _, attempt := range attempts {
+		lines = append(lines, fmt.Sprintf("  - %s: %s", attempt.format, attempt.err))
+	}
+	return fmt.Errorf("%s", strings.Join(lines, "\n"))
+}
+
+func (f *FileContents) parsePEM(data []byte) error {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		switch {
+		case block.Type == "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return fmt.Errorf("Unable to parse certificate: %s", err)
+			}
+			f.addCertificates([]*x509.Certificate{cert})
+		case block.Type == "PKCS7":
+			certs, err := parsePKCS7Certificates(block.Bytes)
+			if err != nil {
+				return fmt.Errorf("Unable to parse PKCS#7 block: %s", err)
+			}
+			f.addCertificates(certs)
+		case block.Type == "CERTIFICATE REQUEST", block.Type == "NEW CERTIFICATE REQUEST":
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			if err != nil {
+				return fmt.Errorf("Unable to parse certificate request: %s", err)
+			}
+			f.CertificateRequests = append(f.CertificateRequests, &CertificateRequest{Request: csr})
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			key, err := parsePrivateKey(pem.EncodeToMemory(block), f.Path)
+			if err != nil {
+				return err
+			}
+			f.PrivateKeys = append(f.PrivateKeys, key)
+		default:
+			warning("Ignoring unknown PEM block '%s' in %s\n", block.Type, f.Path)
+		}
+	}
+	return nil
+}
+
+func (f *FileContents) addCertificates(certs []*x509.Certificate) {
+	for _, cert := range certs {
+		f.Certificates = append(f.Certificates, &Certificate{
+			Certificate: cert,
+			Source:      f.Path,
+		})
+	}
+}
+
+func (f *FileContents) decryptPKCS12(data []byte) ([]*pem.Block, error) {
+	passphrase, err := keyPassphrase(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	return pkcs12ToPEM(data, string(passphrase))
+}
+
+func pemBlocksToBytes(blocks []*pem.Block) []byte {
+	rv := []byte{}
+	for _, block := range blocks {
+		rv = append(rv, pem.EncodeToMemory(block)...)
+	}
+	return rv
+}
+
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// ID is the canonical identity of a certificate: the SHA-256 fingerprint of
+// its DER encoding in bare lowercase hex, used as a key in JSON output and
+// config files. The fingerprints meant for display, SHA256Fingerprint and
+// SHA1Fingerprint, are in OpenSSL's colon-separated uppercase hex instead, so
+// they can be compared with other tooling as they are.
+func (c *Certificate) ID() string {
+	sum := sha256.Sum256(c.Certificate.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Certificate) SHA256Fingerprint() string {
+	sum := sha256.Sum256(c.Certificate.Raw)
+	return colonHex(sum[:])
+}
+
+func (c *Certificate) SHA1Fingerprint() string {
+	sum := sha1.Sum(c.Certificate.Raw)
+	return colonHex(sum[:])
+}
+
+// shortSHA256Fingerprint is the first 4 bytes of the SHA-256 fingerprint,
+// enough to tell apart certificates sharing a name and key.
+func (c *Certificate) shortSHA256Fingerprint() string {
+	sum := sha256.Sum256(c.Certificate.Raw)
+	return colonHex(sum[:4])
+}
+
+// SPKIHash is the base64-encoded SHA-256 hash of the certificate's
+// SubjectPublicKeyInfo, as used by HPKP and most pinning libraries.
+func (c *Certificate) SPKIHash() string {
+	sum := sha256.Sum256(c.Certificate.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (c *Certificate) SPKIPin() string {
+	return SPKIPinFromHash(c.SPKIHash())
+}
+
+func SPKIPinFromHash(hash string) string {
+	return `pin-sha256="` + hash + `"`
+}
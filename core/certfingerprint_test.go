@@ -0,0 +1,35 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// The expected values come from OpenSSL:
+//
+//	openssl x509 -in leaf.crt -noout -fingerprint -sha256
+//	openssl x509 -in leaf.crt -noout -fingerprint -sha1
+//	openssl x509 -in leaf.crt -pubkey -noout | openssl pkey -pubin -outform der |
+//	    openssl dgst -sha256 -binary | base64
+func TestCertificateFingerprints(t *testing.T) {
+	cert := loadCertificateFixture(t, filepath.Join("testdata", "pkcs12", "leaf.crt"))
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"ID", cert.ID(), "418725f3c72ad9f540e03b12dca2d7a062f1cf5e360ee577e6c7a06fa34777f8"},
+		{"SHA256Fingerprint", cert.SHA256Fingerprint(),
+			"41:87:25:F3:C7:2A:D9:F5:40:E0:3B:12:DC:A2:D7:A0:62:F1:CF:5E:36:0E:E5:77:E6:C7:A0:6F:A3:47:77:F8"},
+		{"SHA1Fingerprint", cert.SHA1Fingerprint(), "27:11:4F:41:9C:91:DA:99:1D:8B:17:81:B1:9C:D4:93:8C:3E:2E:91"},
+		{"SPKIHash", cert.SPKIHash(), "qs332EHKevMLCUTX3bi/MidFQBwNhquPb+fQ6KFcaDY="},
+		{"ReadableSubject", cert.ReadableSubject(), "d1f08a6e (leaf.example.test) [SHA-256 41:87:25:F3]"},
+		{"SPKIPin", cert.SPKIPin(), `pin-sha256="qs332EHKevMLCUTX3bi/MidFQBwNhquPb+fQ6KFcaDY="`},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, test.got, test.want)
+		}
+	}
+}
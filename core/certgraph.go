@@ -0,0 +1,406 @@
+package core
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of certificate graph nodes, by where the certificate came from.
+const (
+	GraphNodeLeaf    = "leaf"
+	GraphNodeServed  = "served"
+	GraphNodeAIA     = "aia"
+	GraphNodeRoot    = "root"
+	GraphNodeMissing = "missing"
+)
+
+// maxGraphAIAFetches bounds the AIA requests made while building a graph, in
+// case issuers point at each other.
+const maxGraphAIAFetches = 10
+
+// CertificateGraph holds every certificate that could take part in a chain,
+// with an edge from each certificate to each certificate that issued it.
+// Cross-signed certificates give a node several issuers, and so several
+// paths to a root.
+type CertificateGraph struct {
+	Nodes []*GraphNode
+	Edges []*GraphEdge
+
+	// Errors are AIA fetches that failed.
+	Errors []string
+}
+
+// GraphNode is a certificate, or for GraphNodeMissing, an issuer that
+// couldn't be found, in which case Certificate is nil.
+type GraphNode struct {
+	Name        string
+	Kind        string
+	Certificate *Certificate
+
+	missingIssuerOf *Certificate
+}
+
+// GraphEdge goes from a certificate to its issuer. Problem is empty for
+// sound links; otherwise the link is broken. InPath is set for links on a
+// path that verifies.
+type GraphEdge struct {
+	From    *GraphNode
+	To      *GraphNode
+	Problem string
+	InPath  bool
+}
+
+// NewCertificateGraph builds the graph around a chain. With fetchAIA, the
+// issuers listed in the certificates' AIA extensions are downloaded too.
+func NewCertificateGraph(chain *CertificateChain, fetchAIA bool) *CertificateGraph {
+	g := &CertificateGraph{}
+	g.addCertificate(chain.Leaf, GraphNodeLeaf)
+	for _, cert := range chain.Intermediates {
+		kind := GraphNodeServed
+		if cert.IsTrusted() && cert.IsSelfSigned() {
+			kind = GraphNodeRoot
+		}
+		g.addCertificate(cert, kind)
+	}
+
+	if fetchAIA {
+		g.fetchAIAIssuers()
+	}
+	g.addRoots()
+	g.link()
+	return g
+}
+
+func (g *CertificateGraph) addCertificate(cert *Certificate, kind string) *GraphNode {
+	for _, node := range g.Nodes {
+		if node.Certificate != nil && bytes.Equal(node.Certificate.Certificate.Raw, cert.Certificate.Raw) {
+			return node
+		}
+	}
+	node := &GraphNode{
+		Name:        fmt.Sprintf("n%d", len(g.Nodes)+1),
+		Kind:        kind,
+		Certificate: cert,
+	}
+	g.Nodes = append(g.Nodes, node)
+	return node
+}
+
+func (g *CertificateGraph) fetchAIAIssuers() {
+	fetched := map[string]bool{}
+	// Nodes fetched here are appended while iterating, so their own issuers
+	// get fetched too.
+	for index := 0; index < len(g.Nodes); index++ {
+		cert := g.Nodes[index].Certificate
+		if cert.IsSelfSigned() {
+			continue
+		}
+		for _, url := range cert.Certificate.IssuingCertificateURL {
+			if fetched[url] || len(fetched) >= maxGraphAIAFetches {
+				continue
+			}
+			fetched[url] = true
+
+			issuer, err := CertificateFromURL(url)
+			if err != nil {
+				g.Errors = append(g.Errors, err.Error())
+				continue
+			}
+			issuer.Source = "AIA " + url
+			kind := GraphNodeAIA
+			if issuer.IsTrusted() && issuer.IsSelfSigned() {
+				kind = GraphNodeRoot
+			}
+			g.addCertificate(issuer, kind)
+		}
+	}
+}
+
+// addRoots adds the roots that verified paths end at, and extra trusted
+// roots that issued any of the certificates.
+func (g *CertificateGraph) addRoots() {
+	intermediates := x509.NewCertPool()
+	for _, node := range g.Nodes {
+		intermediates.AddCert(node.Certificate.Certificate)
+	}
+
+	for _, node := range append([]*GraphNode{}, g.Nodes...) {
+		if node.Certificate.IsSelfSigned() {
+			continue
+		}
+		paths, _ := node.Certificate.Certificate.Verify(x509.VerifyOptions{
+			Roots:         MustCertPool(),
+			Intermediates: intermediates,
+			CurrentTime:   time.Now(),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		for _, path := range paths {
+			root := path[len(path)-1]
+			g.addCertificate(&Certificate{Certificate: root}, GraphNodeRoot)
+		}
+
+		for _, root := range trustedRoots {
+			candidate := &Certificate{Certificate: root}
+			if node.Certificate.isIssuedBy(candidate) {
+				g.addCertificate(candidate, GraphNodeRoot)
+			}
+		}
+	}
+}
+
+// link adds the edges: sound ones wherever a signature checks out, broken
+// ones for issuers that only match by name, and for issuers that are missing
+// altogether.
+func (g *CertificateGraph) link() {
+	now := time.Now()
+	certNodes := append([]*GraphNode{}, g.Nodes...)
+	for _, node := range certNodes {
+		cert := node.Certificate
+		if cert.IsSelfSigned() {
+			continue
+		}
+
+		found := false
+		for _, issuer := range certNodes {
+			if issuer == node || !bytes.Equal(cert.Certificate.RawIssuer, issuer.Certificate.Certificate.RawSubject) {
+				continue
+			}
+			found = true
+
+			edge := &GraphEdge{From: node, To: issuer}
+			switch {
+			case !cert.isIssuedBy(issuer.Certificate):
+				edge.Problem = "bad signature"
+			case now.After(issuer.Certificate.Certificate.NotAfter):
+				edge.Problem = "issuer expired"
+			case !issuer.Certificate.Certificate.IsCA:
+				edge.Problem = "issuer isn't a CA"
+			}
+			g.Edges = append(g.Edges, edge)
+		}
+
+		if !found && !node.Trusted() {
+			missing := &GraphNode{
+				Name:            fmt.Sprintf("n%d", len(g.Nodes)+1),
+				Kind:            GraphNodeMissing,
+				missingIssuerOf: cert,
+			}
+			g.Nodes = append(g.Nodes, missing)
+			g.Edges = append(g.Edges, &GraphEdge{From: node, To: missing, Problem: "issuer not found"})
+		}
+	}
+
+	g.markPaths()
+}
+
+// markPaths flags the edges of every path from the leaf to a trusted root
+// that only goes through sound links.
+func (g *CertificateGraph) markPaths() {
+	var walk func(node *GraphNode, visited map[*GraphNode]bool) bool
+	walk = func(node *GraphNode, visited map[*GraphNode]bool) bool {
+		if node.Trusted() {
+			return true
+		}
+		visited[node] = true
+		defer delete(visited, node)
+
+		reachesRoot := false
+		for _, edge := range g.Edges {
+			if edge.From != node || edge.Problem != "" || visited[edge.To] {
+				continue
+			}
+			if walk(edge.To, visited) {
+				edge.InPath = true
+				reachesRoot = true
+			}
+		}
+		return reachesRoot
+	}
+	walk(g.Nodes[0], map[*GraphNode]bool{})
+}
+
+// Expired reports whether the node's certificate has expired.
+func (n *GraphNode) Expired() bool {
+	return n.Certificate != nil && time.Now().After(n.Certificate.Certificate.NotAfter)
+}
+
+// Trusted reports whether the node is a trusted root. Cross-signed
+// certificates share a trusted root's subject and key, but aren't
+// themselves roots.
+func (n *GraphNode) Trusted() bool {
+	return n.Certificate != nil && n.Certificate.IsSelfSigned() && n.Certificate.IsTrusted()
+}
+
+// Label describes the node in a few short lines.
+func (n *GraphNode) Label() []string {
+	if n.Kind == GraphNodeMissing {
+		issuer := n.missingIssuerOf.Certificate.Issuer.CommonName
+		if issuer == "" {
+			issuer = n.missingIssuerOf.Certificate.Issuer.String()
+		}
+		return []string{issuer, "issuer not found"}
+	}
+
+	cert := n.Certificate
+	name := cert.Certificate.Subject.CommonName
+	if name == "" {
+		name = cert.Certificate.Subject.String()
+	}
+	lines := []string{
+		name,
+		fmt.Sprintf("%s, %x", graphNodeKindNames[n.Kind], shortKeyID(cert.SubjectKeyID())),
+	}
+
+	expiry := cert.Certificate.NotAfter.UTC().Format("2006-01-02")
+	if n.Expired() {
+		lines = append(lines, "EXPIRED "+expiry)
+	} else {
+		lines = append(lines, "expires "+expiry)
+	}
+
+	switch {
+	case n.Trusted() && cert.IsBundled():
+		lines = append(lines, "trusted (bundled)")
+	case n.Trusted():
+		lines = append(lines, "trusted (trust store)")
+	case cert.IsSelfSigned():
+		lines = append(lines, "not trusted")
+	}
+	return lines
+}
+
+var graphNodeKindNames = map[string]string{
+	GraphNodeLeaf:   "leaf",
+	GraphNodeServed: "served",
+	GraphNodeAIA:    "fetched via AIA",
+	GraphNodeRoot:   "root",
+}
+
+// DOT renders the graph for Graphviz, e.g. with "dot -Tsvg".
+func (g *CertificateGraph) DOT() string {
+	out := &strings.Builder{}
+	fmt.Fprintln(out, "digraph chain {")
+	fmt.Fprintln(out, `  node [shape=box, style="rounded,filled", fontname="Helvetica", fontsize=10];`)
+	fmt.Fprintln(out, `  edge [fontname="Helvetica", fontsize=9];`)
+
+	for _, node := range g.Nodes {
+		attributes := []string{
+			fmt.Sprintf("label=%s", dotQuote(strings.Join(node.Label(), "\n"))),
+			fmt.Sprintf("fillcolor=%s", dotQuote(graphNodeColors[node.Kind])),
+		}
+		switch {
+		case node.Kind == GraphNodeMissing:
+			attributes = append(attributes, `style="rounded,dashed"`, `color="#cf222e"`)
+		case node.Expired():
+			attributes = append(attributes, `color="#cf222e"`, "penwidth=2")
+		case node.Trusted():
+			attributes = append(attributes, `color="#1a7f37"`, "penwidth=2")
+		}
+		fmt.Fprintf(out, "  %s [%s];\n", node.Name, strings.Join(attributes, ", "))
+	}
+
+	for _, edge := range g.sortedEdges() {
+		attributes := []string{}
+		switch {
+		case edge.Problem != "":
+			attributes = append(attributes, fmt.Sprintf("label=%s", dotQuote(edge.Problem)),
+				`color="#cf222e"`, `fontcolor="#cf222e"`, "style=dashed")
+		case edge.InPath:
+			attributes = append(attributes, "penwidth=2")
+		default:
+			attributes = append(attributes, `color="#888888"`)
+		}
+		fmt.Fprintf(out, "  %s -> %s [%s];\n", edge.From.Name, edge.To.Name, strings.Join(attributes, ", "))
+	}
+
+	fmt.Fprintln(out, "}")
+	return out.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart, which Markdown
+// renderers such as GitHub's display inline.
+func (g *CertificateGraph) Mermaid() string {
+	out := &strings.Builder{}
+	fmt.Fprintln(out, "flowchart TD")
+
+	for _, node := range g.Nodes {
+		label := []string{}
+		for _, line := range node.Label() {
+			label = append(label, mermaidEscape(line))
+		}
+		fmt.Fprintf(out, "  %s[\"%s\"]\n", node.Name, strings.Join(label, "<br/>"))
+	}
+
+	brokenLinks := []string{}
+	for index, edge := range g.sortedEdges() {
+		switch {
+		case edge.Problem != "":
+			fmt.Fprintf(out, "  %s -. \"%s\" .-> %s\n", edge.From.Name, mermaidEscape(edge.Problem), edge.To.Name)
+			brokenLinks = append(brokenLinks, fmt.Sprintf("%d", index))
+		case edge.InPath:
+			fmt.Fprintf(out, "  %s ==> %s\n", edge.From.Name, edge.To.Name)
+		default:
+			fmt.Fprintf(out, "  %s --> %s\n", edge.From.Name, edge.To.Name)
+		}
+	}
+
+	for _, kind := range []string{GraphNodeLeaf, GraphNodeServed, GraphNodeAIA, GraphNodeRoot} {
+		fmt.Fprintf(out, "  classDef %s fill:%s\n", kind, graphNodeColors[kind])
+	}
+	fmt.Fprintln(out, "  classDef missing fill:#ffffff,stroke:#cf222e,stroke-dasharray:5 5")
+	fmt.Fprintln(out, "  classDef expired stroke:#cf222e,stroke-width:3px")
+	fmt.Fprintln(out, "  classDef trusted stroke:#1a7f37,stroke-width:3px")
+	for _, node := range g.Nodes {
+		classes := node.Kind
+		if node.Expired() {
+			classes += ",expired"
+		} else if node.Trusted() {
+			classes += ",trusted"
+		}
+		fmt.Fprintf(out, "  class %s %s\n", node.Name, classes)
+	}
+	if len(brokenLinks) > 0 {
+		fmt.Fprintf(out, "  linkStyle %s stroke:#cf222e,color:#cf222e\n", strings.Join(brokenLinks, ","))
+	}
+	return out.String()
+}
+
+var graphNodeColors = map[string]string{
+	GraphNodeLeaf:    "#ddf4ff",
+	GraphNodeServed:  "#ffffff",
+	GraphNodeAIA:     "#fff8c5",
+	GraphNodeRoot:    "#dafbe1",
+	GraphNodeMissing: "#ffffff",
+}
+
+// sortedEdges orders edges by their nodes, so output is stable.
+func (g *CertificateGraph) sortedEdges() []*GraphEdge {
+	index := map[*GraphNode]int{}
+	for i, node := range g.Nodes {
+		index[node] = i
+	}
+	edges := append([]*GraphEdge{}, g.Edges...)
+	sort.SliceStable(edges, func(i, j int) bool {
+		if index[edges[i].From] != index[edges[j].From] {
+			return index[edges[i].From] < index[edges[j].From]
+		}
+		return index[edges[i].To] < index[edges[j].To]
+	})
+	return edges
+}
+
+func dotQuote(text string) string {
+	text = strings.Replace(text, `\`, `\\`, -1)
+	text = strings.Replace(text, `"`, `\"`, -1)
+	return `"` + strings.Replace(text, "\n", `\n`, -1) + `"`
+}
+
+func mermaidEscape(text string) string {
+	text = strings.Replace(text, `"`, "#quot;", -1)
+	text = strings.Replace(text, "<", "#lt;", -1)
+	return strings.Replace(text, ">", "#gt;", -1)
+}
@@ -0,0 +1,169 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// graphNode returns the node holding cert.
+func graphNode(t *testing.T, g *CertificateGraph, cert *Certificate) *GraphNode {
+	t.Helper()
+	for _, node := range g.Nodes {
+		if node.Certificate != nil && node.Certificate.ID() == cert.ID() {
+			return node
+		}
+	}
+	t.Fatalf("%s isn't in the graph", cert.ReadableSubject())
+	return nil
+}
+
+// graphEdge returns the edge from one node to another.
+func graphEdge(t *testing.T, g *CertificateGraph, from, to *GraphNode) *GraphEdge {
+	t.Helper()
+	for _, edge := range g.Edges {
+		if edge.From == from && edge.To == to {
+			return edge
+		}
+	}
+	t.Fatalf("no edge from %s to %s", from.Name, to.Name)
+	return nil
+}
+
+func TestCertificateGraphCrossSigned(t *testing.T) {
+	f := &TestPKIFixture{}
+	if err := buildCrossSignedRootPKI(f); err != nil {
+		t.Fatal(err)
+	}
+	intermediate, crossSigned := f.Chain[0], f.Chain[1]
+	oldRoot, newRoot := f.Roots[0], f.Extra["new-root.crt"]
+	useTestRoots(t, oldRoot, newRoot)
+
+	// An impostor has the intermediate's name but another key.
+	impostor, err := newTestCertificate("Test Intermediate", true, newRoot, testCertificateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewCertificateGraph(&CertificateChain{
+		Leaf:          f.Leaf,
+		Intermediates: []*Certificate{intermediate, crossSigned, impostor},
+	}, false)
+	if len(g.Nodes) != 6 {
+		t.Errorf("got %d nodes, want 6", len(g.Nodes))
+	}
+	kinds := map[*Certificate]string{
+		f.Leaf:       GraphNodeLeaf,
+		intermediate: GraphNodeServed,
+		crossSigned:  GraphNodeServed,
+		impostor:     GraphNodeServed,
+		oldRoot:      GraphNodeRoot,
+		newRoot:      GraphNodeRoot,
+	}
+	for cert, kind := range kinds {
+		if node := graphNode(t, g, cert); node.Kind != kind {
+			t.Errorf("%s: got kind %s, want %s", cert.ReadableSubject(), node.Kind, kind)
+		}
+	}
+	if graphNode(t, g, crossSigned).Trusted() || !graphNode(t, g, newRoot).Trusted() {
+		t.Errorf("only the self-signed new root should be trusted")
+	}
+
+	// The intermediate reaches a root both directly and through the
+	// cross-sign, so both paths are marked. The impostor's links are sound,
+	// but the leaf can't reach them.
+	edges := []struct {
+		from, to *Certificate
+		problem  string
+		inPath   bool
+	}{
+		{f.Leaf, intermediate, "", true},
+		{f.Leaf, impostor, "bad signature", false},
+		{intermediate, crossSigned, "", true},
+		{intermediate, newRoot, "", true},
+		{crossSigned, oldRoot, "", true},
+		{impostor, crossSigned, "", false},
+		{impostor, newRoot, "", false},
+	}
+	if len(g.Edges) != len(edges) {
+		t.Errorf("got %d edges, want %d", len(g.Edges), len(edges))
+	}
+	for _, want := range edges {
+		edge := graphEdge(t, g, graphNode(t, g, want.from), graphNode(t, g, want.to))
+		if edge.Problem != want.problem || edge.InPath != want.inPath {
+			t.Errorf("%s -> %s: got problem %q and in path %v, want %q and %v",
+				want.from.Certificate.Subject.CommonName, want.to.Certificate.Subject.CommonName,
+				edge.Problem, edge.InPath, want.problem, want.inPath)
+		}
+	}
+
+	// linkStyle counts links in the order they're written.
+	brokenLinks := []string{}
+	for index, edge := range g.sortedEdges() {
+		if edge.Problem != "" {
+			brokenLinks = append(brokenLinks, fmt.Sprintf("%d", index))
+		}
+	}
+	mermaid := g.Mermaid()
+	if want := fmt.Sprintf("  linkStyle %s stroke:", strings.Join(brokenLinks, ",")); !strings.Contains(mermaid, want) {
+		t.Errorf("got Mermaid without %q:\n%s", want, mermaid)
+	}
+	links := 0
+	for _, line := range strings.Split(mermaid, "\n") {
+		if strings.Contains(line, "-->") || strings.Contains(line, "==>") || strings.Contains(line, ".->") {
+			links++
+		}
+	}
+	if links != len(edges) {
+		t.Errorf("got %d Mermaid links, want %d", links, len(edges))
+	}
+}
+
+func TestCertificateGraphMissingIssuer(t *testing.T) {
+	f := &TestPKIFixture{}
+	if err := buildTestPKI(f, 1, testCertificateOptions{}, testCertificateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	useTestRoots(t)
+
+	g := NewCertificateGraph(&CertificateChain{Leaf: f.Leaf}, false)
+	if len(g.Nodes) != 2 || len(g.Edges) != 1 {
+		t.Fatalf("got %d nodes and %d edges, want 2 and 1", len(g.Nodes), len(g.Edges))
+	}
+	missing := g.Nodes[1]
+	if missing.Kind != GraphNodeMissing || missing.Certificate != nil {
+		t.Errorf("got node %+v, want a missing issuer", missing)
+	}
+	if edge := g.Edges[0]; edge.To != missing || edge.Problem != "issuer not found" || edge.InPath {
+		t.Errorf("got edge %+v", edge)
+	}
+	label := missing.Label()
+	if len(label) != 2 || label[0] != f.Leaf.Certificate.Issuer.CommonName || label[1] != "issuer not found" {
+		t.Errorf("got label %q", label)
+	}
+	if mermaid := g.Mermaid(); !strings.Contains(mermaid, `n1 -. "issuer not found" .-> n2`) ||
+		!strings.Contains(mermaid, "linkStyle 0 ") {
+		t.Errorf("got Mermaid:\n%s", mermaid)
+	}
+}
+
+func TestCertificateGraphEscaping(t *testing.T) {
+	root, err := newTestCertificate(`Test "Quoted" <b>Root</b> \ CA`, true, nil, testCertificateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTestRoots(t)
+
+	g := NewCertificateGraph(&CertificateChain{Leaf: root}, false)
+	dot := g.DOT()
+	if want := `label="Test \"Quoted\" <b>Root</b> \\ CA\nleaf, `; !strings.Contains(dot, want) {
+		t.Errorf("got DOT without %q:\n%s", want, dot)
+	}
+	mermaid := g.Mermaid()
+	if want := `n1["Test #quot;Quoted#quot; #lt;b#gt;Root#lt;/b#gt; \ CA<br/>leaf, `; !strings.Contains(mermaid, want) {
+		t.Errorf("got Mermaid without %q:\n%s", want, mermaid)
+	}
+	if strings.Contains(mermaid, "<b>") {
+		t.Errorf("got unescaped HTML in Mermaid:\n%s", mermaid)
+	}
+}
@@ -1,28 +1,32 @@
 package core
 
 import (
-	"bytes"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"fmt"
 	"time"
 )
 
+// ReadableSubject leads with the key ID, which matches the key ID shown by
+// ReadableIssuer for the certificates it issued, and ends with a fingerprint
+// prefix, since renewals and cross-signs share the key ID.
 func (c *Certificate) ReadableSubject() string {
-	return fmt.Sprintf("%x (%s)", c.Certificate.SubjectKeyId[:4], c.Certificate.Subject.CommonName)
+	return fmt.Sprintf("%x (%s) [SHA-256 %s]",
+		shortKeyID(c.SubjectKeyID()), c.Certificate.Subject.CommonName, c.shortSHA256Fingerprint())
 }
 
 func (c *Certificate) ReadableIssuer() string {
-	if c.Certificate.AuthorityKeyId == nil {
-		return "Unsigned"
+	if c.IsSelfSigned() {
+		return "Self-signed"
 	}
 
-	if bytes.Compare(c.Certificate.AuthorityKeyId, c.Certificate.SubjectKeyId) == 0 {
-		return "Self-signed"
+	if len(c.Certificate.AuthorityKeyId) == 0 {
+		return fmt.Sprintf("[no key ID] (%s)", c.Certificate.Issuer.CommonName)
 	}
 
-	return fmt.Sprintf("%x (%s)", c.Certificate.AuthorityKeyId[:4], c.Certificate.Issuer.CommonName)
+	return fmt.Sprintf("%x (%s)", shortKeyID(c.Certificate.AuthorityKeyId), c.Certificate.Issuer.CommonName)
 }
 
 func (c *Certificate) ReadableExpiration() string {
@@ -35,9 +39,10 @@ func (c *Certificate) ReadableSignatureAlgorithm() string {
 
 func (c *Certificate) ReadablePublicKeyAlgorithm() string {
 	mapping := map[x509.PublicKeyAlgorithm]string{
-		x509.RSA:   "RSA",
-		x509.DSA:   "DSA",
-		x509.ECDSA: "ECDSA",
+		x509.RSA:     "RSA",
+		x509.DSA:     "DSA",
+		x509.ECDSA:   "ECDSA",
+		x509.Ed25519: "Ed25519",
 	}
 
 	name, ok := mapping[c.Certificate.PublicKeyAlgorithm]
@@ -53,7 +58,10 @@ func (c *Certificate) ReadableKeyBitLength() string {
 	case *rsa.PublicKey:
 		return fmt.Sprintf("%d", publicKey.N.BitLen())
 	case *ecdsa.PublicKey:
-		return fmt.Sprintf("%d", publicKey.X.BitLen())
+		params := publicKey.Curve.Params()
+		return fmt.Sprintf("%d (curve %s)", params.BitSize, params.Name)
+	case ed25519.PublicKey:
+		return fmt.Sprintf("%d", len(publicKey)*8)
 	default:
 		return "[error: unsupported signature algorithm]"
 	}
@@ -63,34 +71,54 @@ func (c *Certificate) IsBundled() bool {
 	return certInPool(c.Certificate)
 }
 
+// IsTrusted reports whether the certificate is a root, either bundled or
+// from an extra trust store.
+func (c *Certificate) IsTrusted() bool {
+	return c.IsBundled() || certInTrustStore(c.Certificate)
+}
+
 func (c *Certificate) DaysToExpire() float64 {
 	return c.Certificate.NotAfter.Sub(time.Now()).Hours() / 24
 }
 
 func (c *Certificate) InfoLines(wrapLength int) *Lines {
+	return c.infoLines(wrapLength, false, c.Warnings())
+}
+
+func (c *Certificate) VerboseInfoLines(wrapLength int) *Lines {
+	return c.infoLines(wrapLength, true, c.Warnings())
+}
+
+func (c *Certificate) infoLines(wrapLength int, verbose bool, warnings []Warning) *Lines {
 	lines := NewLines()
 
 	lines.Print("Subject:     %s", c.ReadableSubject())
 	lines.Print("Issuer:      %s", c.ReadableIssuer())
+	if c.Source != "" {
+		lines.Print("Source:      %s", c.Source)
+	}
 	lines.Print("Bundled in")
 	lines.Print("browsers?    %v", c.IsBundled())
 	lines.Print("Expires in:  %s", c.ReadableExpiration())
 	lines.Print("Sig. Algo.:  %s", c.ReadableSignatureAlgorithm())
 	lines.Print("Key Algo.:   %s", c.ReadablePublicKeyAlgorithm())
 	lines.Print("Bit Length:  %s", c.ReadableKeyBitLength())
+	lines.Print("SHA-256:     %s", c.SHA256Fingerprint())
+	lines.Print("SPKI Pin:    %s", c.SPKIPin())
 	lines.AppendLines(c.domainLines(wrapLength))
-	lines.AppendLines(c.warningLines(wrapLength))
+	if verbose {
+		lines.AppendLines(c.detailLines(wrapLength))
+	}
+	lines.AppendLines(WarningLines(warnings, wrapLength))
 
 	return lines
 }
 
-func (c *Certificate) warningLines(wrapLength int) *Lines {
+func WarningLines(warnings []Warning, wrapLength int) *Lines {
 	lines := NewLines()
 
 	lines.Print("Warnings:")
 
-	warnings := c.Warnings()
-
 	if len(warnings) <= 0 {
 		lines.Print("  - None. Yay!")
 		return lines
@@ -98,7 +126,8 @@ func (c *Certificate) warningLines(wrapLength int) *Lines {
 
 	for _, warning := range warnings {
 		subIndent := "  - "
-		for _, line := range wordWrapLines(warning.Description(), wrapLength-4) {
+		text := fmt.Sprintf("[%s] %s: %s", warning.Severity(), warning.ID(), warning.Description())
+		for _, line := range wordWrapLines(text, wrapLength-4) {
 			lines.Print("%s%s", subIndent, line)
 			subIndent = "    "
 		}
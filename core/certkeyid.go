@@ -0,0 +1,47 @@
+package core
+
+import (
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+)
+
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// SubjectKeyID returns the certificate's Subject Key Identifier or, if the
+// extension is missing, one computed from the public key as described in
+// RFC 7093, section 2, method 1.
+func (c *Certificate) SubjectKeyID() []byte {
+	if len(c.Certificate.SubjectKeyId) > 0 {
+		return c.Certificate.SubjectKeyId
+	}
+	return computedKeyID(c.Certificate.RawSubjectPublicKeyInfo)
+}
+
+func (c *Certificate) HasSubjectKeyIDExtension() bool {
+	return len(c.Certificate.SubjectKeyId) > 0
+}
+
+func (c *Certificate) IsSelfSigned() bool {
+	return c.isIssuedBy(c)
+}
+
+func computedKeyID(rawSPKI []byte) []byte {
+	spki := subjectPublicKeyInfo{}
+	if _, err := asn1.Unmarshal(rawSPKI, &spki); err != nil {
+		sum := sha256.Sum256(rawSPKI)
+		return sum[:20]
+	}
+	sum := sha256.Sum256(spki.PublicKey.Bytes)
+	return sum[:20]
+}
+
+func shortKeyID(keyID []byte) []byte {
+	if len(keyID) > 4 {
+		return keyID[:4]
+	}
+	return keyID
+}
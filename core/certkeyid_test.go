@@ -0,0 +1,52 @@
+package core
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+)
+
+// no-ski.crt has neither key identifier. self-issued.crt has the test root's
+// name as both subject and issuer, but its own key and the root's signature.
+// The computed key ID (RFC 7093, method 1) is the first 160 bits of
+//
+//	openssl x509 -in no-ski.crt -pubkey -noout | openssl pkey -pubin -outform der |
+//	    tail -c 65 | openssl dgst -sha256
+func TestSubjectKeyID(t *testing.T) {
+	tests := []struct {
+		file         string
+		want         string
+		hasExtension bool
+	}{
+		{"keyid/no-ski.crt", "c8e6d32d2bebc3a1c7262f9b28e350122f228656", false},
+		{"pkcs12/intermediate.crt", "f5f2b453f75fe86a3f68dea70fd96148fdd06120", true},
+	}
+	for _, test := range tests {
+		cert := loadCertificateFixture(t, filepath.Join("testdata", test.file))
+		if got := hex.EncodeToString(cert.SubjectKeyID()); got != test.want {
+			t.Errorf("%s: got key ID %s, want %s", test.file, got, test.want)
+		}
+		if got := cert.HasSubjectKeyIDExtension(); got != test.hasExtension {
+			t.Errorf("%s: got HasSubjectKeyIDExtension %v, want %v", test.file, got, test.hasExtension)
+		}
+	}
+}
+
+func TestIsSelfSigned(t *testing.T) {
+	tests := []struct {
+		file string
+		want bool
+	}{
+		{"pkcs12/root.crt", true},
+		{"keyid/no-ski.crt", true},
+		{"pkcs12/intermediate.crt", false},
+		{"pkcs12/leaf.crt", false},
+		{"keyid/self-issued.crt", false},
+	}
+	for _, test := range tests {
+		cert := loadCertificateFixture(t, filepath.Join("testdata", test.file))
+		if got := cert.IsSelfSigned(); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.file, got, test.want)
+		}
+	}
+}
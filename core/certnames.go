@@ -0,0 +1,94 @@
+package core
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+func (c *Certificate) SANs() []string {
+	cert := c.Certificate
+	return sanStrings(cert.DNSNames, cert.IPAddresses, cert.EmailAddresses, cert.URIs)
+}
+
+// sanStrings lists the names of a certificate or request in the order
+// they're shown: DNS names, IP addresses, email addresses and URIs.
+func sanStrings(dnsNames []string, ipAddresses []net.IP, emailAddresses []string, uris []*url.URL) []string {
+	rv := []string{}
+	rv = append(rv, dnsNames...)
+	for _, ip := range ipAddresses {
+		rv = append(rv, ip.String())
+	}
+	rv = append(rv, emailAddresses...)
+	for _, uri := range uris {
+		rv = append(rv, uri.String())
+	}
+	return rv
+}
+
+// CoversName tells if the certificate is valid for the given name, using the
+// same rules browsers use: only SANs are considered, and a wildcard matches
+// exactly one whole label at the leftmost position, so "*.example.com"
+// covers "www.example.com" but neither "example.com" nor
+// "a.b.example.com".
+func (c *Certificate) CoversName(name string) bool {
+	cert := c.Certificate
+
+	if ip := net.ParseIP(name); ip != nil {
+		for _, certIP := range cert.IPAddresses {
+			if certIP.Equal(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if strings.Contains(name, "@") {
+		for _, email := range cert.EmailAddresses {
+			if strings.EqualFold(email, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if strings.Contains(name, "://") {
+		for _, uri := range cert.URIs {
+			if uri.String() == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, pattern := range cert.DNSNames {
+		if DNSNameMatches(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func DNSNameMatches(pattern, name string) bool {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+	if pattern == name {
+		return true
+	}
+
+	if !strings.HasPrefix(pattern, "*.") {
+		return false
+	}
+	// "*.example.com" matches itself literally above, but a wildcard
+	// pattern as a name to check never matches anything else.
+	if strings.HasPrefix(name, "*.") {
+		return false
+	}
+
+	dot := strings.Index(name, ".")
+	if dot <= 0 {
+		return false
+	}
+	return name[dot+1:] == pattern[2:]
+}
@@ -4,10 +4,10 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -17,6 +17,10 @@ import (
 type Certificate struct {
 	Certificate *x509.Certificate
 	PrivateKey  crypto.PrivateKey
+
+	// Source tells where the certificate was found, such as a file path or
+	// "iam:<name>". It's empty when unknown.
+	Source string
 }
 
 func (c *Certificate) LoadCertificateFromFile(certPath string) error {
@@ -35,24 +39,78 @@ func (c *Certificate) LoadCertificateFromFile(certPath string) error {
 }
 
 func (c *Certificate) LoadCertificateFromURL(url string) error {
-	resp, err := http.Get(url)
+	x509Cert, _, err := fetchCertificate(url)
+	if err != nil {
+		return err
+	}
+
+	c.Certificate = x509Cert
+	return nil
+}
+
+// certificateFetch is what a server answered when asked for a certificate.
+// Cached is set when the certificate came from the AIA cache, in which case
+// Status is 0, or 304 if the server confirmed the cached copy.
+type certificateFetch struct {
+	Status      int
+	ContentType string
+	Cached      bool
+}
+
+// fetchCertificate downloads a certificate, going through the AIA cache.
+func fetchCertificate(url string) (*x509.Certificate, *certificateFetch, error) {
+	entry, cached := lookupAIACache(url)
+	if cached != nil && (aiaOffline || entry.fresh()) {
+		return cached, &certificateFetch{ContentType: entry.ContentType, Cached: true}, nil
+	}
+	if aiaOffline {
+		return nil, nil, fmt.Errorf("Unable to fetch certificate from %s: not cached, and running offline", url)
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return fmt.Errorf("Unable to fetch certificate from %s: %s", url, err)
+		return nil, nil, fmt.Errorf("Unable to fetch certificate from %s: %s", url, err)
+	}
+	if cached != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to fetch certificate from %s: %s", url, err)
 	}
 	defer resp.Body.Close()
 
+	fetch := &certificateFetch{
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		entry.revalidated(resp.Header)
+		fetch.ContentType = entry.ContentType
+		fetch.Cached = true
+		return cached, fetch, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fetch, fmt.Errorf("Unable to fetch certificate from %s: HTTP %s", url, resp.Status)
+	}
+
 	certData, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("Unable to fetch certificate from %s: %s", url, err)
+		return nil, fetch, fmt.Errorf("Unable to fetch certificate from %s: %s", url, err)
 	}
 
 	x509Cert, err := parseCertificate(certData)
 	if err != nil {
-		return fmt.Errorf("Unable to parse certificate from %s: %s", url, err)
+		return nil, fetch, fmt.Errorf("Unable to parse certificate from %s: %s", url, err)
 	}
-
-	c.Certificate = x509Cert
-	return nil
+	storeAIACache(url, x509Cert, resp.Header)
+	return x509Cert, fetch, nil
 }
 
 func (c *Certificate) LoadPrivateKeyFromFile(keyPath string) error {
@@ -61,7 +119,7 @@ func (c *Certificate) LoadPrivateKeyFromFile(keyPath string) error {
 		return err
 	}
 
-	privateKey, err := parsePrivateKey(keyData)
+	privateKey, err := parsePrivateKey(keyData, keyPath)
 	if err != nil {
 		return err
 	}
@@ -71,29 +129,51 @@ func (c *Certificate) LoadPrivateKeyFromFile(keyPath string) error {
 }
 
 func (c *Certificate) EnsureCertificateAndKeyMatch() error {
-	switch publicKey := c.Certificate.PublicKey.(type) {
-	case *rsa.PublicKey:
-		rsaPrivateKey, ok := c.PrivateKey.(*rsa.PrivateKey)
-		if !ok {
-			return fmt.Errorf(
-				"Public key is %T but private key is %T", publicKey, rsaPrivateKey)
-		}
-		if publicKey.N.Cmp(rsaPrivateKey.N) != 0 {
-			return fmt.Errorf("Private RSA key doesn't match public RSA key")
-		}
-	case *ecdsa.PublicKey:
-		ecdsaPrivateKey, ok := c.PrivateKey.(*ecdsa.PrivateKey)
-		if !ok {
-			return fmt.Errorf(
-				"Public key is %T but private key is %T", publicKey, ecdsaPrivateKey)
+	return ensurePrivateKeyMatches(c.Certificate.PublicKey, c.PrivateKey)
+}
+
+// MatchPrivateKeys attaches each key to the certificate holding its public
+// key, returning the keys that didn't match any certificate.
+func MatchPrivateKeys(certs []*Certificate, keys []crypto.PrivateKey) []crypto.PrivateKey {
+	unmatched := []crypto.PrivateKey{}
+	for _, key := range keys {
+		matched := false
+		for _, cert := range certs {
+			if ensurePrivateKeyMatches(cert.Certificate.PublicKey, key) == nil {
+				cert.PrivateKey = key
+				matched = true
+			}
 		}
-		if publicKey.X.Cmp(ecdsaPrivateKey.X) != 0 || publicKey.Y.Cmp(ecdsaPrivateKey.Y) != 0 {
-			return fmt.Errorf("Private ECDSA key doesn't match public ECDSA key")
+		if !matched {
+			unmatched = append(unmatched, key)
 		}
-	default:
-		return fmt.Errorf("Unknown key algorithm")
 	}
+	return unmatched
+}
+
+func (c *Certificate) MatchesPublicKey(publicKey crypto.PublicKey) bool {
+	return publicKeysEqual(c.Certificate.PublicKey, publicKey)
+}
+
+func (c *Certificate) isIssuedBy(issuer *Certificate) bool {
+	if !bytes.Equal(c.Certificate.RawIssuer, issuer.Certificate.RawSubject) {
+		return false
+	}
+	err := issuer.Certificate.CheckSignature(
+		c.Certificate.SignatureAlgorithm,
+		c.Certificate.RawTBSCertificate,
+		c.Certificate.Signature)
+	return err == nil
+}
 
+// IssuerAmong returns the candidate that issued the certificate, checking
+// signatures, or nil if none did.
+func (c *Certificate) IssuerAmong(candidates []*Certificate) *Certificate {
+	for _, candidate := range candidates {
+		if c.isIssuedBy(candidate) {
+			return candidate
+		}
+	}
 	return nil
 }
 
@@ -165,30 +245,138 @@ func parseCertificates(data []byte) ([]*x509.Certificate, error) {
 	return rv, nil
 }
 
-func parsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+type PassphraseSource func(keyDescription string) ([]byte, error)
+
+var KeyPassphraseSource PassphraseSource
+
+type keyParseAttempt struct {
+	format string
+	err    error
+}
+
+type PrivateKeyParseError struct {
+	attempts []keyParseAttempt
+}
+
+func (e PrivateKeyParseError) Error() string {
+	lines := []string{"Failed to parse private key. Formats tried:"}
+	for _, attempt := range e.attempts {
+		lines = append(lines, fmt.Sprintf("  - %s: %s", attempt.format, attempt.err))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func keyPassphrase(keyDescription string) ([]byte, error) {
+	if KeyPassphraseSource == nil {
+		return nil, fmt.Errorf(
+			"Private key %s is encrypted, but no passphrase source is available", keyDescription)
+	}
+	passphrase, err := KeyPassphraseSource(keyDescription)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read passphrase for %s: %s", keyDescription, err)
+	}
+	return passphrase, nil
+}
+
+func parsePrivateKey(data []byte, keyDescription string) (crypto.PrivateKey, error) {
 	pemBlock, _ := pem.Decode(data)
 	if pemBlock != nil {
 		if !strings.HasSuffix(pemBlock.Type, "PRIVATE KEY") {
 			return nil, fmt.Errorf("Found non-key PEM block.")
 		}
 
-		data = pemBlock.Bytes
+		if pemBlock.Type == "ENCRYPTED PRIVATE KEY" {
+			return parseEncryptedPKCS8PrivateKey(pemBlock.Bytes, keyDescription)
+		}
+
+		if x509.IsEncryptedPEMBlock(pemBlock) {
+			passphrase, err := keyPassphrase(keyDescription)
+			if err != nil {
+				return nil, err
+			}
+			data, err = x509.DecryptPEMBlock(pemBlock, passphrase)
+			if err == x509.IncorrectPasswordError {
+				return nil, fmt.Errorf(
+					"Unable to decrypt legacy encrypted PEM key (%s): %s",
+					pemBlock.Headers["DEK-Info"], errIncorrectPassphrase)
+			} else if err != nil {
+				return nil, fmt.Errorf("Unable to decrypt legacy encrypted PEM key: %s", err)
+			}
+		} else {
+			data = pemBlock.Bytes
+		}
 	}
 
+	attempts := []keyParseAttempt{}
+
 	if key, err := x509.ParsePKCS1PrivateKey(data); err == nil {
 		return key, nil
+	} else {
+		attempts = append(attempts, keyParseAttempt{"PKCS#1", err})
 	}
-	if key, err := x509.ParsePKCS8PrivateKey(data); err == nil {
-		switch key := key.(type) {
-		case *rsa.PrivateKey, *ecdsa.PrivateKey:
-			return key, nil
-		default:
-			return nil, fmt.Errorf("Found unknown private key type in PKCS#8 wrapping.")
-		}
+	if key, err := parsePKCS8PrivateKey(data); err == nil {
+		return key, nil
+	} else {
+		attempts = append(attempts, keyParseAttempt{"PKCS#8", err})
 	}
 	if key, err := x509.ParseECPrivateKey(data); err == nil {
 		return key, nil
+	} else {
+		attempts = append(attempts, keyParseAttempt{"SEC 1", err})
 	}
+	if pemBlock == nil {
+		if info, err := parseEncryptedPKCS8(data); err == nil {
+			return decryptAndParsePKCS8PrivateKey(info, keyDescription)
+		} else {
+			attempts = append(attempts, keyParseAttempt{"encrypted PKCS#8", err})
+		}
+	}
+
+	return nil, PrivateKeyParseError{attempts: attempts}
+}
 
-	return nil, errors.New("Failed to parse private key.")
+func parsePKCS8PrivateKey(data []byte) (crypto.PrivateKey, error) {
+	key, err := x509.ParsePKCS8PrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("Found unknown private key type %T in PKCS#8 wrapping", key)
+	}
+}
+
+func parseEncryptedPKCS8PrivateKey(der []byte, keyDescription string) (crypto.PrivateKey, error) {
+	info, err := parseEncryptedPKCS8(der)
+	if err != nil {
+		return nil, PrivateKeyParseError{
+			attempts: []keyParseAttempt{{"encrypted PKCS#8", err}},
+		}
+	}
+	return decryptAndParsePKCS8PrivateKey(info, keyDescription)
+}
+
+func decryptAndParsePKCS8PrivateKey(
+	info *encryptedPrivateKeyInfo,
+	keyDescription string,
+) (crypto.PrivateKey, error) {
+	passphrase, err := keyPassphrase(keyDescription)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, err := decryptPKCS8(info, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decrypt encrypted PKCS#8 key: %s", err)
+	}
+
+	key, err := parsePKCS8PrivateKey(decrypted)
+	if err != nil {
+		return nil, PrivateKeyParseError{
+			attempts: []keyParseAttempt{{"PKCS#8 (after decryption)", err}},
+		}
+	}
+	return key, nil
 }
@@ -3,6 +3,7 @@ package core
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -15,11 +16,14 @@ func (c *Certificate) PrivateKeyToPEM() ([]byte, error) {
 	var pemType string
 	switch pKey := c.PrivateKey.(type) {
 	case *ecdsa.PrivateKey:
-		pemType = "EC"
+		pemType = "EC PRIVATE KEY"
 		der, err = x509.MarshalECPrivateKey(pKey)
 	case *rsa.PrivateKey:
-		pemType = "RSA"
+		pemType = "RSA PRIVATE KEY"
 		der = x509.MarshalPKCS1PrivateKey(pKey)
+	case ed25519.PrivateKey:
+		pemType = "PRIVATE KEY"
+		der, err = x509.MarshalPKCS8PrivateKey(pKey)
 	default:
 		return nil, fmt.Errorf("Unknown private key type: %T", pKey)
 	}
@@ -28,7 +32,7 @@ func (c *Certificate) PrivateKeyToPEM() ([]byte, error) {
 	}
 
 	return pem.EncodeToMemory(&pem.Block{
-		Type:  pemType + " PRIVATE KEY",
+		Type:  pemType,
 		Bytes: der,
 	}), nil
 }
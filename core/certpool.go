@@ -1,14 +1,21 @@
 package core
 
 import (
+	"bytes"
 	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 
 	"github.com/certifi/gocertifi"
 )
 
 var certPoolCache *x509.CertPool
 
+// trustedRoots are roots trusted in addition to the built-in store, such as
+// a local development CA. They're used for verification and chain building,
+// but they're not reported as bundled in browsers.
+var trustedRoots []*x509.Certificate
+
 func CertPool() (*x509.CertPool, error) {
 	if certPoolCache == nil {
 		var err error
@@ -17,6 +24,9 @@ func CertPool() (*x509.CertPool, error) {
 			certPoolCache = nil
 			return nil, fmt.Errorf("Unable to load certificates from built-in store: %s", err)
 		}
+		for _, cert := range trustedRoots {
+			certPoolCache.AddCert(cert)
+		}
 	}
 	return certPoolCache, nil
 }
@@ -29,12 +39,46 @@ func MustCertPool() *x509.CertPool {
 	}
 }
 
+func AddTrustedRoots(certs []*Certificate) {
+	for _, cert := range certs {
+		trustedRoots = append(trustedRoots, cert.Certificate)
+	}
+	certPoolCache = nil
+}
+
+// LoadTrustStore adds every certificate in a PEM or DER file as a trusted
+// root.
+func LoadTrustStore(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read trust store %s: %s", path, err)
+	}
+	x509Certs, err := parseCertificates(data)
+	if err != nil {
+		return fmt.Errorf("Unable to parse trust store %s: %s", path, err)
+	}
+	if len(x509Certs) == 0 {
+		return fmt.Errorf("Trust store %s contains no certificates", path)
+	}
+
+	certs := []*Certificate{}
+	for _, x509Cert := range x509Certs {
+		certs = append(certs, &Certificate{Certificate: x509Cert})
+	}
+	AddTrustedRoots(certs)
+	return nil
+}
+
 var certPoolSubjectSetCache map[string]struct{}
 
 func certPoolSubjectSet() map[string]struct{} {
 	if certPoolSubjectSetCache == nil {
+		pool, err := gocertifi.CACerts()
+		if err != nil {
+			panic(err)
+		}
 		certPoolSubjectSetCache = map[string]struct{}{}
-		for _, subject := range MustCertPool().Subjects() {
+		for _, subject := range pool.Subjects() {
 			certPoolSubjectSetCache[string(subject)] = struct{}{}
 		}
 	}
@@ -45,3 +89,12 @@ func certInPool(cert *x509.Certificate) bool {
 	_, ok := certPoolSubjectSet()[string(cert.RawSubject)]
 	return ok
 }
+
+func certInTrustStore(cert *x509.Certificate) bool {
+	for _, root := range trustedRoots {
+		if bytes.Equal(root.RawSubject, cert.RawSubject) {
+			return true
+		}
+	}
+	return false
+}
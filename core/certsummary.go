@@ -0,0 +1,128 @@
+package core
+
+import (
+	"time"
+)
+
+type CertificateSummary struct {
+	ID                 string           `json:"id"`
+	SHA256Fingerprint  string           `json:"sha256_fingerprint"`
+	SHA1Fingerprint    string           `json:"sha1_fingerprint"`
+	SPKISHA256         string           `json:"spki_sha256"`
+	Subject            string           `json:"subject"`
+	Issuer             string           `json:"issuer"`
+	SerialNumber       string           `json:"serial_number"`
+	NotBefore          time.Time        `json:"not_before"`
+	NotAfter           time.Time        `json:"not_after"`
+	DNSNames           []string         `json:"dns_names"`
+	SignatureAlgorithm string           `json:"signature_algorithm"`
+	PublicKeyAlgorithm string           `json:"public_key_algorithm"`
+	KeyBitLength       string           `json:"key_bit_length"`
+	Bundled            bool             `json:"bundled"`
+	Source             string           `json:"source,omitempty"`
+	Warnings           []WarningSummary `json:"warnings"`
+}
+
+type WarningSummary struct {
+	ID          string `json:"id"`
+	Severity    string `json:"severity"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+type ChainSummary struct {
+	Leaf          *CertificateSummary   `json:"leaf"`
+	Intermediates []*CertificateSummary `json:"intermediates"`
+}
+
+type VerificationSummary struct {
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (c *Certificate) Summary() *CertificateSummary {
+	return c.summary(c.Warnings())
+}
+
+func (c *Certificate) summary(certWarnings []Warning) *CertificateSummary {
+	cert := c.Certificate
+
+	warnings := []WarningSummary{}
+	for _, warning := range certWarnings {
+		warnings = append(warnings, WarningSummary{
+			ID:          warning.ID(),
+			Severity:    warning.Severity().String(),
+			Title:       warning.Title(),
+			Description: warning.Description(),
+		})
+	}
+
+	dnsNames := cert.DNSNames
+	if dnsNames == nil {
+		dnsNames = []string{}
+	}
+
+	return &CertificateSummary{
+		ID:                 c.ID(),
+		SHA256Fingerprint:  c.SHA256Fingerprint(),
+		SHA1Fingerprint:    c.SHA1Fingerprint(),
+		SPKISHA256:         c.SPKIHash(),
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       c.ReadableSerialNumber(),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		DNSNames:           dnsNames,
+		SignatureAlgorithm: c.ReadableSignatureAlgorithm(),
+		PublicKeyAlgorithm: c.ReadablePublicKeyAlgorithm(),
+		KeyBitLength:       c.ReadableKeyBitLength(),
+		Bundled:            c.IsBundled(),
+		Source:             c.Source,
+		Warnings:           warnings,
+	}
+}
+
+func (c *CertificateChain) Summary() *ChainSummary {
+	rv := &ChainSummary{
+		Intermediates: []*CertificateSummary{},
+	}
+	if c.Leaf != nil {
+		rv.Leaf = c.Leaf.summary(c.CertificateWarnings(c.Leaf))
+	}
+	for _, cert := range c.Intermediates {
+		rv.Intermediates = append(rv.Intermediates, cert.summary(c.CertificateWarnings(cert)))
+	}
+	return rv
+}
+
+func NewVerificationSummary(err error) VerificationSummary {
+	if err != nil {
+		return VerificationSummary{Passed: false, Error: err.Error()}
+	}
+	return VerificationSummary{Passed: true}
+}
+
+type RevocationSummary struct {
+	ID        string     `json:"id"`
+	Status    string     `json:"status"`
+	Source    string     `json:"source,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	Errors    []string   `json:"errors,omitempty"`
+}
+
+func (r *RevocationResult) Summary() *RevocationSummary {
+	rv := &RevocationSummary{
+		ID:     r.Certificate.ID(),
+		Status: r.Status,
+		Source: r.Source,
+	}
+	if r.Status == RevocationRevoked {
+		rv.RevokedAt = &r.RevokedAt
+		rv.Reason = RevocationReasonName(r.Reason)
+	}
+	for _, err := range r.Errors {
+		rv.Errors = append(rv.Errors, err.Error())
+	}
+	return rv
+}
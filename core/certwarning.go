@@ -1,19 +1,67 @@
 package core
 
 import (
+	"crypto"
 	"crypto/rsa"
 	"crypto/x509"
 	"fmt"
+	"net"
 	"strings"
 )
 
+// Warning is a problem found by a lint. ID is stable across releases, so it
+// can be used to filter or suppress specific lints.
 type Warning interface {
+	ID() string
+	Severity() Severity
 	Title() string
 	Description() string
 }
 
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = map[Severity]string{
+	SeverityInfo:    "info",
+	SeverityWarning: "warning",
+	SeverityError:   "error",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// CountWarnings tells how many of the warnings have at least the given
+// severity.
+func CountWarnings(warnings []Warning, minimum Severity) int {
+	count := 0
+	for _, warning := range warnings {
+		if warning.Severity() >= minimum {
+			count++
+		}
+	}
+	return count
+}
+
 type ExpirationWarning struct {
-	c *Certificate
+	c         *Certificate
+	threshold int
+}
+
+func (w ExpirationWarning) ID() string {
+	return "expiring-soon"
+}
+
+func (w ExpirationWarning) Severity() Severity {
+	return SeverityWarning
 }
 
 func (w ExpirationWarning) Title() string {
@@ -22,15 +70,47 @@ func (w ExpirationWarning) Title() string {
 
 func (w ExpirationWarning) Description() string {
 	return formatDescription(`
-This certificate is set to expire in %.2f days, which is less than 3
-months. You should probably prepare to renew this certificate (or any
+This certificate is set to expire in %.2f days, which is less than %d
+days. You should probably prepare to renew this certificate (or any
 descendant certificate) soon.
-`, w.c.DaysToExpire())
+`, w.c.DaysToExpire(), w.threshold)
+}
+
+// ExpirationThreshold is the number of days before expiration at which
+// certificates get the expiring-soon warning.
+func ExpirationThreshold() int {
+	return warningPolicy.threshold("expiring-soon", 90)
+}
+
+type ExpiredWarning struct {
+	c *Certificate
+}
+
+func (w ExpiredWarning) ID() string {
+	return "expired"
+}
+
+func (w ExpiredWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w ExpiredWarning) Title() string {
+	return "The certificate has expired."
+}
+
+func (w ExpiredWarning) Description() string {
+	return formatDescription(`
+This certificate expired %.2f days ago, at %s. Every client will reject
+chains that go through it, so it must be replaced right away.
+`, -w.c.DaysToExpire(), w.c.Certificate.NotAfter.UTC())
 }
 
 func TryExpirationWarning(c *Certificate) Warning {
-	if c.DaysToExpire() < 90 {
-		return ExpirationWarning{c: c}
+	threshold := ExpirationThreshold()
+	if c.DaysToExpire() < 0 {
+		return ExpiredWarning{c: c}
+	} else if c.DaysToExpire() < float64(threshold) {
+		return ExpirationWarning{c: c, threshold: threshold}
 	} else {
 		return nil
 	}
@@ -40,6 +120,14 @@ type ObsoleteAlgorithmWarning struct {
 	c *Certificate
 }
 
+func (w ObsoleteAlgorithmWarning) ID() string {
+	return "obsolete-signature-algorithm"
+}
+
+func (w ObsoleteAlgorithmWarning) Severity() Severity {
+	return SeverityError
+}
+
 func (w ObsoleteAlgorithmWarning) Title() string {
 	return "Certificate signed with obsolete algorithm."
 }
@@ -52,16 +140,18 @@ this manner, and you should consider replacing this certificate.
 `, w.c.ReadableSignatureAlgorithm())
 }
 
-func TryObsoleteAlgorithmWarning(c *Certificate) Warning {
-	var isObsoleteAlgorithm bool
-	switch c.Certificate.SignatureAlgorithm {
+func isObsoleteSignatureAlgorithm(algorithm x509.SignatureAlgorithm) bool {
+	switch algorithm {
 	case x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1,
 		x509.MD2WithRSA, x509.MD5WithRSA:
-		isObsoleteAlgorithm = true
+		return true
 	default:
-		isObsoleteAlgorithm = false
+		return false
 	}
-	if !c.IsBundled() && isObsoleteAlgorithm {
+}
+
+func TryObsoleteAlgorithmWarning(c *Certificate) Warning {
+	if !c.IsBundled() && isObsoleteSignatureAlgorithm(c.Certificate.SignatureAlgorithm) {
 		return ObsoleteAlgorithmWarning{c: c}
 	} else {
 		return nil
@@ -69,7 +159,16 @@ func TryObsoleteAlgorithmWarning(c *Certificate) Warning {
 }
 
 type KeyTooShortWarning struct {
-	c *Certificate
+	bitLength int
+	minimum   int
+}
+
+func (w KeyTooShortWarning) ID() string {
+	return "key-too-short"
+}
+
+func (w KeyTooShortWarning) Severity() Severity {
+	return SeverityError
 }
 
 func (w KeyTooShortWarning) Title() string {
@@ -78,22 +177,63 @@ func (w KeyTooShortWarning) Title() string {
 
 func (w KeyTooShortWarning) Description() string {
 	return formatDescription(`
-This certificate has a key that's too short (%d bits) for today's
-standards. RSA keys should have at least 2048 bits, and ECDSA curves
-should respect the requirements established by the CA/B forum. You
-should probably replace this certificate.
-`, w.c.ReadableKeyBitLength())
+This key is too short (%d bits) for today's standards. RSA keys should
+have at least %d bits, and ECDSA curves should respect the
+requirements established by the CA/B forum. You should probably replace
+this certificate.
+`, w.bitLength, w.minimum)
 }
 
 func TryKeyTooShortWarning(c *Certificate) Warning {
-	rsaPubKey, ok := c.Certificate.PublicKey.(*rsa.PublicKey)
-	if ok && rsaPubKey.N.BitLen() < 2048 && !c.IsBundled() {
-		return KeyTooShortWarning{c: c}
+	if c.IsBundled() {
+		return nil
+	}
+	return tryKeyTooShortWarning(c.Certificate.PublicKey)
+}
+
+func tryKeyTooShortWarning(publicKey crypto.PublicKey) Warning {
+	minimum := warningPolicy.threshold("key-too-short", 2048)
+	rsaPubKey, ok := publicKey.(*rsa.PublicKey)
+	if ok && rsaPubKey.N.BitLen() < minimum {
+		return KeyTooShortWarning{bitLength: rsaPubKey.N.BitLen(), minimum: minimum}
 	} else {
 		return nil
 	}
 }
 
+type MissingSANWarning struct{}
+
+func (w MissingSANWarning) ID() string {
+	return "missing-san"
+}
+
+func (w MissingSANWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w MissingSANWarning) Title() string {
+	return "No Subject Alternative Names."
+}
+
+func (w MissingSANWarning) Description() string {
+	return formatDescription(`
+This certificate doesn't list any DNS names or IP addresses as Subject
+Alternative Names. Browsers ignore the Common Name and will reject it
+for any hostname.
+`)
+}
+
+func TryMissingSANWarning(c *Certificate) Warning {
+	return tryMissingSANWarning(c.Certificate.DNSNames, c.Certificate.IPAddresses)
+}
+
+func tryMissingSANWarning(dnsNames []string, ipAddresses []net.IP) Warning {
+	if len(dnsNames) == 0 && len(ipAddresses) == 0 {
+		return MissingSANWarning{}
+	}
+	return nil
+}
+
 func formatDescription(format string, a ...interface{}) string {
 	return strings.Replace(strings.Trim(fmt.Sprintf(format, a...), " \n"), "\n", " ", -1)
 }
@@ -102,9 +242,20 @@ var warningTriers = []func(*Certificate) Warning{
 	TryExpirationWarning,
 	TryObsoleteAlgorithmWarning,
 	TryKeyTooShortWarning,
+	TryDebianWeakKeyWarning,
+	TryROCAWarning,
+	TrySmallExponentWarning,
+	TryCompromisedKeyWarning,
+	TrySharedFactorWarning,
 }
 
+// Warnings returns the warnings that apply to any certificate, after the
+// warning policy.
 func (c *Certificate) Warnings() []Warning {
+	return warningPolicy.apply(c, c.lintWarnings())
+}
+
+func (c *Certificate) lintWarnings() []Warning {
 	rv := []Warning{}
 	for _, trier := range warningTriers {
 		w := trier(c)
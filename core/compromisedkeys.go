@@ -0,0 +1,85 @@
+package core
+
+// compromisedKeys maps the base64 SHA-256 SPKI hashes of keys whose private
+// half is public, such as keys shipped in source code, to where they come
+// from. The built-in entries are private keys from the test suites and
+// examples of widely used TLS libraries, which end up copied into real
+// deployments; the badkeys project (https://badkeys.info) tracks many more,
+// which can be added with LoadCompromisedKeys.
+var compromisedKeys = map[string]string{
+	"OJ+e3lINvDPSrrxIkkatieIh0ewV9pPDSMWLCCGTZ6o=": "Go net/http/httptest test certificate key",
+	"ImrNm1L1cxmH1GUhu+AvqIBF+esMSiYmkAItI8u17vI=": "Go crypto/tls example key",
+	"717lKClBOuqlhMbzlaUNVU9fBdxS8VxJIr65IRLXBXY=": "xmlsec example RSA key",
+
+	// Go and golang.org/x/crypto.
+	"0nh3UW2CzW9+oXJca8QOXNa7vW9peMRp9yeUOG48gYA=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"5Am6MfZgqzw+wNYRNzSF88b+hmJ/GCGvjSUxXpR188Q=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"HAKNeYZ0n9+2wlzNTrColQCpSrXViK//5DwpGHFiesM=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"HStpuiMLLWLcTJGbvsY13FlzAS8AB0b2SW1JF4ftdk8=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"Okiu2tE/3Stkq6kLnIIVJWU9UUZ89qzbGD1BS7hmXrI=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"RUGrzxlo0mXWwr8asTpl56FMDjpkc72Owgti1ZXH6Mc=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"Z86NYbWO7EHJ4qCieRws9BXt6RisQYozklSm8pOwFO8=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"a1hCtf/iX2dBToJ9HVmYvFDbNwfIk6jR7sAP25qS7Fs=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"a5F2qBUYiVYiQAImNAY6cxT5LTF/7ssVbqmPAIWDI5U=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"bg+LbRvYFhWPFrJN7rnJoNxG8KrIj8IM2sbwXHlw3OY=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"ghZDlAUu8zr5KeuCwFm7m8pz7BXgLnSMgQpf4Lobnrc=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"wCFtBW8+Qy5PhC9I9MdfL00ZdePE9NhvRdyNhjHVZT8=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"xDposPFBwiUdjkKzpfjAtNMfYdtwBtVl6edZrZxQfCY=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"yMxbLBSE1LS/CKaM0E1a8viyKhtTP5U9z7n/LqfDnS8=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"ylOw+YnnjdTXukTEuiIE3B2WVUY619QqGUWUrTYNXAo=": "Go test key (src/crypto/tls/certificates_test.go)",
+	"4Vb6W7lnPbKZice/0vrt2n3xPyId8KIDtXAYYqK4V1c=": "Go test key (src/crypto/tls/tls_test.go)",
+	"zjqjBva1eJMmZKbvgb6tJyR+/sglc3tLTPHFZ2wJno8=": "Go test key (src/crypto/tls/tls_test.go)",
+	"/HV1uZJ2GB3cLO3tbQxKQjsCWuOaCh+UNj3SVF8DMaQ=": "Go test key (src/crypto/x509/platform_root_key.pem)",
+	"UyBP4K9gYYbmJ85mJBEDXTtryq4maEYygYKCEol+R7Y=": "Go test key (src/net/smtp/smtp_test.go)",
+	"0yEsTwRvR6KxxjpY85ai0fr6DXFsko9KU0mCokmK54s=": "Go x/crypto test key (acme/jws_test.go)",
+	"2aqs3szP3xqh59rIfGfnmb9ysc5PiUC+WCJfD6NNbkA=": "Go x/crypto test key (acme/jws_test.go)",
+	"gNFt0J8zlkcUZUXQjBENEroyUtLf85X+o/yiC/8hHCY=": "Go x/crypto test key (acme/jws_test.go)",
+	"saf0wjbcHg07ouDN5/KlI4qlM6HjvstOVGHqA7SUd7M=": "Go x/crypto test key (acme/jws_test.go)",
+	"9/pqqBpzNhxwhM9nfdG5bFaRFXiiOdGYFr1mH+UD0mk=": "Go x/crypto test key (ssh/testdata/keys.go)",
+	"AgpHwyns3aNqoR5dAbTPEmToGjASE6fW+0+0cxE+xpI=": "Go x/crypto test key (ssh/testdata/keys.go)",
+	"J4Zw/L0J/3wNcaAoYTcF7UWHZqfIZwuFDupzTvjL6uw=": "Go x/crypto test key (ssh/testdata/keys.go)",
+	"LphK8syOEaBP34aD+EhoaLURPybtyswvVp5Dgly2W+E=": "Go x/crypto test key (ssh/testdata/keys.go)",
+	"LuesJQCtl+it214ZZc1Lf7arYFVc40ORMo0ue2E8yfo=": "Go x/crypto test key (ssh/testdata/keys.go)",
+	"T0/cdjFln/TXtgTz9VdbkOIdq2NwerW4FtbC+qG8q/0=": "Go x/crypto test key (ssh/testdata/keys.go)",
+	"cxKHRJCWYVBRGl8L9JMd9PVI1Xa0C65y1jTk7gNE5F8=": "Go x/crypto test key (ssh/testdata/keys.go)",
+	"pUKiAHnOsXcnEN1OHH25rig9Zwrv/qznFdPG97sptAk=": "Go x/crypto test key (ssh/testdata/keys.go)",
+
+	// CPython's Lib/test, including older releases whose keys differ.
+	"1ntjDqdNy/AfpZDVfERpz/fIFw5yvNzjUQ0z74ndUiQ=": "CPython test key (Lib/test/allsans.pem)",
+	"m508PvLoNV2RDmbEcs7mzO3raiT/l+sKgNVe8322ko8=": "CPython test key (Lib/test/certdata/allsans.pem)",
+	"nkRWydxwmNxDMMyPD4Tyf3dxVDdYposD6TsmFaQS608=": "CPython test key (Lib/test/certdata/badcert.pem)",
+	"/i/5thHdnSFniQG44ZEjWrSDJ8SDqRoFBJK8wxutOGA=": "CPython test key (Lib/test/certdata/idnsans.pem)",
+	"Uywc9WPlfp08/2XPTjE/Tw7pd1Xhc8DpRshQw6ttdtw=": "CPython test key (Lib/test/certdata/keycert2.pem)",
+	"4qe3/VAu01RU8N2V+ui+vobPVYrIbEnHjLiCNwPNgfM=": "CPython test key (Lib/test/certdata/keycert3.pem)",
+	"Rfmg77ou9/bZGLuhq7uW1JemnYdVG4JY+POkS4hjSy4=": "CPython test key (Lib/test/certdata/keycert4.pem)",
+	"UViUqIcZqNeccs6RUMXm59YYx1IDJW/I9RHbm50goMg=": "CPython test key (Lib/test/certdata/keycertecc.pem)",
+	"LpadXnFJG2zxaR7VsWuYuaa2D4ZHibHBL/r+PpKCCG4=": "CPython test key (Lib/test/certdata/leaf-missing-aki.keycert.pem)",
+	"EQNXJd4JWgY3D8XfRvKer2k6ppHKxrJg3m1Wmjf1z4k=": "CPython test key (Lib/test/certdata/nosan.pem)",
+	"7f4tJmqONKrhDn/fXbqvStN9D6ttPXOORj9ZwGazsMU=": "CPython test key (Lib/test/certdata/pycakey.pem)",
+	"HSBUuRpVkWrQ3/fxOYivRKtgl7MQ+cdYfyY3NnvXwVA=": "CPython test key (Lib/test/certdata/ssl_key.pem)",
+	"63jl8RO/XcPk21zP9418KJ+Sbl2nGXFsoiSDhGV+AI8=": "CPython test key (Lib/test/idnsans.pem)",
+	"MWVBw1NPKbfL73bmMP0FzyLHc3/F7Bbekw1aZaEOFHg=": "CPython test key (Lib/test/keycert2.pem)",
+	"BSQJ0jkQ7wwhR7KvPZ+DSNk2XTZ/MS6xCbo9qu++VdQ=": "CPython test key (Lib/test/keycert3.pem)",
+	"rmzz+JqXQ4d+8FhdXJ8Wts5DpqbcM4Pcx2Z0LV3F57A=": "CPython test key (Lib/test/keycert4.pem)",
+	"ihLM9xKepJNUhD0F7/JgNiToCDDQUGFwogW6I6/yr+Q=": "CPython test key (Lib/test/keycertecc.pem)",
+	"eKnX70CAWhimi3NCFoYUU35hsXM8iHxPhs9NgC9tuyY=": "CPython test key (Lib/test/pycakey.pem)",
+	"vkyJroQnil78EL1r+mFeP4S5qREQsXu1K6ajUeqhTU0=": "CPython test key (Lib/test/ssl_key.pem)",
+
+	// Rust crates.
+	"y2kWPYTz4AkzyXO+YxUclSrKJdclFFKnEJi5mT9zdMo=": "Rust hyper-rustls crate test key (examples/sample.rsa)",
+	"PpjKl/35UXeFgqrmlSFx2k107/uDNIq4Ewal2q3V9sU=": "Rust openssl crate test key (test/intermediate-ca.key)",
+	"VHQAbNl67nmkZJNESeTKvTxb5bQmd1maWnMKG/tjcAY=": "Rust openssl crate test key (test/key.pem)",
+	"c+RpeTEpgvF4MC8TY/kWCpL0GBc4l6K4YpYb8ReQvZo=": "Rust openssl crate test key (test/root-ca.key)",
+	"b9E8JDWjYefFiM0X9V9a098Bd6ZsFyemogCEX016uIw=": "Rust openssl crate test key (test/rsa.pem)",
+	"Vd1MdLDkhTTi9OFzzs61DfjyenrCqomRzHrpFOAwvO0=": "Rust pkcs8 crate test key (tests/examples/ed25519-priv-pkcs8v1.pem)",
+	"oekVYFTgT6yJmunydRMs3Ael28TqLCrTof/G4NJTaB8=": "Rust pkcs8 crate test key (tests/examples/ed25519-priv-pkcs8v2.pem)",
+	"uZaNVu2NaqP7Q7FfoB41XXo6AgOxQIs/0nM2N8TRZCw=": "Rust pkcs8 crate test key (tests/examples/p256-priv.pem)",
+	"7+2pv+rZ/QWU9qXPb99sFjEWo7H61tc86gUpW2j9F5Q=": "Rust pkcs8 crate test key (tests/examples/rsa2048-priv.pem)",
+	"Qa1FhptBkrpllcXOVwYJy7v/12JzeUaJakI4YjVS5dY=": "Rust rustls-pki-types crate test key (tests/data/rsa-key-no-trailing-newline.pem)",
+	"Hg4KimjHczh6jc5IOszLIk7fY0X4DGRepo//E5dl36g=": "Rust rustls-pki-types crate test key (tests/data/rsa1024.pkcs8.pem)",
+	"XGVqsxCzBtal4YwsfvlXonH8Da46erbKXpvg/w6oJfA=": "Rust rustls-pki-types crate test key (tests/data/zen.pem)",
+	"nzGgOYXjvSyL60wATbBhAwcd05OxWaa4Q7lb7h3EhPw=": "Rust tokio-rustls crate test key (tests/certs/end.key)",
+
+	// Tornado.
+	"4tBhF9G21Fi9P8o4v+GB511Bimp3fs7F2u1WD2F0E5A=": "Tornado test key (tornado/test/test.key)",
+}
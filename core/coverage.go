@@ -0,0 +1,116 @@
+package core
+
+import (
+	"strings"
+	"time"
+)
+
+// NameCoverage lists the certificates covering a required name. Expired
+// certificates don't count as covering, but are kept apart since they
+// usually point to a missed renewal.
+type NameCoverage struct {
+	Name      string
+	CoveredBy []*Certificate
+	ExpiredBy []*Certificate
+}
+
+type CoverageReport struct {
+	Names        []*NameCoverage
+	Certificates []*Certificate
+}
+
+// AnalyzeCoverage checks which certificates cover each name. Certificates
+// found in several sources are only counted once, and names are compared
+// case-insensitively and without a trailing dot.
+func AnalyzeCoverage(names []string, certs []*Certificate) *CoverageReport {
+	certs = uniqueCertificates(certs)
+	report := &CoverageReport{
+		Certificates: certs,
+	}
+
+	now := time.Now()
+	for _, name := range normalizeHostnames(names) {
+		coverage := &NameCoverage{Name: name}
+		for _, cert := range certs {
+			if !cert.CoversName(name) {
+				continue
+			}
+			if now.After(cert.Certificate.NotAfter) {
+				coverage.ExpiredBy = append(coverage.ExpiredBy, cert)
+			} else {
+				coverage.CoveredBy = append(coverage.CoveredBy, cert)
+			}
+		}
+		report.Names = append(report.Names, coverage)
+	}
+
+	return report
+}
+
+func (r *CoverageReport) Uncovered() []*NameCoverage {
+	rv := []*NameCoverage{}
+	for _, coverage := range r.Names {
+		if len(coverage.CoveredBy) == 0 {
+			rv = append(rv, coverage)
+		}
+	}
+	return rv
+}
+
+func (r *CoverageReport) CoveredSeveralTimes() []*NameCoverage {
+	rv := []*NameCoverage{}
+	for _, coverage := range r.Names {
+		if len(coverage.CoveredBy) > 1 {
+			rv = append(rv, coverage)
+		}
+	}
+	return rv
+}
+
+// UnneededSANs lists the certificate's SANs that don't cover any required
+// name. When all of them are listed, the certificate isn't needed at all.
+func (r *CoverageReport) UnneededSANs(cert *Certificate) []string {
+	rv := []string{}
+	for _, san := range cert.SANs() {
+		needed := false
+		for _, coverage := range r.Names {
+			if DNSNameMatches(san, coverage.Name) {
+				needed = true
+				break
+			}
+		}
+		if !needed {
+			rv = append(rv, san)
+		}
+	}
+	return rv
+}
+
+// uniqueCertificates drops certificates already seen with the same
+// fingerprint, keeping the first one.
+func uniqueCertificates(certs []*Certificate) []*Certificate {
+	seen := map[string]bool{}
+	rv := []*Certificate{}
+	for _, cert := range certs {
+		if !seen[cert.ID()] {
+			seen[cert.ID()] = true
+			rv = append(rv, cert)
+		}
+	}
+	return rv
+}
+
+// normalizeHostnames lowercases names, trims whitespace and trailing dots,
+// and drops empty names and duplicates.
+func normalizeHostnames(names []string) []string {
+	seen := map[string]bool{}
+	rv := []string{}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			rv = append(rv, name)
+		}
+	}
+	return rv
+}
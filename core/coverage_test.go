@@ -0,0 +1,64 @@
+package core
+
+import (
+	"crypto/x509/pkix"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeCoverage(t *testing.T) {
+	key := loadKeyFixture(t, "ec.pem")
+	wildcard := issueTestCertificate(t, key, pkix.Name{CommonName: "*.example.test"},
+		[]string{"*.example.test"})
+	// The same certificate, found again in another source.
+	wildcardCopy := &Certificate{Certificate: wildcard.Certificate, Source: "other.pem"}
+	apex := issueTestCertificate(t, key, pkix.Name{CommonName: "example.test"},
+		[]string{"example.test", "www.example.test", "old.example.org"})
+
+	report := AnalyzeCoverage(
+		[]string{"WWW.Example.test.", "www.example.test", " example.test ", "", "a.b.example.test"},
+		[]*Certificate{wildcard, apex, wildcardCopy},
+	)
+
+	if len(report.Certificates) != 2 {
+		t.Errorf("got %d certificates, want 2", len(report.Certificates))
+	}
+
+	covered := map[string]int{}
+	names := []string{}
+	for _, coverage := range report.Names {
+		names = append(names, coverage.Name)
+		covered[coverage.Name] = len(coverage.CoveredBy)
+	}
+	if want := []string{"www.example.test", "example.test", "a.b.example.test"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got names %v, want %v", names, want)
+	}
+	if want := map[string]int{"www.example.test": 2, "example.test": 1, "a.b.example.test": 0}; !reflect.DeepEqual(covered, want) {
+		t.Errorf("got coverage %v, want %v", covered, want)
+	}
+
+	tests := []struct {
+		what string
+		got  []*NameCoverage
+		want []string
+	}{
+		{"uncovered", report.Uncovered(), []string{"a.b.example.test"}},
+		{"covered several times", report.CoveredSeveralTimes(), []string{"www.example.test"}},
+	}
+	for _, test := range tests {
+		got := []string{}
+		for _, coverage := range test.got {
+			got = append(got, coverage.Name)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.what, got, test.want)
+		}
+	}
+
+	if got, want := report.UnneededSANs(apex), []string{"old.example.org"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unneeded SANs: got %v, want %v", got, want)
+	}
+	if got := report.UnneededSANs(wildcard); len(got) != 0 {
+		t.Errorf("unneeded wildcard SANs: got %v, want none", got)
+	}
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type CertificateRequestTemplate struct {
+	CommonName         string   `yaml:"common_name"`
+	Organization       []string `yaml:"organization"`
+	OrganizationalUnit []string `yaml:"organizational_unit"`
+	Country            []string `yaml:"country"`
+	Province           []string `yaml:"province"`
+	Locality           []string `yaml:"locality"`
+	SANs               []string `yaml:"sans"`
+	Key                KeySpec  `yaml:"key"`
+}
+
+func LoadCertificateRequestTemplate(path string) (*CertificateRequestTemplate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := &CertificateRequestTemplate{}
+	if err := yaml.UnmarshalStrict(data, rv); err != nil {
+		return nil, fmt.Errorf("Unable to parse template %s: %s", path, err)
+	}
+	return rv, nil
+}
+
+func (t *CertificateRequestTemplate) Subject() pkix.Name {
+	return pkix.Name{
+		CommonName:         t.CommonName,
+		Organization:       t.Organization,
+		OrganizationalUnit: t.OrganizationalUnit,
+		Country:            t.Country,
+		Province:           t.Province,
+		Locality:           t.Locality,
+	}
+}
+
+// SplitSANs sorts free-form names into DNS names, IP addresses, email
+// addresses and URIs. The common name is added as a DNS name when it's not
+// already listed, since browsers only look at the SANs.
+func (t *CertificateRequestTemplate) SplitSANs() (
+	dnsNames []string,
+	ipAddresses []net.IP,
+	emailAddresses []string,
+	uris []*url.URL,
+	err error,
+) {
+	names := t.SANs
+	if t.CommonName != "" && net.ParseIP(t.CommonName) == nil && !strings.Contains(t.CommonName, " ") {
+		found := false
+		for _, name := range names {
+			if strings.EqualFold(name, t.CommonName) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			names = append([]string{t.CommonName}, names...)
+		}
+	}
+
+	for _, name := range names {
+		switch {
+		case net.ParseIP(name) != nil:
+			ipAddresses = append(ipAddresses, net.ParseIP(name))
+		case strings.Contains(name, "://"):
+			uri, parseErr := url.Parse(name)
+			if parseErr != nil {
+				return nil, nil, nil, nil, fmt.Errorf("Invalid URI SAN '%s': %s", name, parseErr)
+			}
+			uris = append(uris, uri)
+		case strings.Contains(name, "@"):
+			emailAddresses = append(emailAddresses, name)
+		default:
+			dnsNames = append(dnsNames, strings.ToLower(name))
+		}
+	}
+	return
+}
+
+func CreateCertificateRequest(
+	template *CertificateRequestTemplate,
+	privateKey crypto.PrivateKey,
+) (*CertificateRequest, error) {
+	dnsNames, ipAddresses, emailAddresses, uris, err := template.SplitSANs()
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:        template.Subject(),
+		DNSNames:       dnsNames,
+		IPAddresses:    ipAddresses,
+		EmailAddresses: emailAddresses,
+		URIs:           uris,
+	}, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create certificate request: %s", err)
+	}
+
+	request, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CertificateRequest{Request: request}, nil
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGeneratePrivateKey(t *testing.T) {
+	tests := []struct {
+		spec        KeySpec
+		description string
+		err         string
+	}{
+		{spec: KeySpec{Type: KeyTypeECDSA}, description: "ECDSA P-256"},
+		{spec: KeySpec{Type: KeyTypeECDSA, Curve: "secp384r1"}, description: "ECDSA P-384"},
+		{spec: KeySpec{Type: KeyTypeEd25519}, description: "Ed25519"},
+		{spec: KeySpec{Type: KeyTypeECDSA, Curve: "P-192"}, err: "Unknown curve 'P-192'"},
+		{spec: KeySpec{Type: "dsa"}, err: "Unknown key type 'dsa'"},
+	}
+	for _, test := range tests {
+		key, err := GeneratePrivateKey(test.spec)
+		switch {
+		case test.err != "":
+			if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+				t.Errorf("%+v: got error %v, want %q", test.spec, err, test.err)
+			}
+		case err != nil:
+			t.Errorf("%+v: %s", test.spec, err)
+		default:
+			if got := DescribePrivateKey(key); got != test.description {
+				t.Errorf("%+v: got %s, want %s", test.spec, got, test.description)
+			}
+		}
+	}
+}
+
+func TestSplitSANs(t *testing.T) {
+	tests := []struct {
+		commonName string
+		sans       []string
+		dnsNames   []string
+		ips        []string
+		emails     []string
+		uris       []string
+	}{
+		{
+			commonName: "www.example.test",
+			sans:       []string{"example.test"},
+			dnsNames:   []string{"www.example.test", "example.test"},
+		},
+		{
+			commonName: "WWW.Example.test",
+			sans:       []string{"www.example.test", "Mixed.Example.test"},
+			dnsNames:   []string{"www.example.test", "mixed.example.test"},
+		},
+		{
+			commonName: "Example Corp",
+			sans:       []string{"192.0.2.1", "2001:db8::1", "admin@example.test", "spiffe://example.test/web"},
+			ips:        []string{"192.0.2.1", "2001:db8::1"},
+			emails:     []string{"admin@example.test"},
+			uris:       []string{"spiffe://example.test/web"},
+		},
+		{
+			commonName: "192.0.2.1",
+		},
+	}
+	for _, test := range tests {
+		template := &CertificateRequestTemplate{CommonName: test.commonName, SANs: test.sans}
+		dnsNames, ips, emails, uris, err := template.SplitSANs()
+		if err != nil {
+			t.Errorf("%s: %s", test.commonName, err)
+			continue
+		}
+		gotIPs := []string{}
+		for _, ip := range ips {
+			gotIPs = append(gotIPs, ip.String())
+		}
+		gotURIs := []string{}
+		for _, uri := range uris {
+			gotURIs = append(gotURIs, uri.String())
+		}
+		for _, check := range []struct {
+			what      string
+			got, want []string
+		}{
+			{"DNS names", dnsNames, test.dnsNames},
+			{"IP addresses", gotIPs, test.ips},
+			{"email addresses", emails, test.emails},
+			{"URIs", gotURIs, test.uris},
+		} {
+			if len(check.got) == 0 && len(check.want) == 0 {
+				continue
+			}
+			if !reflect.DeepEqual(check.got, check.want) {
+				t.Errorf("%s: got %s %v, want %v", test.commonName, check.what, check.got, check.want)
+			}
+		}
+	}
+}
+
+func TestCertificateRequestWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		template CertificateRequestTemplate
+		want     []string
+	}{
+		{
+			name:     "clean request",
+			key:      "ec.pem",
+			template: CertificateRequestTemplate{CommonName: "www.example.test"},
+			want:     []string{},
+		},
+		{
+			name:     "short key",
+			key:      "rsa.pem",
+			template: CertificateRequestTemplate{SANs: []string{"www.example.test"}},
+			want:     []string{"key-too-short"},
+		},
+		{
+			name:     "no names",
+			key:      "ed25519.pem",
+			template: CertificateRequestTemplate{CommonName: "Example Corp"},
+			want:     []string{"missing-san"},
+		},
+	}
+	for _, test := range tests {
+		request, err := CreateCertificateRequest(&test.template, loadKeyFixture(t, test.key))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		got := []string{}
+		for _, w := range request.Warnings() {
+			got = append(got, w.ID())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got warnings %v, want %v", test.name, got, test.want)
+		}
+	}
+}
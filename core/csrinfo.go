@@ -0,0 +1,39 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+func (r *CertificateRequest) ReadableSignature() string {
+	if err := r.Request.CheckSignature(); err != nil {
+		return "INVALID (" + err.Error() + ")"
+	}
+	return "valid"
+}
+
+func (r *CertificateRequest) SPKIHash() string {
+	sum := sha256.Sum256(r.Request.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (r *CertificateRequest) SANs() []string {
+	request := r.Request
+	return sanStrings(request.DNSNames, request.IPAddresses, request.EmailAddresses, request.URIs)
+}
+
+func (r *CertificateRequest) InfoLines(wrapLength int) *Lines {
+	request := r.Request
+	lines := NewLines()
+
+	lines.Print("Subject:     %s", request.Subject.CommonName)
+	lines.Print("Subject DN:  %s", request.Subject.String())
+	lines.Print("Signature:   %s", r.ReadableSignature())
+	lines.Print("Sig. Algo.:  %s", request.SignatureAlgorithm)
+	lines.Print("Key:         %s", publicKeyDescription(request.PublicKey))
+	lines.Print("SPKI Pin:    %s", SPKIPinFromHash(r.SPKIHash()))
+	lines.AppendLines(labeledLines("Names:", r.SANs()))
+	lines.AppendLines(WarningLines(r.Warnings(), wrapLength))
+
+	return lines
+}
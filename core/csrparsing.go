@@ -0,0 +1,73 @@
+package core
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+)
+
+type CertificateRequest struct {
+	Request *x509.CertificateRequest
+}
+
+func (r *CertificateRequest) LoadCertificateRequestFromFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	request, err := parseCertificateRequest(data)
+	if err != nil {
+		return err
+	}
+
+	r.Request = request
+	return nil
+}
+
+func CertificateRequestFromFile(path string) (*CertificateRequest, error) {
+	rv := &CertificateRequest{}
+	if err := rv.LoadCertificateRequestFromFile(path); err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+func (r *CertificateRequest) CheckSignature() error {
+	if err := r.Request.CheckSignature(); err != nil {
+		return fmt.Errorf("Certificate request signature is invalid: %s", err)
+	}
+	return nil
+}
+
+func (r *CertificateRequest) EnsureKeyMatches(privateKey crypto.PrivateKey) error {
+	return ensurePrivateKeyMatches(r.Request.PublicKey, privateKey)
+}
+
+func (r *CertificateRequest) ToPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE REQUEST",
+		Bytes: r.Request.Raw,
+	})
+}
+
+func parseCertificateRequest(data []byte) (*x509.CertificateRequest, error) {
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE REQUEST" || block.Type == "NEW CERTIFICATE REQUEST" {
+			return x509.ParseCertificateRequest(block.Bytes)
+		}
+	}
+
+	request, err := x509.ParseCertificateRequest(data)
+	if err != nil {
+		return nil, fmt.Errorf("No certificate request was found: %s", err)
+	}
+	return request, nil
+}
@@ -0,0 +1,222 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+var certificateRequestWarningTriers = []func(*CertificateRequest) Warning{
+	func(r *CertificateRequest) Warning {
+		return tryKeyTooShortWarning(r.Request.PublicKey)
+	},
+	func(r *CertificateRequest) Warning {
+		return tryMissingSANWarning(r.Request.DNSNames, r.Request.IPAddresses)
+	},
+	func(r *CertificateRequest) Warning {
+		return tryWeakCurveWarning(r.Request.PublicKey)
+	},
+	func(r *CertificateRequest) Warning {
+		return tryDebianWeakKeyWarning(r.Request.PublicKey)
+	},
+	func(r *CertificateRequest) Warning {
+		return tryROCAWarning(r.Request.PublicKey)
+	},
+	func(r *CertificateRequest) Warning {
+		return trySmallExponentWarning(r.Request.PublicKey)
+	},
+	func(r *CertificateRequest) Warning {
+		return tryCompromisedKeyWarning(r.Request.RawSubjectPublicKeyInfo)
+	},
+	TryInvalidRequestSignatureWarning,
+}
+
+func (r *CertificateRequest) Warnings() []Warning {
+	rv := []Warning{}
+	for _, trier := range certificateRequestWarningTriers {
+		w := trier(r)
+		if w != nil {
+			rv = append(rv, w)
+		}
+	}
+	return warningPolicy.apply(nil, rv)
+}
+
+type InvalidRequestSignatureWarning struct {
+	err error
+}
+
+func (w InvalidRequestSignatureWarning) ID() string {
+	return "csr-invalid-signature"
+}
+
+func (w InvalidRequestSignatureWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w InvalidRequestSignatureWarning) Title() string {
+	return "Certificate request signature is invalid."
+}
+
+func (w InvalidRequestSignatureWarning) Description() string {
+	return formatDescription(`
+The signature on this certificate request doesn't verify (%s). The
+request was probably corrupted or tampered with, and CAs will reject it.
+`, w.err)
+}
+
+func TryInvalidRequestSignatureWarning(r *CertificateRequest) Warning {
+	if err := r.Request.CheckSignature(); err != nil {
+		return InvalidRequestSignatureWarning{err: err}
+	}
+	return nil
+}
+
+type RequestKeyMismatchWarning struct{}
+
+func (w RequestKeyMismatchWarning) ID() string {
+	return "csr-key-mismatch"
+}
+
+func (w RequestKeyMismatchWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w RequestKeyMismatchWarning) Title() string {
+	return "Certificate key doesn't match the request."
+}
+
+func (w RequestKeyMismatchWarning) Description() string {
+	return formatDescription(`
+The issued certificate has a different public key than the certificate
+request. It can't be used with the private key the request was made
+with.
+`)
+}
+
+type RequestNamesDroppedWarning struct {
+	names []string
+}
+
+func (w RequestNamesDroppedWarning) ID() string {
+	return "csr-names-dropped"
+}
+
+func (w RequestNamesDroppedWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w RequestNamesDroppedWarning) Title() string {
+	return "Certificate drops requested names."
+}
+
+func (w RequestNamesDroppedWarning) Description() string {
+	return formatDescription(`
+The issued certificate doesn't cover these names from the request: %s.
+Clients connecting to them will get hostname mismatch errors.
+`, strings.Join(w.names, ", "))
+}
+
+type RequestNamesAddedWarning struct {
+	names []string
+}
+
+func (w RequestNamesAddedWarning) ID() string {
+	return "csr-names-added"
+}
+
+func (w RequestNamesAddedWarning) Severity() Severity {
+	return SeverityInfo
+}
+
+func (w RequestNamesAddedWarning) Title() string {
+	return "Certificate adds names that weren't requested."
+}
+
+func (w RequestNamesAddedWarning) Description() string {
+	return formatDescription(`
+The issued certificate covers these names that weren't in the request:
+%s. This is usually harmless (many CAs add the bare domain to "www"
+requests), but check that it's expected.
+`, strings.Join(w.names, ", "))
+}
+
+type RequestSubjectChangedWarning struct {
+	changes []string
+}
+
+func (w RequestSubjectChangedWarning) ID() string {
+	return "csr-subject-changed"
+}
+
+func (w RequestSubjectChangedWarning) Severity() Severity {
+	return SeverityWarning
+}
+
+func (w RequestSubjectChangedWarning) Title() string {
+	return "Certificate subject differs from the request."
+}
+
+func (w RequestSubjectChangedWarning) Description() string {
+	return formatDescription(`
+The CA changed the following subject fields: %s.
+`, strings.Join(w.changes, "; "))
+}
+
+// CompareWithCertificate checks that an issued certificate corresponds to
+// this request, returning a warning for every difference found.
+func (r *CertificateRequest) CompareWithCertificate(cert *Certificate) []Warning {
+	rv := []Warning{}
+
+	if !cert.MatchesPublicKey(r.Request.PublicKey) {
+		rv = append(rv, RequestKeyMismatchWarning{})
+	}
+
+	requestNames := r.SANs()
+
+	dropped := []string{}
+	for _, name := range requestNames {
+		if !cert.CoversName(name) {
+			dropped = append(dropped, name)
+		}
+	}
+	if len(dropped) > 0 {
+		rv = append(rv, RequestNamesDroppedWarning{names: dropped})
+	}
+
+	requested := map[string]bool{}
+	for _, name := range requestNames {
+		requested[strings.ToLower(name)] = true
+	}
+	added := []string{}
+	for _, name := range cert.SANs() {
+		if !requested[strings.ToLower(name)] {
+			added = append(added, name)
+		}
+	}
+	if len(added) > 0 {
+		rv = append(rv, RequestNamesAddedWarning{names: added})
+	}
+
+	requestSubject := r.Request.Subject
+	certSubject := cert.Certificate.Subject
+	changes := []string{}
+	compare := func(field string, requested, issued []string) {
+		requestedValue := strings.Join(requested, ", ")
+		issuedValue := strings.Join(issued, ", ")
+		if requestedValue != issuedValue {
+			changes = append(changes, fmt.Sprintf(
+				"%s requested as '%s' but issued as '%s'", field, requestedValue, issuedValue))
+		}
+	}
+	compare("CN", []string{requestSubject.CommonName}, []string{certSubject.CommonName})
+	compare("O", requestSubject.Organization, certSubject.Organization)
+	compare("OU", requestSubject.OrganizationalUnit, certSubject.OrganizationalUnit)
+	compare("C", requestSubject.Country, certSubject.Country)
+	compare("ST", requestSubject.Province, certSubject.Province)
+	compare("L", requestSubject.Locality, certSubject.Locality)
+	if len(changes) > 0 {
+		rv = append(rv, RequestSubjectChangedWarning{changes: changes})
+	}
+
+	return warningPolicy.apply(cert, rv)
+}
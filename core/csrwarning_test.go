@@ -0,0 +1,128 @@
+package core
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompareWithCertificate(t *testing.T) {
+	key := loadKeyFixture(t, "ec.pem")
+	request, err := CreateCertificateRequest(&CertificateRequestTemplate{
+		CommonName:   "www.example.test",
+		Organization: []string{"Example"},
+		SANs:         []string{"example.test"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		key          crypto.PrivateKey
+		subject      pkix.Name
+		dnsNames     []string
+		want         []string
+		wantSeverity Severity
+	}{
+		{
+			name:     "matching",
+			key:      key,
+			subject:  pkix.Name{CommonName: "www.example.test", Organization: []string{"Example"}},
+			dnsNames: []string{"www.example.test", "example.test"},
+			want:     []string{},
+		},
+		{
+			name:     "wildcard covers requested names",
+			key:      key,
+			subject:  pkix.Name{CommonName: "www.example.test", Organization: []string{"Example"}},
+			dnsNames: []string{"*.example.test", "example.test"},
+			want:     []string{"csr-names-added"},
+		},
+		{
+			name:     "names added",
+			key:      key,
+			subject:  pkix.Name{CommonName: "www.example.test", Organization: []string{"Example"}},
+			dnsNames: []string{"www.example.test", "example.test", "mail.example.test"},
+			want:     []string{"csr-names-added"},
+		},
+		{
+			name:     "name dropped and subject changed",
+			key:      key,
+			subject:  pkix.Name{CommonName: "www.example.test"},
+			dnsNames: []string{"www.example.test"},
+			want:     []string{"csr-names-dropped", "csr-subject-changed"},
+		},
+		{
+			name:     "different key",
+			key:      loadKeyFixture(t, "ed25519.pem"),
+			subject:  pkix.Name{CommonName: "www.example.test", Organization: []string{"Example"}},
+			dnsNames: []string{"www.example.test", "example.test"},
+			want:     []string{"csr-key-mismatch"},
+		},
+	}
+	for _, test := range tests {
+		cert := issueTestCertificate(t, test.key, test.subject, test.dnsNames)
+		got := []string{}
+		for _, w := range request.CompareWithCertificate(cert) {
+			got = append(got, w.ID())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCountWarnings(t *testing.T) {
+	warnings := []Warning{
+		RequestNamesAddedWarning{},
+		RequestSubjectChangedWarning{},
+		RequestKeyMismatchWarning{},
+	}
+	tests := []struct {
+		minimum Severity
+		want    int
+	}{
+		{SeverityInfo, 3},
+		{SeverityWarning, 2},
+		{SeverityError, 1},
+	}
+	for _, test := range tests {
+		if got := CountWarnings(warnings, test.minimum); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.minimum, got, test.want)
+		}
+	}
+	if got := CountWarnings(warnings[:1], SeverityWarning); got != 0 {
+		t.Errorf("added names alone: got %d, want 0", got)
+	}
+}
+
+// issueTestCertificate creates a self-signed certificate for the key, which is
+// all CompareWithCertificate looks at.
+func issueTestCertificate(t *testing.T, key crypto.PrivateKey, subject pkix.Name, dnsNames []string) *Certificate {
+	t.Helper()
+	publicKey, err := publicKeyFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      subject,
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}, &x509.Certificate{Subject: subject}, publicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Certificate{Certificate: cert}
+}
@@ -0,0 +1,345 @@
+package core
+
+import (
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DistrustedCA is a CA family browsers stopped trusting, identified by the
+// base64 SHA-256 SPKI hashes of its roots and intermediates. Roots are rarely
+// served and may be missing from the trust store, so they're also listed by
+// hex subject key ID and by subject, which the certificates they issued name
+// in their AKI and issuer. Organizations catches the rest of the family, for
+// families that no longer issue under those names.
+type DistrustedCA struct {
+	Name          string     `yaml:"name"`
+	Reason        string     `yaml:"reason"`
+	SPKIHashes    []string   `yaml:"spki_sha256"`
+	KeyIDs        []string   `yaml:"key_ids"`
+	Subjects      []string   `yaml:"subjects"`
+	Organizations []string   `yaml:"organizations"`
+	Distrusts     []Distrust `yaml:"distrust"`
+}
+
+// Distrust is how one client treats a distrusted CA: starting at Since, it
+// rejects certificates issued after IssuedAfter, or all of them when
+// IssuedAfter is empty. Dates are YYYY-MM-DD.
+type Distrust struct {
+	Client      string `yaml:"client"`
+	Since       string `yaml:"since"`
+	IssuedAfter string `yaml:"issued_after,omitempty"`
+}
+
+const distrustDateLayout = "2006-01-02"
+
+// distrustDatabase is built in, and extended with LoadDistrustDatabase.
+// Entries loaded later take precedence.
+var distrustDatabase = []*DistrustedCA{
+	{
+		Name:   "Symantec (GeoTrust, thawte, VeriSign)",
+		Reason: "repeated misissuance",
+		SPKIHashes: []string{
+			"h6801m+z8v3zbgkRHpq6L29Esgfzhj89C1SyUCOQmqU=", // GeoTrust Global CA
+			"F3VaXClfPS1y5vAxofB/QAxYi55YKyLxfq4xoVkNEYU=", // GeoTrust Global CA 2
+			"SQVGZiOrQXi+kqxcvWWE96HhfydlLVqFr4lQTqI5qqo=", // GeoTrust Primary Certification Authority
+			"vPtEqrmtAhAVcGtBIep2HIHJ6IlnWQ9vlK50TciLePs=", // GeoTrust Primary Certification Authority - G2
+			"q5hJUnat8eyv8o81xTBIeB5cFxjaucjmelBPT2pRMo8=", // GeoTrust Primary Certification Authority - G3
+			"lpkiXF3lLlbN0y3y6W0c/qWqPKC7Us2JM8I7XCdEOCA=", // GeoTrust Universal CA
+			"fKoDRlEkWQxgHlZ+UhSOlSwM/+iQAFMP4NlbbVDqrkE=", // GeoTrust Universal CA 2
+			"HXXQgxueCIU5TTLHob/bPbwcKOKw6DkfsTWYHbxbqTY=", // thawte Primary Root CA
+			"Z9xPMvoQ59AaeaBzqgyeAhLsL/w9d54Kp/nA8OHCyJM=", // thawte Primary Root CA - G2
+			"GQbGEk27Q4V40A4GbVBUxsN/D6YCjAVUXgmU7drshik=", // thawte Primary Root CA - G3
+			"9TwiBZgX3Zb0AGUWOdL4V+IQcKWavtkHlADZ9pVQaQA=", // Thawte Premium Server CA
+			"nG9qEjy6pO402+zu4kyX1ziHjLQj88InOQNCT10fbdU=", // Thawte Server CA
+			"sRJBQqWhpaKIGcc1NA7/jJ4vgWj+47oYfyU7waOS1+I=", // VeriSign Class 3 Public Primary Certification Authority
+			"AjyBzOjnxk+pQtPBUEhwfTXZu1uH9PVExb8bxWQ68vo=", // VeriSign Class 3 Public Primary Certification Authority - G2
+			"SVqWumuteCQHvVIaALrOZXuzVVVeS7f4FGxxu6V+es4=", // VeriSign Class 3 Public Primary Certification Authority - G3
+			"UZJDjsNp1+4M5x9cbbdflB779y5YRBcV6Z6rBMLIrO4=", // VeriSign Class 3 Public Primary Certification Authority - G4
+			"JbQbUG5JMJUoI6brnx0x3vZF6jilxsapbXGVfjhN8Fg=", // VeriSign Class 3 Public Primary Certification Authority - G5
+			"lnsM2T/O9/J84sJFdnrpsFp3awZJ+ZZbYpCWhGloaHI=", // VeriSign Universal Root Certification Authority
+			"gJ8rquNa+082vWR2znXCABB3kBtq9cTauC4YjGuVwaE=", // Symantec Class 3 Public Primary Certification Authority - G4
+			"lXNUc71no7lajV+QxaIazh4NeUcyBnTUq4R5crkVRNI=", // Symantec Class 3 Public Primary Certification Authority - G6
+		},
+		KeyIDs: []string{
+			"c07a98688d89fbab05640c117daa7d65b8cacc4e", // GeoTrust Global CA
+			"2cd5504197158bf08f36615b4afb6bd999c93392", // GeoTrust Primary Certification Authority
+			"155f35575155fb25b2ad0369fc01a3fabe1155d5", // GeoTrust Primary Certification Authority - G2
+			"c479ca8ea14e031d1cdc6bdb315b943e3f307f2d", // GeoTrust Primary Certification Authority - G3
+			"dabb2eaab00cb8882651745c6d03d3c0d88f7ad6", // GeoTrust Universal CA
+			"76f355e1faa436fbf09f5c6271ed3cf44738102b", // GeoTrust Universal CA 2
+			"7b5b45cfafcecb7afd31921a6ab6f346eb574850", // thawte Primary Root CA
+			"9ad8003000e76b7f8518ee8bb6ce8a0cf811e1bb", // thawte Primary Root CA - G2
+			"ad6caa94609cede4fffa3e0a742b6303f7b659bf", // thawte Primary Root CA - G3
+			"b31691fdeea66ee4b52e498f87788180ece5b1b5", // VeriSign Class 3 Public Primary Certification Authority - G4
+			"7fd365a7c2ddecbbf03009f34339fa02af333133", // VeriSign Class 3 Public Primary Certification Authority - G5
+			"b677fa6948479f5312d5c2ea07327607d1970719", // VeriSign Universal Root Certification Authority
+		},
+		Subjects: []string{
+			"CN=GeoTrust Global CA,O=GeoTrust Inc.,C=US",
+			"CN=GeoTrust Primary Certification Authority,O=GeoTrust Inc.,C=US",
+			"CN=GeoTrust Primary Certification Authority - G2,OU=(c) 2007 GeoTrust Inc. - For authorized use only,O=GeoTrust Inc.,C=US",
+			"CN=GeoTrust Primary Certification Authority - G3,OU=(c) 2008 GeoTrust Inc. - For authorized use only,O=GeoTrust Inc.,C=US",
+			"CN=GeoTrust Universal CA,O=GeoTrust Inc.,C=US",
+			"CN=GeoTrust Universal CA 2,O=GeoTrust Inc.,C=US",
+			"CN=thawte Primary Root CA,OU=Certification Services Division+OU=(c) 2006 thawte\\, Inc. - For authorized use only,O=thawte\\, Inc.,C=US",
+			"CN=thawte Primary Root CA - G2,OU=(c) 2007 thawte\\, Inc. - For authorized use only,O=thawte\\, Inc.,C=US",
+			"CN=thawte Primary Root CA - G3,OU=Certification Services Division+OU=(c) 2008 thawte\\, Inc. - For authorized use only,O=thawte\\, Inc.,C=US",
+			"CN=VeriSign Class 3 Public Primary Certification Authority - G3,OU=VeriSign Trust Network+OU=(c) 1999 VeriSign\\, Inc. - For authorized use only,O=VeriSign\\, Inc.,C=US",
+			"CN=VeriSign Class 3 Public Primary Certification Authority - G4,OU=VeriSign Trust Network+OU=(c) 2007 VeriSign\\, Inc. - For authorized use only,O=VeriSign\\, Inc.,C=US",
+			"CN=VeriSign Class 3 Public Primary Certification Authority - G5,OU=VeriSign Trust Network+OU=(c) 2006 VeriSign\\, Inc. - For authorized use only,O=VeriSign\\, Inc.,C=US",
+			"CN=VeriSign Universal Root Certification Authority,OU=VeriSign Trust Network+OU=(c) 2008 VeriSign\\, Inc. - For authorized use only,O=VeriSign\\, Inc.,C=US",
+		},
+		Organizations: []string{"GeoTrust Inc.", "thawte, Inc.", "Thawte Consulting cc", "VeriSign, Inc.", "Symantec Corporation"},
+		Distrusts: []Distrust{
+			{Client: "Chrome", Since: "2018-10-16"},
+			{Client: "Firefox", Since: "2018-10-23"},
+		},
+	},
+	{
+		Name:   "WoSign and StartCom",
+		Reason: "backdated certificates and an undisclosed acquisition",
+		SPKIHashes: []string{
+			"1qGEQ9NI25lPk0zNjmNdgzonrB5W+K+vfJfLT0Pqtos=", // Certification Authority of WoSign
+			"OBo/x6iwgvooYTpNB/LHVT9OGRjuB8qp6LfO3lqcoGo=", // Certification Authority of WoSign G2
+			"2xXABitSDzGKGdrP7NZPnno/vmCf1YZ5byCuAo6OMFg=", // CA 沃通根证书
+			"eu3d82sY+Ky3N5/hzhgyErI1DQeIq+DoJFe+m62tbVQ=", // CA WoSign ECC Root
+			"5C8kvU039KouVrl52D0eZSGf4Onjo4Khs8tmyTlV3nU=", // StartCom Certification Authority
+			"FSg5faISiQqDCwuVpZlozvI0dzd531GBzxD6ZHU0u2U=", // StartCom Certification Authority G2
+		},
+		Organizations: []string{"WoSign CA Limited", "StartCom Ltd."},
+		Distrusts: []Distrust{
+			{Client: "Firefox", Since: "2017-01-24", IssuedAfter: "2016-10-21"},
+			{Client: "Chrome", Since: "2017-09-05"},
+		},
+	},
+	{
+		Name:   "CNNIC",
+		Reason: "issued an unconstrained intermediate used for interception",
+		SPKIHashes: []string{
+			"H0IkzshPyZztiB/2/P0+IfjFGcVHqmpd094kcwLOUNE=", // CNNIC ROOT
+			"ndVfxXP1RstqODHRES2HEKb0+C3If1+unToaAo3Tbks=", // China Internet Network Information Center EV Certificates Root
+		},
+		Organizations: []string{"CNNIC", "China Internet Network Information Center"},
+		Distrusts: []Distrust{
+			{Client: "Chrome", Since: "2015-05-19", IssuedAfter: "2015-04-01"},
+		},
+	},
+	{
+		Name:   "Camerfirma",
+		Reason: "repeated compliance failures",
+		SPKIHashes: []string{
+			"iir/vRocXRvcy7f1SLqZX5ZoBrP9DDoA+uLlLzyFOYk=", // Chambers of Commerce Root
+			"ztQ5AqtftXtEIyLcDhcqT7VfcXi4CPlOeApv1sxr2Bg=", // Chambers of Commerce Root - 2008
+			"uJvLuKzUdMG+p9rWUDf0jc7MnfqgYSw8JEWVZBnfMv4=", // CHAMBERS OF COMMERCE ROOT - 2016
+			"Tq2ptTEecYGZ2Y6oK5UAXLqTGYqx+X78vo3GIBYo+K8=", // Global Chambersign Root
+			"knobhWIoBXbQSMUDIa2kPYcD0tlSGhjCi4xGzGquTv0=", // Global Chambersign Root - 2008
+		},
+		KeyIDs: []string{
+			"f924ac0fb2b5f879c0fa60881bc4d94d029e1719", // Chambers of Commerce Root - 2008
+			"b909ca9c1edbd36c3a6baeed54f15b9306352e5e", // Global Chambersign Root - 2008
+		},
+		Subjects: []string{
+			"SERIALNUMBER=A82743287,CN=Chambers of Commerce Root - 2008,O=AC Camerfirma S.A.,L=Madrid (see current address at www.camerfirma.com/address),C=EU",
+			"SERIALNUMBER=A82743287,CN=Global Chambersign Root - 2008,O=AC Camerfirma S.A.,L=Madrid (see current address at www.camerfirma.com/address),C=EU",
+		},
+		Organizations: []string{"AC Camerfirma S.A.", "AC Camerfirma SA CIF A82743287"},
+		Distrusts: []Distrust{
+			{Client: "Chrome", Since: "2021-04-14"},
+		},
+	},
+	{
+		Name:   "TrustCor",
+		Reason: "ties to a company distributing surveillance software",
+		SPKIHashes: []string{
+			"6of0Yt7v/713daoqS34Py5HCLu5t9p7ZAQDMxzsxFHY=", // TrustCor RootCert CA-1
+			"xj1oxkihi3dkHEJ6Zp1hyXaKVfT80DIurJbFdwApnPE=", // TrustCor RootCert CA-2
+			"ev5LBxovH0b4upRKJtWE1ZYLkvtIw7obfKuEkF8yqs0=", // TrustCor ECA-1
+		},
+		KeyIDs: []string{
+			"ee6b493c7a3f0de3b109b78ac8ab199f733350e7", // TrustCor RootCert CA-1
+			"d9fe21406e949ebc9b3d9c7d982019e58c3062b2", // TrustCor RootCert CA-2
+			"449e48f5cc6d48d4a04b7ffe59242f8397999a86", // TrustCor ECA-1
+		},
+		Subjects: []string{
+			"CN=TrustCor RootCert CA-1,OU=TrustCor Certificate Authority,O=TrustCor Systems S. de R.L.,L=Panama City,ST=Panama,C=PA",
+			"CN=TrustCor RootCert CA-2,OU=TrustCor Certificate Authority,O=TrustCor Systems S. de R.L.,L=Panama City,ST=Panama,C=PA",
+			"CN=TrustCor ECA-1,OU=TrustCor Certificate Authority,O=TrustCor Systems S. de R.L.,L=Panama City,ST=Panama,C=PA",
+		},
+		Distrusts: []Distrust{
+			{Client: "Firefox", Since: "2022-12-13", IssuedAfter: "2022-11-30"},
+		},
+	},
+	{
+		Name:   "Entrust (including AffirmTrust)",
+		Reason: "repeated compliance failures",
+		SPKIHashes: []string{
+			"bb+uANN7nNc/j7R95lkXrwDg3d9C286sIMF8AnXuIJU=", // Entrust Root Certification Authority
+			"du6FkDdMcVQ3u8prumAo6t3i3G27uMP2EOhR8R0at/U=", // Entrust Root Certification Authority - G2
+			"NtfHnz0Img/3mXLZCSPepcp2tMy698J1HLFS6UlPUtA=", // Entrust Root Certification Authority - G4
+			"/qK31kX7pz11PB7Jp4cMQOH3sMVh6Se5hb9xGGbjbyI=", // Entrust Root Certification Authority - EC1
+			"HqPF5D7WbC2imDpCpKebHpBnhs6fG1hiFBmgBGOofTg=", // Entrust.net Certification Authority (2048)
+			"bEZLmlsjOl6HTadlwm8EUBDS3c/0V5TwtMfkqvpQFJU=", // AffirmTrust Commercial
+			"lAcq0/WPcPkwmOWl9sBMlscQvYSdgxhJGa6Q64kK5AA=", // AffirmTrust Networking
+			"x/Q7TPW3FWgpT4IrU3YmBfbd0Vyt7Oc56eLDy6YenWc=", // AffirmTrust Premium
+			"MhmwkRT/SVo+tusAwu/qs0ACrl8KVsdnnqCHo/oDfk8=", // AffirmTrust Premium ECC
+		},
+		KeyIDs: []string{
+			"6890e467a4a65380c78666a4f1f74b43fb84bd6d", // Entrust Root Certification Authority
+			"6a72267ad01eef7de73b6951d46c8d9f901266ab", // Entrust Root Certification Authority - G2
+			"9f38c45623c339e8a0716ce8544ce4e83ab1bf67", // Entrust Root Certification Authority - G4
+			"b763e71add8de908a65583a4e06a504165114249", // Entrust Root Certification Authority - EC1
+			"55e481d11180bed889b908a331f9a1240916b970", // Entrust.net Certification Authority (2048)
+			"9d93c6538b5ecaaf3f9f1e0fe59995bc24f6948f", // AffirmTrust Commercial
+			"071fd2e79cdac26ea240b4b07a50105074c4c8bd", // AffirmTrust Networking
+			"9dc067a60c22d926f545aba665521127d845ac63", // AffirmTrust Premium
+			"9aaf297ac011353526513000c36afe40d5aed63c", // AffirmTrust Premium ECC
+		},
+		Subjects: []string{
+			"CN=Entrust Root Certification Authority,OU=www.entrust.net/CPS is incorporated by reference+OU=(c) 2006 Entrust\\, Inc.,O=Entrust\\, Inc.,C=US",
+			"CN=Entrust Root Certification Authority - G2,OU=See www.entrust.net/legal-terms+OU=(c) 2009 Entrust\\, Inc. - for authorized use only,O=Entrust\\, Inc.,C=US",
+			"CN=Entrust Root Certification Authority - G4,OU=See www.entrust.net/legal-terms+OU=(c) 2015 Entrust\\, Inc. - for authorized use only,O=Entrust\\, Inc.,C=US",
+			"CN=Entrust Root Certification Authority - EC1,OU=See www.entrust.net/legal-terms+OU=(c) 2012 Entrust\\, Inc. - for authorized use only,O=Entrust\\, Inc.,C=US",
+			"CN=Entrust.net Certification Authority (2048),OU=www.entrust.net/CPS_2048 incorp. by ref. (limits liab.)+OU=(c) 1999 Entrust.net Limited,O=Entrust.net",
+			"CN=AffirmTrust Commercial,O=AffirmTrust,C=US",
+			"CN=AffirmTrust Networking,O=AffirmTrust,C=US",
+			"CN=AffirmTrust Premium,O=AffirmTrust,C=US",
+			"CN=AffirmTrust Premium ECC,O=AffirmTrust,C=US",
+		},
+		Distrusts: []Distrust{
+			{Client: "Chrome", Since: "2024-11-12", IssuedAfter: "2024-11-11"},
+			{Client: "Apple", Since: "2024-11-15", IssuedAfter: "2024-11-15"},
+			{Client: "Firefox", Since: "2024-11-30", IssuedAfter: "2024-11-30"},
+		},
+	},
+	{
+		Name:   "Chunghwa Telecom",
+		Reason: "repeated compliance failures",
+		SPKIHashes: []string{
+			"YlVMFwBVQ7I3IV8EJo3NL9HEcCQK08hmDiWuLFljD1U=", // ePKI Root Certification Authority
+			"ecqvU0fm5KlMjniphJb8dAIPgJ7eE/Ig+rYQTI3tMp8=", // HiPKI Root CA - G1
+		},
+		KeyIDs: []string{
+			"1e0cf7b667f2e192260945c055392e773f424aa2", // ePKI Root Certification Authority
+			"f27717fa5ea8fef63d71d568bac9460c38d8afb0", // HiPKI Root CA - G1
+		},
+		Subjects: []string{
+			"OU=ePKI Root Certification Authority,O=Chunghwa Telecom Co.\\, Ltd.,C=TW",
+			"CN=HiPKI Root CA - G1,O=Chunghwa Telecom Co.\\, Ltd.,C=TW",
+		},
+		Distrusts: []Distrust{
+			{Client: "Chrome", Since: "2025-08-05", IssuedAfter: "2025-07-31"},
+		},
+	},
+}
+
+// LoadDistrustDatabase adds the CAs listed in a YAML file, using the same
+// fields as DistrustedCA, to the distrust database. This way it can be
+// updated without a new release.
+func LoadDistrustDatabase(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read distrust database %s: %s", path, err)
+	}
+
+	entries := []*DistrustedCA{}
+	if err := yaml.UnmarshalStrict(data, &entries); err != nil {
+		return fmt.Errorf("Unable to parse distrust database %s: %s", path, err)
+	}
+	for _, entry := range entries {
+		if len(entry.SPKIHashes) == 0 && len(entry.KeyIDs) == 0 &&
+			len(entry.Subjects) == 0 && len(entry.Organizations) == 0 {
+			return fmt.Errorf(
+				"Distrust database %s: %s has no spki_sha256, key_ids, subjects or organizations",
+				path, entry.Name)
+		}
+		for _, distrust := range entry.Distrusts {
+			if _, err := distrust.since(); err != nil {
+				return fmt.Errorf("Distrust database %s: %s: %s", path, entry.Name, err)
+			}
+			if _, err := distrust.issuedAfter(); err != nil {
+				return fmt.Errorf("Distrust database %s: %s: %s", path, entry.Name, err)
+			}
+		}
+	}
+
+	distrustDatabase = append(entries, distrustDatabase...)
+	return nil
+}
+
+func parseDistrustDate(field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse(distrustDateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s date '%s', expected YYYY-MM-DD", field, value)
+	}
+	return date, nil
+}
+
+func (d Distrust) since() (time.Time, error) {
+	return parseDistrustDate("since", d.Since)
+}
+
+// issuedAfter is the zero time when all certificates are affected. Since
+// dates have day precision, certificates issued during that day still count
+// as issued before.
+func (d Distrust) issuedAfter() (time.Time, error) {
+	date, err := parseDistrustDate("issued_after", d.IssuedAfter)
+	if err != nil || date.IsZero() {
+		return date, err
+	}
+	return date.AddDate(0, 0, 1), nil
+}
+
+// Affects tells whether the client rejects a certificate issued at issuedAt
+// from the distrusted CA, ignoring when the distrust starts.
+func (d Distrust) Affects(issuedAt time.Time) bool {
+	after, _ := d.issuedAfter()
+	return after.IsZero() || !issuedAt.Before(after)
+}
+
+// findDistrustedCA returns the distrusted CA owning the given SPKI hash.
+func findDistrustedCA(spkiHash string) *DistrustedCA {
+	for _, ca := range distrustDatabase {
+		for _, hash := range ca.SPKIHashes {
+			if hash == spkiHash {
+				return ca
+			}
+		}
+	}
+	return nil
+}
+
+// findDistrustedCAByName returns the distrusted CA whose certificate has the
+// given subject and key ID, without needing the certificate itself.
+func findDistrustedCAByName(name pkix.Name, keyID []byte) *DistrustedCA {
+	subject := name.String()
+	hexKeyID := hex.EncodeToString(keyID)
+	for _, ca := range distrustDatabase {
+		for _, id := range ca.KeyIDs {
+			if len(keyID) > 0 && strings.EqualFold(id, hexKeyID) {
+				return ca
+			}
+		}
+		for _, other := range ca.Subjects {
+			if other == subject {
+				return ca
+			}
+		}
+		for _, organization := range ca.Organizations {
+			for _, other := range name.Organization {
+				if strings.EqualFold(organization, other) {
+					return ca
+				}
+			}
+		}
+	}
+	return nil
+}
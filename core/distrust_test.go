@@ -0,0 +1,192 @@
+package core
+
+import (
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDistrustAffects(t *testing.T) {
+	tests := []struct {
+		issuedAfter string
+		issuedAt    time.Time
+		want        bool
+	}{
+		{"", time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2025-07-31", time.Date(2025, 7, 31, 23, 59, 0, 0, time.UTC), false},
+		{"2025-07-31", time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2025-07-31", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), false},
+	}
+	for _, test := range tests {
+		distrust := Distrust{Client: "Chrome", Since: "2025-08-05", IssuedAfter: test.issuedAfter}
+		if got := distrust.Affects(test.issuedAt); got != test.want {
+			t.Errorf("issued after %q, at %s: got %v, want %v", test.issuedAfter, test.issuedAt, got, test.want)
+		}
+	}
+}
+
+func TestDistrustedIssuerWarningSeverity(t *testing.T) {
+	issuedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	future := time.Now().AddDate(1, 0, 0).Format(distrustDateLayout)
+	tests := []struct {
+		name      string
+		distrusts []Distrust
+		want      Severity
+	}{
+		{"already rejected", []Distrust{{Client: "Chrome", Since: "2020-01-01"}}, SeverityError},
+		{"rejected later", []Distrust{{Client: "Chrome", Since: future}}, SeverityWarning},
+		{
+			"renewals only",
+			[]Distrust{{Client: "Chrome", Since: "2020-01-01", IssuedAfter: "2025-06-30"}},
+			SeverityInfo,
+		},
+		{
+			"worst client wins",
+			[]Distrust{{Client: "Chrome", Since: future}, {Client: "Firefox", Since: "2020-01-01"}},
+			SeverityError,
+		},
+	}
+	for _, test := range tests {
+		w := DistrustedIssuerWarning{issuedAt: issuedAt, ca: &DistrustedCA{Distrusts: test.distrusts}}
+		if got := w.Severity(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFindDistrustedCAByName(t *testing.T) {
+	defer func(database []*DistrustedCA) { distrustDatabase = database }(distrustDatabase)
+	ca := &DistrustedCA{
+		Name:          "Test",
+		KeyIDs:        []string{"F5F2B453F75FE86A3F68DEA70FD96148FDD06120"},
+		Subjects:      []string{"CN=Test Root,O=Test"},
+		Organizations: []string{"Test Family"},
+	}
+	distrustDatabase = []*DistrustedCA{ca}
+
+	tests := []struct {
+		name  string
+		keyID string
+		want  bool
+	}{
+		{"CN=Other", "f5f2b453f75fe86a3f68dea70fd96148fdd06120", true},
+		{"CN=Test Root,O=Test", "", true},
+		{"CN=Other,O=test family", "", true},
+		{"CN=Other,O=Other", "00", false},
+		{"CN=Test Root", "", false},
+	}
+	for _, test := range tests {
+		name := parseTestName(t, test.name)
+		keyID := []byte{}
+		if test.keyID != "" {
+			fmt.Sscanf(test.keyID, "%x", &keyID)
+		}
+		if got := findDistrustedCAByName(name, keyID) == ca; got != test.want {
+			t.Errorf("%s/%s: got %v, want %v", test.name, test.keyID, got, test.want)
+		}
+	}
+}
+
+// parseTestName builds a pkix.Name from the "CN=...,O=..." form its String
+// method produces.
+func parseTestName(t *testing.T, s string) pkix.Name {
+	t.Helper()
+	name := pkix.Name{}
+	for _, part := range strings.Split(s, ",") {
+		switch fields := strings.SplitN(part, "=", 2); fields[0] {
+		case "CN":
+			name.CommonName = fields[1]
+		case "O":
+			name.Organization = append(name.Organization, fields[1])
+		default:
+			t.Fatalf("unexpected name part %s", part)
+		}
+	}
+	return name
+}
+
+func TestLoadDistrustDatabase(t *testing.T) {
+	defer func(database []*DistrustedCA) { distrustDatabase = database }(distrustDatabase)
+
+	fixture := func(name string) *Certificate {
+		return loadCertificateFixture(t, filepath.Join("testdata", "pkcs12", name))
+	}
+	leaf, intermediate := fixture("leaf.crt"), fixture("intermediate.crt")
+
+	tests := []struct {
+		name     string
+		database string
+		err      string
+	}{
+		{
+			name: "no criteria",
+			database: `
+- name: Nothing
+  distrust:
+    - client: Chrome
+      since: 2020-01-01
+`,
+			err: "Nothing has no spki_sha256, key_ids, subjects or organizations",
+		},
+		{
+			name: "bad date",
+			database: `
+- name: Bad date
+  organizations: [Test]
+  distrust:
+    - client: Chrome
+      since: 01/01/2020
+`,
+			err: "Bad date: invalid since date '01/01/2020'",
+		},
+		{
+			name:     "unknown field",
+			database: "- name: Typo\n  spki: abc\n",
+			err:      "Unable to parse distrust database",
+		},
+		{
+			name: "by SPKI hash",
+			database: fmt.Sprintf(`
+- name: Test Intermediate
+  spki_sha256: [%s]
+  distrust:
+    - client: Chrome
+      since: 2020-01-01
+`, intermediate.SPKIHash()),
+		},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "distrust.yaml")
+		if err := ioutil.WriteFile(path, []byte(test.database), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := LoadDistrustDatabase(path)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %s", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+		}
+	}
+
+	// The intermediate is now distrusted, whether it's served or only
+	// named in the leaf.
+	chain := &CertificateChain{Leaf: leaf, Intermediates: []*Certificate{intermediate}}
+	w := TryDistrustedIssuerWarning(chain, 0)
+	if w == nil || w.Severity() != SeverityError {
+		t.Errorf("served intermediate: got %v, want an error", w)
+	}
+	if w := TryLeafDistrustedIssuerWarning(chain); w != nil {
+		t.Errorf("leaf with its intermediate served: got %v, want none", w)
+	}
+
+	distrustDatabase[0].KeyIDs = []string{fmt.Sprintf("%x", intermediate.Certificate.SubjectKeyId)}
+	distrustDatabase[0].SPKIHashes = nil
+	if w := TryLeafDistrustedIssuerWarning(&CertificateChain{Leaf: leaf}); w == nil {
+		t.Error("leaf served alone: got no warning")
+	}
+}
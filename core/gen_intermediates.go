@@ -0,0 +1,89 @@
+//go:build ignore
+// +build ignore
+
+// gen_intermediates regenerates the built-in intermediate database,
+// intermediates_builtin.pem, from a CCADB report given by URL or as a
+// downloaded file:
+//
+//	go run gen_intermediates.go [url|file]
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cesarkawakami/chaintool/core"
+)
+
+const defaultCCADBURL = "https://ccadb.my.salesforce-sites.com/mozilla/MozillaIntermediateCertsCSVReport"
+
+func main() {
+	url := defaultCCADBURL
+	if len(os.Args) > 1 {
+		url = os.Args[1]
+	}
+
+	dir, err := ioutil.TempDir("", "intermediates")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	csvPath := url
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		csvPath = dir + "/ccadb.csv"
+		if err := download(url, csvPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	imported, skipped, err := core.ImportIntermediateDatabase(csvPath, dir+"/intermediates.pem")
+	if err != nil {
+		log.Fatal(err)
+	}
+	// An empty snapshot would build fine and silently disable offline chain
+	// completion, so refuse to write one.
+	if imported == 0 {
+		log.Fatalf("no intermediates found in %s", url)
+	}
+	bundle, err := ioutil.ReadFile(dir + "/intermediates.pem")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The header records where the snapshot comes from and when it was
+	// taken; pem.Decode skips it.
+	header := fmt.Sprintf(
+		"# Generated by gen_intermediates.go; DO NOT EDIT.\n"+
+			"# %d intermediates from %s, taken %s.\n\n",
+		imported, url, time.Now().UTC().Format("2006-01-02"))
+	if err := ioutil.WriteFile("intermediates_builtin.pem", append([]byte(header), bundle...), 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%d intermediates imported, %d skipped", imported, skipped)
+}
+
+func download(url, path string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
@@ -0,0 +1,294 @@
+package core
+
+import (
+	"bytes"
+	"crypto/x509"
+	_ "embed"
+	"encoding/csv"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//go:generate go run gen_intermediates.go
+
+// The intermediate database holds publicly disclosed intermediate CA
+// certificates, so chains can be completed without fetching anything. It's
+// made of the built-in set, the user's copy imported from a CCADB export
+// (see ImportIntermediateDatabase) and any extra files loaded with
+// LoadIntermediateDatabase. Certificates are indexed by subject and by SKI.
+var (
+	intermediatesBySubject map[string][]*Certificate
+	intermediatesBySKI     map[string][]*Certificate
+	intermediatesLoaded    map[string]bool
+)
+
+// builtinIntermediates is the CCADB snapshot written by gen_intermediates.go,
+// whose header says when it was taken.
+//
+//go:embed intermediates_builtin.pem
+var builtinIntermediates string
+
+// intermediateOwnerHeader is the PEM header naming the CA owner of each
+// certificate in database files.
+const intermediateOwnerHeader = "CA-Owner"
+
+// DefaultIntermediateDatabasePath is where the imported database lives, e.g.
+// ~/.config/chaintool/intermediates.pem on Linux.
+func DefaultIntermediateDatabasePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("Unable to locate the user config directory: %s", err)
+	}
+	return filepath.Join(dir, "chaintool", "intermediates.pem"), nil
+}
+
+// intermediateDatabase loads the built-in set and the user's imported
+// database the first time it's needed.
+func intermediateDatabase() {
+	if intermediatesLoaded != nil {
+		return
+	}
+	intermediatesBySubject = map[string][]*Certificate{}
+	intermediatesBySKI = map[string][]*Certificate{}
+	intermediatesLoaded = map[string]bool{}
+
+	if err := addIntermediates([]byte(builtinIntermediates), "built-in"); err != nil {
+		warning("Unable to load the built-in intermediate database: %s\n", err)
+	}
+	if path, err := DefaultIntermediateDatabasePath(); err == nil {
+		if _, err := os.Stat(path); err == nil {
+			if err := LoadIntermediateDatabase(path); err != nil {
+				warning("%s\n", err)
+			}
+		}
+	}
+}
+
+// LoadIntermediateDatabase adds the intermediates in a PEM file or a CCADB
+// CSV export to the database.
+func LoadIntermediateDatabase(path string) error {
+	intermediateDatabase()
+	if intermediatesLoaded[path] {
+		return nil
+	}
+	intermediatesLoaded[path] = true
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read intermediate database %s: %s", path, err)
+	}
+	if isCSV(data) {
+		if data, _, err = intermediatesFromCCADB(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("Unable to parse intermediate database %s: %s", path, err)
+		}
+	}
+	if err := addIntermediates(data, path); err != nil {
+		return fmt.Errorf("Unable to parse intermediate database %s: %s", path, err)
+	}
+	return nil
+}
+
+// isCSV tells CCADB exports from PEM files by their header row.
+func isCSV(data []byte) bool {
+	firstLine := bytes.SplitN(bytes.TrimSpace(data), []byte("\n"), 2)[0]
+	return bytes.Contains(firstLine, []byte(",")) && !bytes.Contains(firstLine, []byte("-----BEGIN"))
+}
+
+// addIntermediates indexes the certificates in PEM data. Roots and non-CA
+// certificates can't be intermediates, and a self-signed one would be found
+// as its own issuer, so they're skipped with a warning.
+func addIntermediates(data []byte, database string) error {
+	skipped := 0
+	defer func() {
+		if skipped > 0 {
+			warning("Skipped %d self-signed or non-CA certificates in intermediate database %s\n", skipped, database)
+		}
+	}()
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		x509Cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return err
+		}
+		source := "intermediate DB " + database
+		if owner := block.Headers[intermediateOwnerHeader]; owner != "" {
+			source += " [" + owner + "]"
+		}
+		cert := &Certificate{Certificate: x509Cert, Source: source}
+		if !x509Cert.IsCA || cert.IsSelfSigned() {
+			skipped++
+			continue
+		}
+
+		subject := string(x509Cert.RawSubject)
+		intermediatesBySubject[subject] = append(intermediatesBySubject[subject], cert)
+		ski := string(cert.SubjectKeyID())
+		intermediatesBySKI[ski] = append(intermediatesBySKI[ski], cert)
+	}
+}
+
+// intermediateCandidates returns the database certificates that may have
+// issued cert: the ones whose subject is its issuer name, or whose SKI is its
+// AKI.
+func intermediateCandidates(cert *Certificate) []*Certificate {
+	intermediateDatabase()
+
+	candidates := []*Certificate{}
+	seen := map[*Certificate]bool{}
+	for _, candidate := range intermediatesBySubject[string(cert.Certificate.RawIssuer)] {
+		candidates = append(candidates, candidate)
+		seen[candidate] = true
+	}
+	if len(cert.Certificate.AuthorityKeyId) > 0 {
+		for _, candidate := range intermediatesBySKI[string(cert.Certificate.AuthorityKeyId)] {
+			if !seen[candidate] {
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+	return candidates
+}
+
+// considerDatabase records the database candidates for cert's issuer and
+// returns the first one that fits, if any.
+func (t *Trace) considerDatabase(cert *Certificate) *Certificate {
+	var issuer *Certificate
+	for _, candidate := range intermediateCandidates(cert) {
+		if t.considerIssuer(cert, candidate, "intermediate DB") && issuer == nil {
+			issuer = candidate
+		}
+	}
+	return issuer
+}
+
+// CompleteChain appends intermediates from the database until the chain
+// reaches a trusted root, returning how many were added. Chains ending in a
+// self-signed certificate, or whose last issuer isn't in the database, are
+// left as they are.
+func CompleteChain(chain *CertificateChain) int {
+	var trace *Trace
+	added := 0
+	for len(chain.Intermediates) < maxTraceDepth {
+		last := chain.pathEnd()
+		if last.IsSelfSigned() || last.IsTrusted() || trace.considerRoots(last) != nil {
+			break
+		}
+
+		issuer := trace.considerDatabase(last)
+		if issuer == nil {
+			break
+		}
+		chain.Intermediates = append(chain.Intermediates, issuer)
+		added++
+	}
+	return added
+}
+
+// ImportIntermediateDatabase converts a CCADB export of intermediate
+// certificates (the CSV report including PEM) into a database file at path,
+// replacing it. Expired certificates are left out. It returns how many
+// certificates were imported and skipped.
+func ImportIntermediateDatabase(csvPath, path string) (int, int, error) {
+	f, err := os.Open(csvPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Unable to read CCADB export %s: %s", csvPath, err)
+	}
+	defer f.Close()
+
+	data, skipped, err := intermediatesFromCCADB(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Unable to parse CCADB export %s: %s", csvPath, err)
+	}
+	imported := bytes.Count(data, []byte("-----BEGIN CERTIFICATE-----"))
+	if imported == 0 {
+		return 0, skipped, fmt.Errorf("CCADB export %s contains no usable certificates", csvPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, 0, fmt.Errorf("Unable to write intermediate database %s: %s", path, err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return 0, 0, fmt.Errorf("Unable to write intermediate database %s: %s", path, err)
+	}
+	return imported, skipped, nil
+}
+
+// intermediatesFromCCADB reads a CCADB CSV report and returns its unexpired
+// CA certificates as PEM, each with a CA-Owner header. Rows without a
+// parseable certificate, with self-signed or expired certificates are
+// skipped and counted.
+func intermediatesFromCCADB(in io.Reader) ([]byte, int, error) {
+	reader := csv.NewReader(in)
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, 0, err
+	}
+	pemColumn, ownerColumn := -1, -1
+	for index, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case strings.Contains(name, "pem") && pemColumn < 0:
+			pemColumn = index
+		case name == "ca owner":
+			ownerColumn = index
+		}
+	}
+	if pemColumn < 0 {
+		return nil, 0, fmt.Errorf("no PEM column; use a CCADB report that includes PEM data")
+	}
+
+	out := &bytes.Buffer{}
+	skipped := 0
+	now := time.Now()
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, 0, err
+		}
+		if pemColumn >= len(record) {
+			skipped++
+			continue
+		}
+
+		block, _ := pem.Decode([]byte(strings.Trim(record[pemColumn], "' \r\n")))
+		if block == nil || block.Type != "CERTIFICATE" {
+			skipped++
+			continue
+		}
+		x509Cert, err := x509.ParseCertificate(block.Bytes)
+		cert := &Certificate{Certificate: x509Cert}
+		if err != nil || !x509Cert.IsCA || cert.IsSelfSigned() || now.After(x509Cert.NotAfter) {
+			skipped++
+			continue
+		}
+
+		headers := map[string]string{}
+		if ownerColumn >= 0 && ownerColumn < len(record) && strings.TrimSpace(record[ownerColumn]) != "" {
+			headers[intermediateOwnerHeader] = strings.TrimSpace(record[ownerColumn])
+		}
+		if err := pem.Encode(out, &pem.Block{Type: "CERTIFICATE", Headers: headers, Bytes: x509Cert.Raw}); err != nil {
+			return nil, 0, err
+		}
+	}
+	return out.Bytes(), skipped, nil
+}
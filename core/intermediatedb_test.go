@@ -0,0 +1,192 @@
+package core
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTestIntermediateDatabase empties the intermediate database for the
+// rest of the test, leaving out the built-in set and the user's import.
+func useTestIntermediateDatabase(t *testing.T) {
+	t.Helper()
+	bySubject, bySKI, loaded := intermediatesBySubject, intermediatesBySKI, intermediatesLoaded
+	t.Cleanup(func() {
+		intermediatesBySubject, intermediatesBySKI, intermediatesLoaded = bySubject, bySKI, loaded
+	})
+	intermediatesBySubject = map[string][]*Certificate{}
+	intermediatesBySKI = map[string][]*Certificate{}
+	intermediatesLoaded = map[string]bool{}
+}
+
+// ccadbFixture is a CCADB "all certificate records" report: an intermediate
+// with its owner, then rows that must be skipped.
+type ccadbFixture struct {
+	csv          []byte
+	root         *Certificate
+	intermediate *Certificate
+}
+
+func newCCADBFixture(t *testing.T) *ccadbFixture {
+	t.Helper()
+	certificate := func(name string, isCA bool, issuer *Certificate, mutate func(*x509.Certificate)) *Certificate {
+		cert, err := newTestCertificate(name, isCA, issuer, testCertificateOptions{mutate: mutate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	quotedPEM := func(cert *Certificate) string {
+		return "'" + string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate.Raw})) + "'"
+	}
+
+	root := certificate("Test Root", true, nil, nil)
+	intermediate := certificate("Test Intermediate", true, root, nil)
+	expired := certificate("Test Expired Intermediate", true, root, func(template *x509.Certificate) {
+		template.NotBefore = time.Now().AddDate(-2, 0, 0)
+		template.NotAfter = time.Now().AddDate(0, 0, -1)
+	})
+	leaf := certificate(testPKIHostname, false, intermediate, nil)
+
+	out := &bytes.Buffer{}
+	writer := csv.NewWriter(out)
+	rows := [][]string{
+		{"CA Owner", "Certificate Name", "SHA-256 Fingerprint", "PEM Info"},
+		{"Test CA Inc.", "Test Intermediate", "00", quotedPEM(intermediate)},
+		{"Test CA Inc.", "Test Root", "00", quotedPEM(root)},
+		{"Test CA Inc.", "Test Expired Intermediate", "00", quotedPEM(expired)},
+		{"Test CA Inc.", "Not a CA", "00", quotedPEM(leaf)},
+		{"Test CA Inc.", "Garbage", "00", "'not a certificate'"},
+		{"Short row"},
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		t.Fatal(err)
+	}
+	return &ccadbFixture{csv: out.Bytes(), root: root, intermediate: intermediate}
+}
+
+func TestIntermediatesFromCCADB(t *testing.T) {
+	f := newCCADBFixture(t)
+
+	data, skipped, err := intermediatesFromCCADB(bytes.NewReader(f.csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 5 {
+		t.Errorf("got %d skipped rows, want 5", skipped)
+	}
+	block, rest := pem.Decode(data)
+	if block == nil || !bytes.Equal(block.Bytes, f.intermediate.Certificate.Raw) {
+		t.Fatalf("got %q, want the intermediate", data)
+	}
+	if owner := block.Headers[intermediateOwnerHeader]; owner != "Test CA Inc." {
+		t.Errorf("got owner %q", owner)
+	}
+	if len(bytes.TrimSpace(rest)) != 0 {
+		t.Errorf("got more certificates: %q", rest)
+	}
+
+	if _, _, err := intermediatesFromCCADB(strings.NewReader("CA Owner,Certificate Name\nTest,Test\n")); err == nil ||
+		!strings.Contains(err.Error(), "no PEM column") {
+		t.Errorf("got error %v, want one about the PEM column", err)
+	}
+}
+
+func TestImportIntermediateDatabase(t *testing.T) {
+	useTestIntermediateDatabase(t)
+	f := newCCADBFixture(t)
+	useTestRoots(t, f.root)
+
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "AllCertificateRecordsReport.csv")
+	if err := ioutil.WriteFile(csvPath, f.csv, 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config", "intermediates.pem")
+	imported, skipped, err := ImportIntermediateDatabase(csvPath, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 1 || skipped != 5 {
+		t.Errorf("got %d imported and %d skipped, want 1 and 5", imported, skipped)
+	}
+
+	// The imported file and the CSV itself both load.
+	for _, database := range []string{path, csvPath} {
+		useTestIntermediateDatabase(t)
+		if err := LoadIntermediateDatabase(database); err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := newTestCertificate(testPKIHostname, false, f.intermediate, testCertificateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		chain := &CertificateChain{Leaf: leaf}
+		if added := CompleteChain(chain); added != 1 {
+			t.Fatalf("%s: got %d intermediates added, want 1", database, added)
+		}
+		if want := "intermediate DB " + database + " [Test CA Inc.]"; chain.Intermediates[0].Source != want {
+			t.Errorf("got source %q, want %q", chain.Intermediates[0].Source, want)
+		}
+	}
+
+	empty := filepath.Join(dir, "empty.csv")
+	if err := ioutil.WriteFile(empty, []byte("CA Owner,PEM Info\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ImportIntermediateDatabase(empty, path); err == nil ||
+		!strings.Contains(err.Error(), "contains no usable certificates") {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestAddIntermediates(t *testing.T) {
+	useTestIntermediateDatabase(t)
+	f := newCCADBFixture(t)
+	leaf, err := newTestCertificate(testPKIHostname, false, f.intermediate, testCertificateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte{}
+	for _, cert := range []*Certificate{f.root, f.intermediate, leaf} {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate.Raw})...)
+	}
+	if err := addIntermediates(data, "test"); err != nil {
+		t.Fatal(err)
+	}
+	// Only the intermediate is indexed: the root would be its own issuer.
+	if got := intermediateCandidates(f.root); len(got) != 0 {
+		t.Errorf("got %d candidates for the root, want none", len(got))
+	}
+	if got := intermediateCandidates(leaf); len(got) != 1 || got[0].ID() != f.intermediate.ID() {
+		t.Errorf("got candidates %v for the leaf, want the intermediate", got)
+	}
+	if got := len(intermediatesBySubject) + len(intermediatesBySKI); got != 2 {
+		t.Errorf("got %d index entries, want 2", got)
+	}
+
+	// The built-in set parses.
+	if err := addIntermediates([]byte(builtinIntermediates), "built-in"); err != nil {
+		t.Errorf("built-in set: %s", err)
+	}
+}
+
+func TestBuiltinIntermediates(t *testing.T) {
+	if !strings.HasPrefix(builtinIntermediates, "# Generated by gen_intermediates.go") {
+		t.Skip("intermediates_builtin.pem is the placeholder; run `go generate ./core` to take a snapshot")
+	}
+	useTestIntermediateDatabase(t)
+	if err := addIntermediates([]byte(builtinIntermediates), "built-in"); err != nil {
+		t.Fatal(err)
+	}
+	if len(intermediatesBySubject) == 0 {
+		t.Error("the built-in set has no intermediates")
+	}
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeECDSA   = "ecdsa"
+	KeyTypeEd25519 = "ed25519"
+)
+
+type KeySpec struct {
+	Type  string `yaml:"type"`
+	Bits  int    `yaml:"bits"`
+	Curve string `yaml:"curve"`
+}
+
+// ParseKeySpec parses the compact "type[:size]" form used on the command
+// line, such as "rsa:4096", "ecdsa:P-384" or "ed25519".
+func ParseKeySpec(s string) (KeySpec, error) {
+	parts := strings.SplitN(s, ":", 2)
+	spec := KeySpec{Type: strings.ToLower(parts[0])}
+	if len(parts) == 1 {
+		return spec, nil
+	}
+
+	switch spec.Type {
+	case KeyTypeRSA:
+		bits, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return spec, fmt.Errorf("Invalid RSA key size '%s'", parts[1])
+		}
+		spec.Bits = bits
+	case KeyTypeECDSA:
+		spec.Curve = parts[1]
+	default:
+		return spec, fmt.Errorf("Key type '%s' doesn't take a size", spec.Type)
+	}
+	return spec, nil
+}
+
+func GeneratePrivateKey(spec KeySpec) (crypto.PrivateKey, error) {
+	switch strings.ToLower(spec.Type) {
+	case KeyTypeRSA:
+		bits := spec.Bits
+		if bits == 0 {
+			bits = 2048
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case KeyTypeECDSA:
+		curve, err := namedCurve(spec.Curve)
+		if err != nil {
+			return nil, err
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf(
+			"Unknown key type '%s', expected one of rsa, ecdsa or ed25519", spec.Type)
+	}
+}
+
+func namedCurve(name string) (elliptic.Curve, error) {
+	switch strings.ToUpper(name) {
+	case "", "P-256", "P256", "PRIME256V1":
+		return elliptic.P256(), nil
+	case "P-384", "P384", "SECP384R1":
+		return elliptic.P384(), nil
+	case "P-521", "P521", "SECP521R1":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("Unknown curve '%s', expected one of P-256, P-384 or P-521", name)
+	}
+}
+
+func EncodePrivateKeyPEM(privateKey crypto.PrivateKey) ([]byte, error) {
+	return (&Certificate{PrivateKey: privateKey}).PrivateKeyToPEM()
+}
+
+func LoadPrivateKeyFromFile(keyPath string) (crypto.PrivateKey, error) {
+	holder := &Certificate{}
+	if err := holder.LoadPrivateKeyFromFile(keyPath); err != nil {
+		return nil, err
+	}
+	return holder.PrivateKey, nil
+}
@@ -0,0 +1,31 @@
+package core
+
+import "testing"
+
+func TestParseKeySpec(t *testing.T) {
+	tests := []struct {
+		input string
+		want  KeySpec
+		err   string
+	}{
+		{input: "rsa", want: KeySpec{Type: KeyTypeRSA}},
+		{input: "RSA:4096", want: KeySpec{Type: KeyTypeRSA, Bits: 4096}},
+		{input: "ecdsa:P-384", want: KeySpec{Type: KeyTypeECDSA, Curve: "P-384"}},
+		{input: "ed25519", want: KeySpec{Type: KeyTypeEd25519}},
+		{input: "rsa:big", err: "Invalid RSA key size 'big'"},
+		{input: "ed25519:256", err: "Key type 'ed25519' doesn't take a size"},
+	}
+	for _, test := range tests {
+		got, err := ParseKeySpec(test.input)
+		switch {
+		case test.err != "":
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: got error %v, want %q", test.input, err, test.err)
+			}
+		case err != nil:
+			t.Errorf("%s: %s", test.input, err)
+		case got != test.want:
+			t.Errorf("%s: got %+v, want %+v", test.input, got, test.want)
+		}
+	}
+}
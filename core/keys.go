@@ -0,0 +1,96 @@
+package core
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"reflect"
+)
+
+func publicKeyAlgorithmName(publicKey crypto.PublicKey) string {
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA"
+	case *ecdsa.PublicKey:
+		return "ECDSA"
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return fmt.Sprintf("%T", publicKey)
+	}
+}
+
+func publicKeyDescription(publicKey crypto.PublicKey) string {
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d-bit", publicKey.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA %s", publicKey.Curve.Params().Name)
+	default:
+		return publicKeyAlgorithmName(publicKey)
+	}
+}
+
+// publicKeySize is the RSA modulus or ECDSA curve size in bits, comparable
+// only between keys of the same algorithm.
+func publicKeySize(publicKey crypto.PublicKey) int {
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		return publicKey.N.BitLen()
+	case *ecdsa.PublicKey:
+		return publicKey.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	default:
+		return 0
+	}
+}
+
+func DescribePrivateKey(privateKey crypto.PrivateKey) string {
+	publicKey, err := publicKeyFromPrivateKey(privateKey)
+	if err != nil {
+		return fmt.Sprintf("%T", privateKey)
+	}
+	return publicKeyDescription(publicKey)
+}
+
+func publicKeyFromPrivateKey(privateKey crypto.PrivateKey) (crypto.PublicKey, error) {
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("Unknown private key type: %T", privateKey)
+	}
+	return signer.Public(), nil
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	equaler, ok := a.(interface {
+		Equal(crypto.PublicKey) bool
+	})
+	if !ok {
+		return false
+	}
+	return equaler.Equal(b)
+}
+
+func ensurePrivateKeyMatches(publicKey crypto.PublicKey, privateKey crypto.PrivateKey) error {
+	privatePublicKey, err := publicKeyFromPrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	if reflect.TypeOf(publicKey) != reflect.TypeOf(privatePublicKey) {
+		return fmt.Errorf(
+			"Public key is %s but private key is %s",
+			publicKeyAlgorithmName(publicKey), publicKeyAlgorithmName(privatePublicKey))
+	}
+
+	if !publicKeysEqual(publicKey, privatePublicKey) {
+		return fmt.Errorf(
+			"Private %s key doesn't match public %s key",
+			publicKeyAlgorithmName(privatePublicKey), publicKeyAlgorithmName(publicKey))
+	}
+
+	return nil
+}
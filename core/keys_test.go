@@ -0,0 +1,74 @@
+package core
+
+import (
+	"crypto"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The keys in testdata/keys were generated with openssl genpkey; the
+// -pkcs1 and -sec1 files are the same keys in their traditional formats,
+// and ed25519.der is ed25519.pem in DER.
+func loadKeyFixture(t *testing.T, name string) crypto.PrivateKey {
+	t.Helper()
+	path := filepath.Join("testdata", "keys", name)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := parsePrivateKey(data, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	tests := []struct {
+		file        string
+		description string
+	}{
+		{"ed25519.pem", "Ed25519"},
+		{"ed25519.der", "Ed25519"},
+		{"rsa.pem", "RSA 1024-bit"},
+		{"rsa-pkcs1.pem", "RSA 1024-bit"},
+		{"ec.pem", "ECDSA P-384"},
+		{"ec-sec1.pem", "ECDSA P-384"},
+	}
+	for _, test := range tests {
+		key := loadKeyFixture(t, test.file)
+		if got := DescribePrivateKey(key); got != test.description {
+			t.Errorf("%s: got %s, want %s", test.file, got, test.description)
+		}
+	}
+}
+
+func TestEnsurePrivateKeyMatches(t *testing.T) {
+	tests := []struct {
+		public  string
+		private string
+		err     string
+	}{
+		{public: "ed25519.pem", private: "ed25519.der"},
+		{public: "rsa.pem", private: "rsa-pkcs1.pem"},
+		{public: "ec.pem", private: "ec-sec1.pem"},
+		{public: "ed25519.pem", private: "ed25519-other.pem", err: "Private Ed25519 key doesn't match public Ed25519 key"},
+		{public: "ed25519.pem", private: "ec.pem", err: "Public key is Ed25519 but private key is ECDSA"},
+		{public: "rsa.pem", private: "ed25519.pem", err: "Public key is RSA but private key is Ed25519"},
+	}
+	for _, test := range tests {
+		publicKey, err := publicKeyFromPrivateKey(loadKeyFixture(t, test.public))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ensurePrivateKeyMatches(publicKey, loadKeyFixture(t, test.private))
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s/%s: %s", test.public, test.private, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s/%s: got error %v, want %q", test.public, test.private, err, test.err)
+		}
+	}
+}
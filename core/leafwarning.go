@@ -0,0 +1,599 @@
+package core
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// leafWarningTriers only make sense for the certificate a server presents,
+// so they run in addition to warningTriers for the leaf of a chain.
+var leafWarningTriers = []func(*Certificate) Warning{
+	TryValidityTooLongWarning,
+	TryMissingSANWarning,
+	TryCommonNameNotInSANWarning,
+	TryMissingServerAuthWarning,
+	TryKeyUsageMismatchWarning,
+	TryReservedIPAddressWarning,
+	TryIPAddressInSANWarning,
+	TryInternalNameWarning,
+	TryBadWildcardWarning,
+	TryNonPositiveSerialWarning,
+	TrySerialTooLongWarning,
+	TryLeafIsCAWarning,
+	TryWeakCurveWarning,
+}
+
+// LeafWarnings returns the warnings for the certificate when used as the
+// leaf of a chain.
+func (c *Certificate) LeafWarnings() []Warning {
+	rv := c.lintWarnings()
+	for _, trier := range leafWarningTriers {
+		w := trier(c)
+		if w != nil {
+			rv = append(rv, w)
+		}
+	}
+	return warningPolicy.apply(c, rv)
+}
+
+type validityLimit struct {
+	since time.Time
+	days  int
+}
+
+// leafValidityLimits are the CA/B Forum Baseline Requirements limits on
+// subscriber certificate validity, by issuance date, latest first.
+var leafValidityLimits = []validityLimit{
+	{time.Date(2029, 3, 15, 0, 0, 0, 0, time.UTC), 47},
+	{time.Date(2027, 3, 15, 0, 0, 0, 0, time.UTC), 100},
+	{time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC), 200},
+	{time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC), 398},
+	{time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), 825},
+	{time.Time{}, 1185},
+}
+
+func maxLeafValidityDays(notBefore time.Time) int {
+	for _, limit := range leafValidityLimits {
+		if !notBefore.Before(limit.since) {
+			return limit.days
+		}
+	}
+	return leafValidityLimits[len(leafValidityLimits)-1].days
+}
+
+// validityDays counts NotAfter itself as valid, as RFC 5280 does.
+func validityDays(cert *x509.Certificate) float64 {
+	return cert.NotAfter.Sub(cert.NotBefore).Hours()/24 + 1.0/86400
+}
+
+type ValidityTooLongWarning struct {
+	days  float64
+	limit int
+}
+
+func (w ValidityTooLongWarning) ID() string {
+	return "validity-too-long"
+}
+
+func (w ValidityTooLongWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w ValidityTooLongWarning) Title() string {
+	return "Validity period is too long."
+}
+
+func (w ValidityTooLongWarning) Description() string {
+	return formatDescription(`
+This certificate is valid for %.0f days, but the limit for certificates
+issued at that date is %d days. Browsers reject publicly trusted
+certificates exceeding the CA/B Forum limits.
+`, w.days, w.limit)
+}
+
+func TryValidityTooLongWarning(c *Certificate) Warning {
+	days := validityDays(c.Certificate)
+	limit := warningPolicy.threshold(
+		"validity-too-long", maxLeafValidityDays(c.Certificate.NotBefore))
+	if days > float64(limit) {
+		return ValidityTooLongWarning{days: days, limit: limit}
+	}
+	return nil
+}
+
+type CommonNameNotInSANWarning struct {
+	commonName string
+}
+
+func (w CommonNameNotInSANWarning) ID() string {
+	return "cn-not-in-san"
+}
+
+func (w CommonNameNotInSANWarning) Severity() Severity {
+	return SeverityWarning
+}
+
+func (w CommonNameNotInSANWarning) Title() string {
+	return "Common Name isn't a Subject Alternative Name."
+}
+
+func (w CommonNameNotInSANWarning) Description() string {
+	return formatDescription(`
+The Common Name (%s) isn't listed in the Subject Alternative Names.
+Browsers ignore the Common Name, so the certificate isn't valid for it.
+`, w.commonName)
+}
+
+func TryCommonNameNotInSANWarning(c *Certificate) Warning {
+	commonName := c.Certificate.Subject.CommonName
+	if commonName == "" || len(c.SANs()) == 0 {
+		return nil
+	}
+	for _, san := range c.SANs() {
+		if strings.EqualFold(san, commonName) {
+			return nil
+		}
+	}
+	if ip := net.ParseIP(commonName); ip != nil && c.CoversName(commonName) {
+		return nil
+	}
+	return CommonNameNotInSANWarning{commonName: commonName}
+}
+
+type MissingServerAuthWarning struct {
+	noExtension bool
+}
+
+func (w MissingServerAuthWarning) ID() string {
+	return "missing-server-auth-eku"
+}
+
+// Severity is lower without the extension at all, since clients then
+// accept the certificate for any purpose.
+func (w MissingServerAuthWarning) Severity() Severity {
+	if w.noExtension {
+		return SeverityWarning
+	}
+	return SeverityError
+}
+
+func (w MissingServerAuthWarning) Title() string {
+	return "Missing serverAuth extended key usage."
+}
+
+func (w MissingServerAuthWarning) Description() string {
+	if w.noExtension {
+		return formatDescription(`
+This certificate has no Extended Key Usage extension. Clients accept it,
+but the CA/B Forum requires serverAuth to be listed explicitly.
+`)
+	}
+	return formatDescription(`
+This certificate's Extended Key Usage doesn't include serverAuth, so
+clients will reject it for TLS servers.
+`)
+}
+
+func TryMissingServerAuthWarning(c *Certificate) Warning {
+	cert := c.Certificate
+	if len(cert.ExtKeyUsage) == 0 && len(cert.UnknownExtKeyUsage) == 0 {
+		return MissingServerAuthWarning{noExtension: true}
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageServerAuth || usage == x509.ExtKeyUsageAny {
+			return nil
+		}
+	}
+	return MissingServerAuthWarning{}
+}
+
+type KeyUsageMismatchWarning struct {
+	problems []string
+}
+
+func (w KeyUsageMismatchWarning) ID() string {
+	return "key-usage-mismatch"
+}
+
+func (w KeyUsageMismatchWarning) Severity() Severity {
+	return SeverityWarning
+}
+
+func (w KeyUsageMismatchWarning) Title() string {
+	return "Key usage doesn't fit the key type."
+}
+
+func (w KeyUsageMismatchWarning) Description() string {
+	return formatDescription(`
+This certificate's Key Usage is wrong for a TLS server: %s.
+`, strings.Join(w.problems, "; "))
+}
+
+func TryKeyUsageMismatchWarning(c *Certificate) Warning {
+	usage := c.Certificate.KeyUsage
+	if usage == 0 {
+		return nil
+	}
+
+	problems := []string{}
+	switch c.Certificate.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if usage&(x509.KeyUsageDigitalSignature|x509.KeyUsageKeyEncipherment) == 0 {
+			problems = append(problems, "RSA keys need digitalSignature or keyEncipherment")
+		}
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		if usage&x509.KeyUsageDigitalSignature == 0 {
+			problems = append(problems, fmt.Sprintf(
+				"%s keys need digitalSignature", c.ReadablePublicKeyAlgorithm()))
+		}
+		if usage&x509.KeyUsageKeyEncipherment != 0 {
+			problems = append(problems, fmt.Sprintf(
+				"%s keys can't be used for keyEncipherment", c.ReadablePublicKeyAlgorithm()))
+		}
+	}
+	if usage&(x509.KeyUsageCertSign|x509.KeyUsageCRLSign) != 0 {
+		problems = append(problems, "keyCertSign and cRLSign are only for CAs")
+	}
+
+	if len(problems) > 0 {
+		return KeyUsageMismatchWarning{problems: problems}
+	}
+	return nil
+}
+
+func isReservedIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast()
+}
+
+func joinIPs(ips []net.IP) string {
+	rv := []string{}
+	for _, ip := range ips {
+		rv = append(rv, ip.String())
+	}
+	return strings.Join(rv, ", ")
+}
+
+type ReservedIPAddressWarning struct {
+	ips []net.IP
+}
+
+func (w ReservedIPAddressWarning) ID() string {
+	return "reserved-ip-in-san"
+}
+
+func (w ReservedIPAddressWarning) Severity() Severity {
+	return SeverityWarning
+}
+
+func (w ReservedIPAddressWarning) Title() string {
+	return "Reserved IP address in Subject Alternative Names."
+}
+
+func (w ReservedIPAddressWarning) Description() string {
+	return formatDescription(`
+This certificate is valid for private or reserved IP addresses (%s).
+Publicly trusted CAs may not issue for them, and anyone on another
+network can use the same addresses.
+`, joinIPs(w.ips))
+}
+
+func TryReservedIPAddressWarning(c *Certificate) Warning {
+	reserved := []net.IP{}
+	for _, ip := range c.Certificate.IPAddresses {
+		if isReservedIP(ip) {
+			reserved = append(reserved, ip)
+		}
+	}
+	if len(reserved) > 0 {
+		return ReservedIPAddressWarning{ips: reserved}
+	}
+	return nil
+}
+
+type IPAddressInSANWarning struct {
+	ips []net.IP
+}
+
+func (w IPAddressInSANWarning) ID() string {
+	return "ip-address-in-san"
+}
+
+func (w IPAddressInSANWarning) Severity() Severity {
+	return SeverityInfo
+}
+
+func (w IPAddressInSANWarning) Title() string {
+	return "IP address in Subject Alternative Names."
+}
+
+func (w IPAddressInSANWarning) Description() string {
+	return formatDescription(`
+This certificate is valid for IP addresses (%s). That's allowed, but the
+certificate must be replaced whenever the addresses change.
+`, joinIPs(w.ips))
+}
+
+func TryIPAddressInSANWarning(c *Certificate) Warning {
+	public := []net.IP{}
+	for _, ip := range c.Certificate.IPAddresses {
+		if !isReservedIP(ip) {
+			public = append(public, ip)
+		}
+	}
+	if len(public) > 0 {
+		return IPAddressInSANWarning{ips: public}
+	}
+	return nil
+}
+
+// internalTLDs are top-level domains reserved by RFC 2606, 6761 and 6762,
+// or commonly used on internal networks, that no public CA can issue for.
+var internalTLDs = map[string]bool{
+	"test":        true,
+	"example":     true,
+	"invalid":     true,
+	"localhost":   true,
+	"local":       true,
+	"localdomain": true,
+	"internal":    true,
+	"intranet":    true,
+	"private":     true,
+	"corp":        true,
+	"home":        true,
+	"lan":         true,
+}
+
+func isInternalName(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if strings.HasSuffix(name, ".home.arpa") {
+		return true
+	}
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 {
+		return true
+	}
+	return internalTLDs[name[dot+1:]]
+}
+
+type InternalNameWarning struct {
+	names []string
+}
+
+func (w InternalNameWarning) ID() string {
+	return "internal-name-in-san"
+}
+
+func (w InternalNameWarning) Severity() Severity {
+	return SeverityWarning
+}
+
+func (w InternalNameWarning) Title() string {
+	return "Internal name in Subject Alternative Names."
+}
+
+func (w InternalNameWarning) Description() string {
+	return formatDescription(`
+This certificate is valid for internal names (%s), which aren't unique
+on the internet. Publicly trusted CAs may not issue for them, so this
+certificate must come from a private CA.
+`, strings.Join(w.names, ", "))
+}
+
+func TryInternalNameWarning(c *Certificate) Warning {
+	internal := []string{}
+	for _, name := range c.Certificate.DNSNames {
+		if isInternalName(name) {
+			internal = append(internal, name)
+		}
+	}
+	if len(internal) > 0 {
+		return InternalNameWarning{names: internal}
+	}
+	return nil
+}
+
+// badWildcardReason tells why a DNS name is an invalid wildcard, or returns
+// "" for valid wildcards and names without wildcards.
+func badWildcardReason(name string) string {
+	if !strings.Contains(name, "*") {
+		return ""
+	}
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	if labels[0] != "*" {
+		return "the wildcard must be a whole label"
+	}
+	for _, label := range labels[1:] {
+		if strings.Contains(label, "*") {
+			return "only the leftmost label can be a wildcard"
+		}
+	}
+	if len(labels) < 3 {
+		return "the wildcard covers a whole top-level domain"
+	}
+	return ""
+}
+
+type BadWildcardWarning struct {
+	problems []string
+}
+
+func (w BadWildcardWarning) ID() string {
+	return "bad-wildcard"
+}
+
+func (w BadWildcardWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w BadWildcardWarning) Title() string {
+	return "Invalid wildcard names."
+}
+
+func (w BadWildcardWarning) Description() string {
+	return formatDescription(`
+Browsers won't match these wildcard names: %s.
+`, strings.Join(w.problems, "; "))
+}
+
+func TryBadWildcardWarning(c *Certificate) Warning {
+	problems := []string{}
+	for _, name := range c.Certificate.DNSNames {
+		if reason := badWildcardReason(name); reason != "" {
+			problems = append(problems, fmt.Sprintf("%s (%s)", name, reason))
+		}
+	}
+	if len(problems) > 0 {
+		return BadWildcardWarning{problems: problems}
+	}
+	return nil
+}
+
+type NonPositiveSerialWarning struct {
+	serial *big.Int
+}
+
+func (w NonPositiveSerialWarning) ID() string {
+	return "non-positive-serial"
+}
+
+func (w NonPositiveSerialWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w NonPositiveSerialWarning) Title() string {
+	return "Serial number isn't positive."
+}
+
+func (w NonPositiveSerialWarning) Description() string {
+	return formatDescription(`
+This certificate's serial number is %s, but RFC 5280 requires a positive
+integer. Some clients refuse to parse such certificates.
+`, w.serial)
+}
+
+func TryNonPositiveSerialWarning(c *Certificate) Warning {
+	if c.Certificate.SerialNumber.Sign() <= 0 {
+		return NonPositiveSerialWarning{serial: c.Certificate.SerialNumber}
+	}
+	return nil
+}
+
+// maxSerialOctets is the RFC 5280 limit on encoded serial number length.
+const maxSerialOctets = 20
+
+// serialOctets is the DER-encoded length of a positive serial number,
+// including the leading zero added when the high bit is set.
+func serialOctets(serial *big.Int) int {
+	octets := len(serial.Bytes())
+	if serial.BitLen()%8 == 0 {
+		octets++
+	}
+	return octets
+}
+
+type SerialTooLongWarning struct {
+	octets int
+}
+
+func (w SerialTooLongWarning) ID() string {
+	return "serial-too-long"
+}
+
+func (w SerialTooLongWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w SerialTooLongWarning) Title() string {
+	return "Serial number is too long."
+}
+
+func (w SerialTooLongWarning) Description() string {
+	return formatDescription(`
+This certificate's serial number takes %d octets, more than the %d
+RFC 5280 allows. Some clients refuse to parse such certificates.
+`, w.octets, maxSerialOctets)
+}
+
+func TrySerialTooLongWarning(c *Certificate) Warning {
+	serial := c.Certificate.SerialNumber
+	if serial.Sign() <= 0 {
+		return nil
+	}
+	if octets := serialOctets(serial); octets > maxSerialOctets {
+		return SerialTooLongWarning{octets: octets}
+	}
+	return nil
+}
+
+type LeafIsCAWarning struct{}
+
+func (w LeafIsCAWarning) ID() string {
+	return "leaf-is-ca"
+}
+
+func (w LeafIsCAWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w LeafIsCAWarning) Title() string {
+	return "Leaf certificate is a CA."
+}
+
+func (w LeafIsCAWarning) Description() string {
+	return formatDescription(`
+This certificate has the CA flag set in its Basic Constraints, so it
+could be used to issue other certificates. Server certificates must not
+be CAs, and some clients reject them.
+`)
+}
+
+func TryLeafIsCAWarning(c *Certificate) Warning {
+	if c.Certificate.BasicConstraintsValid && c.Certificate.IsCA {
+		return LeafIsCAWarning{}
+	}
+	return nil
+}
+
+type WeakCurveWarning struct {
+	curve string
+}
+
+func (w WeakCurveWarning) ID() string {
+	return "weak-ecdsa-curve"
+}
+
+func (w WeakCurveWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w WeakCurveWarning) Title() string {
+	return "Weak ECDSA curve."
+}
+
+func (w WeakCurveWarning) Description() string {
+	return formatDescription(`
+This key uses the %s curve, which is weaker than the 128-bit security
+level the CA/B Forum requires. Use P-256 or P-384 instead.
+`, w.curve)
+}
+
+func TryWeakCurveWarning(c *Certificate) Warning {
+	return tryWeakCurveWarning(c.Certificate.PublicKey)
+}
+
+func tryWeakCurveWarning(publicKey crypto.PublicKey) Warning {
+	ecdsaPubKey, ok := publicKey.(*ecdsa.PublicKey)
+	if ok && ecdsaPubKey.Curve.Params().BitSize < 256 {
+		return WeakCurveWarning{curve: ecdsaPubKey.Curve.Params().Name}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMaxLeafValidityDays(t *testing.T) {
+	tests := []struct {
+		notBefore time.Time
+		want      int
+	}{
+		{time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC), 1185},
+		{time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), 825},
+		{time.Date(2020, 8, 31, 23, 59, 59, 0, time.UTC), 825},
+		{time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC), 398},
+		{time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC), 200},
+		{time.Date(2027, 3, 15, 0, 0, 0, 0, time.UTC), 100},
+		{time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), 47},
+	}
+	for _, test := range tests {
+		if got := maxLeafValidityDays(test.notBefore); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.notBefore, got, test.want)
+		}
+	}
+}
+
+func TestIsInternalName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"www.example.com", false},
+		{"www.example.com.", false},
+		{"www.example.test", true},
+		{"printer.LOCAL", true},
+		{"router.home.arpa", true},
+		{"intranet", true},
+		{"db.corp", true},
+		{"corp.example.com", false},
+	}
+	for _, test := range tests {
+		if got := isInternalName(test.name); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestBadWildcardReason(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"www.example.com", ""},
+		{"*.example.com", ""},
+		{"*.example.com.", ""},
+		{"w*.example.com", "the wildcard must be a whole label"},
+		{"www.*.example.com", "the wildcard must be a whole label"},
+		{"*.*.example.com", "only the leftmost label can be a wildcard"},
+		{"*.com", "the wildcard covers a whole top-level domain"},
+	}
+	for _, test := range tests {
+		if got := badWildcardReason(test.name); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSerialOctets(t *testing.T) {
+	tests := []struct {
+		serial *big.Int
+		want   int
+	}{
+		{big.NewInt(1), 1},
+		{big.NewInt(0x7f), 1},
+		{big.NewInt(0x80), 2},
+		{new(big.Int).Lsh(big.NewInt(1), 158), 20},
+		{new(big.Int).Lsh(big.NewInt(1), 159), 21},
+	}
+	for _, test := range tests {
+		if got := serialOctets(test.serial); got != test.want {
+			t.Errorf("%x: got %d, want %d", test.serial, got, test.want)
+		}
+	}
+}
+
+func TestLeafLints(t *testing.T) {
+	key := loadKeyFixture(t, "ec.pem")
+	ecKey, err := publicKeyFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	leaf := func(mutate func(cert *x509.Certificate)) *Certificate {
+		cert := &x509.Certificate{
+			Subject:      pkix.Name{CommonName: "www.example.com"},
+			DNSNames:     []string{"www.example.com"},
+			SerialNumber: big.NewInt(1),
+			NotBefore:    now,
+			NotAfter:     now.AddDate(0, 0, 40),
+			PublicKey:    ecKey,
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		if mutate != nil {
+			mutate(cert)
+		}
+		return &Certificate{Certificate: cert}
+	}
+
+	tests := []struct {
+		name   string
+		trier  func(*Certificate) Warning
+		mutate func(cert *x509.Certificate)
+		want   string
+	}{
+		{"validity ok", TryValidityTooLongWarning, nil, ""},
+		{"validity too long", TryValidityTooLongWarning, func(cert *x509.Certificate) {
+			cert.NotAfter = cert.NotBefore.AddDate(2, 0, 0)
+		}, "validity-too-long"},
+		{"CN in SANs", TryCommonNameNotInSANWarning, nil, ""},
+		{"CN in SANs, other case", TryCommonNameNotInSANWarning, func(cert *x509.Certificate) {
+			cert.Subject.CommonName = "WWW.example.com"
+		}, ""},
+		{"CN not in SANs", TryCommonNameNotInSANWarning, func(cert *x509.Certificate) {
+			cert.Subject.CommonName = "example.com"
+		}, "cn-not-in-san"},
+		{"server auth", TryMissingServerAuthWarning, nil, ""},
+		{"no EKU", TryMissingServerAuthWarning, func(cert *x509.Certificate) {
+			cert.ExtKeyUsage = nil
+		}, "missing-server-auth-eku"},
+		{"client auth only", TryMissingServerAuthWarning, func(cert *x509.Certificate) {
+			cert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		}, "missing-server-auth-eku"},
+		{"ECDSA key usage", TryKeyUsageMismatchWarning, nil, ""},
+		{"ECDSA key encipherment", TryKeyUsageMismatchWarning, func(cert *x509.Certificate) {
+			cert.KeyUsage |= x509.KeyUsageKeyEncipherment
+		}, "key-usage-mismatch"},
+		{"cert sign on a leaf", TryKeyUsageMismatchWarning, func(cert *x509.Certificate) {
+			cert.KeyUsage |= x509.KeyUsageCertSign
+		}, "key-usage-mismatch"},
+		{"public IP", TryReservedIPAddressWarning, func(cert *x509.Certificate) {
+			cert.IPAddresses = []net.IP{net.ParseIP("203.0.113.10")}
+		}, ""},
+		{"private IP", TryReservedIPAddressWarning, func(cert *x509.Certificate) {
+			cert.IPAddresses = []net.IP{net.ParseIP("10.0.0.1")}
+		}, "reserved-ip-in-san"},
+		{"IP in SANs", TryIPAddressInSANWarning, func(cert *x509.Certificate) {
+			cert.IPAddresses = []net.IP{net.ParseIP("203.0.113.10")}
+		}, "ip-address-in-san"},
+		{"internal name", TryInternalNameWarning, func(cert *x509.Certificate) {
+			cert.DNSNames = append(cert.DNSNames, "db.internal")
+		}, "internal-name-in-san"},
+		{"bad wildcard", TryBadWildcardWarning, func(cert *x509.Certificate) {
+			cert.DNSNames = append(cert.DNSNames, "*.com")
+		}, "bad-wildcard"},
+		{"zero serial", TryNonPositiveSerialWarning, func(cert *x509.Certificate) {
+			cert.SerialNumber = big.NewInt(0)
+		}, "non-positive-serial"},
+		{"long serial", TrySerialTooLongWarning, func(cert *x509.Certificate) {
+			cert.SerialNumber = new(big.Int).Lsh(big.NewInt(1), 160)
+		}, "serial-too-long"},
+		{"not a CA", TryLeafIsCAWarning, nil, ""},
+		{"CA leaf", TryLeafIsCAWarning, func(cert *x509.Certificate) {
+			cert.BasicConstraintsValid = true
+			cert.IsCA = true
+		}, "leaf-is-ca"},
+	}
+	for _, test := range tests {
+		got := ""
+		if w := test.trier(leaf(test.mutate)); w != nil {
+			got = w.ID()
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package core
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+// golang.org/x/crypto/pkcs12 is frozen and only reads the legacy algorithms
+// (3DES/RC2 with a SHA-1 MAC) that OpenSSL 3 no longer uses by default. It's
+// still tried first, since it handles RC2; archives it rejects are decoded
+// here, which adds PBES2 encryption, SHA-2 MACs and archives without a MAC.
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+
+	oidKeyBag                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509CertificateBagValue = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+
+	oidPBEWithSHAAnd3KeyTripleDESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe pkcs12ContentInfo
+	MacData  pkcs12MacData `asn1:"optional"`
+}
+
+type pkcs12ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type pkcs12MacData struct {
+	Mac struct {
+		Algorithm pkix.AlgorithmIdentifier
+		Digest    []byte
+	}
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type pkcs12EncryptedData struct {
+	Version              int
+	EncryptedContentInfo struct {
+		ContentType                asn1.ObjectIdentifier
+		ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+		EncryptedContent           []byte `asn1:"tag:0,optional"`
+	}
+}
+
+type pkcs12SafeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue   `asn1:"tag:0,explicit"`
+	Attributes []asn1.RawValue `asn1:"set,optional"`
+}
+
+type pkcs12CertBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type pkcs12PBEParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+// pkcs12ToPEM decodes a PKCS#12 archive into certificate and private key PEM
+// blocks. A wrong passphrase gives errIncorrectPassphrase.
+func pkcs12ToPEM(data []byte, passphrase string) ([]*pem.Block, error) {
+	blocks, err := pkcs12.ToPEM(data, passphrase)
+	switch {
+	case err == nil:
+		return blocks, nil
+	case err == pkcs12.ErrIncorrectPassword:
+		return nil, errIncorrectPassphrase
+	default:
+		return decodePKCS12(data, passphrase)
+	}
+}
+
+func decodePKCS12(data []byte, passphrase string) ([]*pem.Block, error) {
+	pfx := pfxPdu{}
+	if rest, err := asn1.Unmarshal(data, &pfx); err != nil {
+		return nil, err
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("Trailing data after PKCS#12 archive")
+	}
+	if pfx.Version != 3 {
+		return nil, fmt.Errorf("Unsupported PKCS#12 version %d", pfx.Version)
+	}
+	if !pfx.AuthSafe.ContentType.Equal(oidDataContentType) {
+		return nil, fmt.Errorf("Unsupported PKCS#12 content type %s (only password integrity is supported)",
+			pfx.AuthSafe.ContentType)
+	}
+
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, fmt.Errorf("Invalid PKCS#12 content: %s", err)
+	}
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) > 0 {
+		if err := verifyPKCS12MAC(&pfx.MacData, authSafe, passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	contents := []pkcs12ContentInfo{}
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return nil, fmt.Errorf("Invalid PKCS#12 content: %s", err)
+	}
+
+	blocks := []*pem.Block{}
+	for _, content := range contents {
+		var safeContents []byte
+		switch {
+		case content.ContentType.Equal(oidDataContentType):
+			if _, err := asn1.Unmarshal(content.Content.Bytes, &safeContents); err != nil {
+				return nil, fmt.Errorf("Invalid PKCS#12 safe contents: %s", err)
+			}
+		case content.ContentType.Equal(oidEncryptedDataContentType):
+			encrypted := pkcs12EncryptedData{}
+			if _, err := asn1.Unmarshal(content.Content.Bytes, &encrypted); err != nil {
+				return nil, fmt.Errorf("Invalid PKCS#12 encrypted data: %s", err)
+			}
+			info := encrypted.EncryptedContentInfo
+			var err error
+			safeContents, err = decryptPKCS12(info.ContentEncryptionAlgorithm, info.EncryptedContent, passphrase)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("Unsupported PKCS#12 content type %s", content.ContentType)
+		}
+
+		bags := []pkcs12SafeBag{}
+		if _, err := asn1.Unmarshal(safeContents, &bags); err != nil {
+			return nil, fmt.Errorf("Invalid PKCS#12 safe contents: %s", err)
+		}
+		for _, bag := range bags {
+			block, err := pkcs12BagToPEM(&bag, passphrase)
+			if err != nil {
+				return nil, err
+			}
+			if block != nil {
+				blocks = append(blocks, block)
+			}
+		}
+	}
+	return blocks, nil
+}
+
+// pkcs12BagToPEM returns nil for bags holding neither a certificate nor a
+// private key, such as CRL and secret bags.
+func pkcs12BagToPEM(bag *pkcs12SafeBag, passphrase string) (*pem.Block, error) {
+	switch {
+	case bag.ID.Equal(oidCertBag):
+		certBag := pkcs12CertBag{}
+		if _, err := asn1.Unmarshal(bag.Value.Bytes, &certBag); err != nil {
+			return nil, fmt.Errorf("Invalid PKCS#12 certificate bag: %s", err)
+		}
+		if !certBag.ID.Equal(oidX509CertificateBagValue) {
+			return nil, nil
+		}
+		return &pem.Block{Type: "CERTIFICATE", Bytes: certBag.Data}, nil
+	case bag.ID.Equal(oidKeyBag):
+		return &pem.Block{Type: "PRIVATE KEY", Bytes: bag.Value.Bytes}, nil
+	case bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+		info, err := parseEncryptedPKCS8(bag.Value.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid PKCS#12 key bag: %s", err)
+		}
+		key, err := decryptPKCS12(info.EncryptionAlgorithm, info.EncryptedData, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return &pem.Block{Type: "PRIVATE KEY", Bytes: key}, nil
+	}
+	return nil, nil
+}
+
+func decryptPKCS12(algorithm pkix.AlgorithmIdentifier, data []byte, passphrase string) ([]byte, error) {
+	switch {
+	case algorithm.Algorithm.Equal(oidPBES2):
+		// PBES2 takes the passphrase as is, not as a BMPString.
+		return decryptPKCS8(&encryptedPrivateKeyInfo{
+			EncryptionAlgorithm: algorithm,
+			EncryptedData:       data,
+		}, []byte(passphrase))
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd3KeyTripleDESCBC):
+		params := pkcs12PBEParams{}
+		if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("Invalid PKCS#12 PBE parameters: %s", err)
+		}
+		if params.Iterations < 1 || params.Iterations > maxPBKDF2Iterations {
+			return nil, fmt.Errorf("PKCS#12 iteration count %d is outside the supported range (1 to %d)",
+				params.Iterations, maxPBKDF2Iterations)
+		}
+		password := bmpPassphrase(passphrase)
+		key := pkcs12KDF(sha1.New, 1, password, params.Salt, params.Iterations, 24)
+		iv := pkcs12KDF(sha1.New, 2, password, params.Salt, params.Iterations, 8)
+		block, err := des.NewTripleDESCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+			return nil, fmt.Errorf("Encrypted data isn't a multiple of the cipher block size")
+		}
+		decrypted := make([]byte, len(data))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, data)
+		return unpadPKCS7(decrypted, block.BlockSize())
+	default:
+		return nil, fmt.Errorf("Unsupported PKCS#12 encryption algorithm %s", algorithm.Algorithm)
+	}
+}
+
+func verifyPKCS12MAC(macData *pkcs12MacData, content []byte, passphrase string) error {
+	var newHash func() hash.Hash
+	switch algorithm := macData.Mac.Algorithm.Algorithm; {
+	case algorithm.Equal(oidSHA1):
+		newHash = sha1.New
+	case algorithm.Equal(oidSHA256):
+		newHash = sha256.New
+	case algorithm.Equal(oidSHA384):
+		newHash = sha512.New384
+	case algorithm.Equal(oidSHA512):
+		newHash = sha512.New
+	default:
+		return fmt.Errorf("Unsupported PKCS#12 MAC algorithm %s", algorithm)
+	}
+	if macData.Iterations < 1 || macData.Iterations > maxPBKDF2Iterations {
+		return fmt.Errorf("PKCS#12 MAC iteration count %d is outside the supported range (1 to %d)",
+			macData.Iterations, maxPBKDF2Iterations)
+	}
+
+	key := pkcs12KDF(newHash, 3, bmpPassphrase(passphrase), macData.MacSalt,
+		macData.Iterations, newHash().Size())
+	mac := hmac.New(newHash, key)
+	mac.Write(content)
+	if !hmac.Equal(mac.Sum(nil), macData.Mac.Digest) {
+		return errIncorrectPassphrase
+	}
+	return nil
+}
+
+// bmpPassphrase encodes a passphrase as the null-terminated BMPString the
+// PKCS#12 key derivation takes.
+func bmpPassphrase(passphrase string) []byte {
+	rv := []byte{}
+	for _, unit := range utf16.Encode([]rune(passphrase)) {
+		rv = append(rv, byte(unit>>8), byte(unit))
+	}
+	return append(rv, 0, 0)
+}
+
+// pkcs12KDF derives key material as in RFC 7292, appendix B.2. The id picks
+// what it's for: 1 for keys, 2 for IVs and 3 for MAC keys.
+func pkcs12KDF(newHash func() hash.Hash, id byte, password, salt []byte, iterations, size int) []byte {
+	h := newHash()
+	v := h.BlockSize()
+
+	fill := func(data []byte) []byte {
+		if len(data) == 0 {
+			return nil
+		}
+		rv := make([]byte, v*((len(data)+v-1)/v))
+		for index := range rv {
+			rv[index] = data[index%len(data)]
+		}
+		return rv
+	}
+
+	diversifier := bytes.Repeat([]byte{id}, v)
+	input := append(fill(salt), fill(password)...)
+
+	rv := []byte{}
+	for {
+		h.Reset()
+		h.Write(diversifier)
+		h.Write(input)
+		digest := h.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			h.Reset()
+			h.Write(digest)
+			digest = h.Sum(digest[:0])
+		}
+
+		rv = append(rv, digest...)
+		if len(rv) >= size {
+			return rv[:size]
+		}
+
+		// Add digest+1, repeated to v bytes, to each v-byte block of the
+		// input.
+		b := fill(digest)
+		for start := 0; start < len(input); start += v {
+			carry := 1
+			for index := v - 1; index >= 0; index-- {
+				sum := int(input[start+index]) + int(b[index]) + carry
+				input[start+index] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+}
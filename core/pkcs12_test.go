@@ -0,0 +1,103 @@
+package core
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// The archives in testdata/pkcs12 hold leaf.crt, leaf.key and the
+// intermediate and root certificates, exported by OpenSSL 3 with the
+// passphrase "chaintool":
+//
+//	openssl pkcs12 -export -in leaf.crt -inkey leaf.key -certfile chain.pem
+//	openssl pkcs12 -export -legacy ...
+//	openssl pkcs12 -export -nomac ...
+//
+// unrelated.p12 has unrelated.crt instead of the root.
+const testPKCS12Passphrase = "chaintool"
+
+func loadCertificateFixture(t *testing.T, path string) *Certificate {
+	t.Helper()
+	cert := &Certificate{}
+	if err := cert.LoadCertificateFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestPKCS12ToPEM(t *testing.T) {
+	leaf := loadCertificateFixture(t, filepath.Join("testdata", "pkcs12", "leaf.crt"))
+
+	tests := []struct {
+		file       string
+		passphrase string
+		err        error
+	}{
+		{file: "default.p12", passphrase: testPKCS12Passphrase},
+		{file: "legacy.p12", passphrase: testPKCS12Passphrase},
+		{file: "nomac.p12", passphrase: testPKCS12Passphrase},
+		{file: "default.p12", passphrase: "wrong", err: errIncorrectPassphrase},
+		{file: "legacy.p12", passphrase: "wrong", err: errIncorrectPassphrase},
+		{file: "nomac.p12", passphrase: "wrong", err: errIncorrectPassphrase},
+		{file: "default.p12", passphrase: "", err: errIncorrectPassphrase},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file+"/"+test.passphrase, func(t *testing.T) {
+			data, err := ioutil.ReadFile(filepath.Join("testdata", "pkcs12", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			blocks, err := pkcs12ToPEM(data, test.passphrase)
+			if test.err != nil {
+				if err != test.err {
+					t.Fatalf("got error %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			certs, keys := 0, 0
+			for _, block := range blocks {
+				switch block.Type {
+				case "CERTIFICATE":
+					certs++
+				default:
+					keys++
+					key, err := parsePrivateKey(pem.EncodeToMemory(block), test.file)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if err := ensurePrivateKeyMatches(leaf.Certificate.PublicKey, key); err != nil {
+						t.Fatalf("key doesn't match leaf.crt: %s", err)
+					}
+				}
+			}
+			if certs != 3 || keys != 1 {
+				t.Fatalf("got %d certificates and %d keys, want 3 and 1", certs, keys)
+			}
+		})
+	}
+}
+
+func TestLoadFileContentsPKCS12(t *testing.T) {
+	defer func(source PassphraseSource) { KeyPassphraseSource = source }(KeyPassphraseSource)
+	KeyPassphraseSource = func(string) ([]byte, error) {
+		return []byte(testPKCS12Passphrase), nil
+	}
+
+	for _, file := range []string{"default.p12", "legacy.p12", "nomac.p12"} {
+		contents, err := LoadFileContents(filepath.Join("testdata", "pkcs12", file))
+		if err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+		if contents.Format != "PKCS#12" || len(contents.Certificates) != 3 || len(contents.PrivateKeys) != 1 {
+			t.Errorf("%s: got %s", file, contents.Summary())
+		}
+	}
+}
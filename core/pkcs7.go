@@ -0,0 +1,50 @@
+package core
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+)
+
+var oidPKCS7SignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+// parsePKCS7Certificates extracts the certificates bundled in a PKCS#7
+// SignedData structure, which is how .p7b/.p7c files are usually shipped by
+// CAs. Signatures and CRLs are ignored.
+func parsePKCS7Certificates(der []byte) ([]*x509.Certificate, error) {
+	contentInfo := pkcs7ContentInfo{}
+	rest, err := asn1.Unmarshal(der, &contentInfo)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("Trailing data after PKCS#7 structure")
+	}
+	if !contentInfo.ContentType.Equal(oidPKCS7SignedData) {
+		return nil, fmt.Errorf("Unsupported PKCS#7 content type %s", contentInfo.ContentType)
+	}
+
+	signedData := pkcs7SignedData{}
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return nil, fmt.Errorf("Invalid PKCS#7 SignedData: %s", err)
+	}
+
+	if len(signedData.Certificates.Bytes) == 0 {
+		return nil, nil
+	}
+	return x509.ParseCertificates(signedData.Certificates.Bytes)
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"encoding/asn1"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The bundles in testdata/pkcs7 hold the certificates from testdata/pkcs12,
+// in leaf, intermediate, root order:
+//
+//	openssl crl2pkcs7 -nocrl -certfile leaf.crt -certfile intermediate.crt \
+//	    -certfile root.crt -outform DER -out chain.p7b
+//	openssl crl2pkcs7 ... -outform PEM -out chain.p7c
+//	openssl crl2pkcs7 -nocrl -outform DER -out empty.p7b
+//
+// empty.p7b is a degenerate SignedData without any certificates.
+
+func TestParsePKCS7Certificates(t *testing.T) {
+	want := []*Certificate{}
+	for _, name := range []string{"leaf.crt", "intermediate.crt", "root.crt"} {
+		want = append(want, loadCertificateFixture(t, filepath.Join("testdata", "pkcs12", name)))
+	}
+
+	der, err := ioutil.ReadFile(filepath.Join("testdata", "pkcs7", "chain.p7b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "pkcs7", "chain.p7c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PKCS7" {
+		t.Fatalf("chain.p7c: got block %v, want PKCS7", block)
+	}
+
+	for name, der := range map[string][]byte{"chain.p7b": der, "chain.p7c": block.Bytes} {
+		certs, err := parsePKCS7Certificates(der)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if len(certs) != len(want) {
+			t.Fatalf("%s: got %d certificates, want %d", name, len(certs), len(want))
+		}
+		for index, cert := range certs {
+			if !cert.Equal(want[index].Certificate) {
+				t.Errorf("%s: certificate #%d is %s, want %s",
+					name, index+1, cert.Subject, want[index].Certificate.Subject)
+			}
+		}
+	}
+
+	empty, err := ioutil.ReadFile(filepath.Join("testdata", "pkcs7", "empty.p7b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certs, err := parsePKCS7Certificates(empty); err != nil || len(certs) != 0 {
+		t.Errorf("empty.p7b: got %d certificates and error %v, want none", len(certs), err)
+	}
+
+	data, err = asn1.Marshal(pkcs7ContentInfo{ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errorTests := []struct {
+		name string
+		der  []byte
+		err  string
+	}{
+		{"trailing data", append(append([]byte{}, der...), 0), "Trailing data"},
+		{"not SignedData", data, "Unsupported PKCS#7 content type 1.2.840.113549.1.7.1"},
+		{"not PKCS#7", want[0].Certificate.Raw, "asn1"},
+	}
+	for _, test := range errorTests {
+		if _, err := parsePKCS7Certificates(test.der); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestLoadFileContentsPKCS7(t *testing.T) {
+	tests := []struct {
+		file   string
+		format string
+		certs  int
+	}{
+		{"chain.p7b", "PKCS#7", 3},
+		{"chain.p7c", "PEM", 3},
+		{"empty.p7b", "PKCS#7", 0},
+	}
+	for _, test := range tests {
+		contents, err := LoadFileContents(filepath.Join("testdata", "pkcs7", test.file))
+		if err != nil {
+			t.Fatalf("%s: %s", test.file, err)
+		}
+		if contents.Format != test.format || len(contents.Certificates) != test.certs {
+			t.Errorf("%s: got %s, want %s with %d certificates", test.file, contents.Summary(), test.format, test.certs)
+		}
+	}
+}
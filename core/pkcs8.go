@@ -0,0 +1,178 @@
+package core
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	oidPBES2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA224 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 8}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+var errIncorrectPassphrase = errors.New("Incorrect passphrase")
+
+// maxPBKDF2Iterations bounds the work a key file can ask for. Tools use at
+// most a few hundred thousand iterations; a crafted file with billions would
+// hang key loading.
+const maxPBKDF2Iterations = 10000000
+
+type encryptedPrivateKeyInfo struct {
+	EncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+func parseEncryptedPKCS8(der []byte) (*encryptedPrivateKeyInfo, error) {
+	info := &encryptedPrivateKeyInfo{}
+	rest, err := asn1.Unmarshal(der, info)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("Trailing data after encrypted key")
+	}
+	return info, nil
+}
+
+func decryptPKCS8(info *encryptedPrivateKeyInfo, passphrase []byte) ([]byte, error) {
+	if !info.EncryptionAlgorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf(
+			"Unsupported encryption scheme %s (only PBES2 is supported)",
+			info.EncryptionAlgorithm.Algorithm)
+	}
+
+	params := pbes2Params{}
+	if _, err := asn1.Unmarshal(info.EncryptionAlgorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("Invalid PBES2 parameters: %s", err)
+	}
+
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf(
+			"Unsupported key derivation function %s (only PBKDF2 is supported)",
+			params.KeyDerivationFunc.Algorithm)
+	}
+	kdfParams := pbkdf2Params{}
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, fmt.Errorf("Invalid PBKDF2 parameters: %s", err)
+	}
+
+	if kdfParams.IterationCount < 1 || kdfParams.IterationCount > maxPBKDF2Iterations {
+		return nil, fmt.Errorf(
+			"PBKDF2 iteration count %d is outside the supported range (1 to %d)",
+			kdfParams.IterationCount, maxPBKDF2Iterations)
+	}
+
+	prf, err := pbkdf2PRF(kdfParams.PRF.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyLength int
+	var newCipher func([]byte) (cipher.Block, error)
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLength, newCipher = 16, aes.NewCipher
+	case scheme.Equal(oidAES192CBC):
+		keyLength, newCipher = 24, aes.NewCipher
+	case scheme.Equal(oidAES256CBC):
+		keyLength, newCipher = 32, aes.NewCipher
+	case scheme.Equal(oidDESEDE3CBC):
+		keyLength, newCipher = 24, des.NewTripleDESCipher
+	default:
+		return nil, fmt.Errorf("Unsupported cipher %s", scheme)
+	}
+	if kdfParams.KeyLength != 0 && kdfParams.KeyLength != keyLength {
+		return nil, fmt.Errorf(
+			"Key length %d doesn't match cipher key length %d", kdfParams.KeyLength, keyLength)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("Invalid cipher IV: %s", err)
+	}
+
+	key := pbkdf2.Key(passphrase, kdfParams.Salt, kdfParams.IterationCount, keyLength, prf)
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, fmt.Errorf("Cipher IV has length %d, expected %d", len(iv), block.BlockSize())
+	}
+
+	data := info.EncryptedData
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("Encrypted data isn't a multiple of the cipher block size")
+	}
+
+	decrypted := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, data)
+
+	return unpadPKCS7(decrypted, block.BlockSize())
+}
+
+func pbkdf2PRF(oid asn1.ObjectIdentifier) (func() hash.Hash, error) {
+	switch {
+	case len(oid) == 0, oid.Equal(oidHMACWithSHA1):
+		return sha1.New, nil
+	case oid.Equal(oidHMACWithSHA224):
+		return sha256.New224, nil
+	case oid.Equal(oidHMACWithSHA256):
+		return sha256.New, nil
+	case oid.Equal(oidHMACWithSHA384):
+		return sha512.New384, nil
+	case oid.Equal(oidHMACWithSHA512):
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("Unsupported PBKDF2 PRF %s", oid)
+	}
+}
+
+// A wrong passphrase yields garbage, which almost always shows up as
+// invalid padding.
+func unpadPKCS7(data []byte, blockSize int) ([]byte, error) {
+	padLength := int(data[len(data)-1])
+	if padLength == 0 || padLength > blockSize || padLength > len(data) {
+		return nil, errIncorrectPassphrase
+	}
+	for _, b := range data[len(data)-padLength:] {
+		if int(b) != padLength {
+			return nil, errIncorrectPassphrase
+		}
+	}
+	return data[:len(data)-padLength], nil
+}
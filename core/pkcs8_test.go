@@ -0,0 +1,121 @@
+package core
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The keys in testdata/pkcs8 are the same P-256 key (key.pem), encrypted
+// by OpenSSL 3 with the passphrase "chaintool":
+//
+//	openssl pkcs8 -topk8 -in key.pem -v2 aes-256-cbc -v2prf hmacWithSHA256
+//	openssl pkcs8 -topk8 -in key.pem -v2 aes-128-cbc -v2prf hmacWithSHA1
+//	openssl pkcs8 -topk8 -in key.pem -v2 des3
+//	openssl pkcs8 -topk8 -in key.pem -v1 PBE-MD5-DES -provider legacy -provider default
+const testPKCS8Passphrase = "chaintool"
+
+func readPEMFixture(t *testing.T, path string) *pem.Block {
+	t.Helper()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("%s: no PEM block", path)
+	}
+	return block
+}
+
+func TestDecryptPKCS8(t *testing.T) {
+	plain := readPEMFixture(t, filepath.Join("testdata", "pkcs8", "key.pem"))
+
+	tests := []struct {
+		file       string
+		passphrase string
+		err        string
+	}{
+		{file: "aes256-sha256.pem", passphrase: testPKCS8Passphrase},
+		{file: "aes128-sha1.pem", passphrase: testPKCS8Passphrase},
+		{file: "des3.pem", passphrase: testPKCS8Passphrase},
+		{file: "aes256-sha256.pem", passphrase: "wrong", err: errIncorrectPassphrase.Error()},
+		{file: "des3.pem", passphrase: "wrong", err: errIncorrectPassphrase.Error()},
+		{file: "pbes1.pem", passphrase: testPKCS8Passphrase, err: "Unsupported encryption scheme"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file+"/"+test.passphrase, func(t *testing.T) {
+			block := readPEMFixture(t, filepath.Join("testdata", "pkcs8", test.file))
+			info, err := parseEncryptedPKCS8(block.Bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decrypted, err := decryptPKCS8(info, []byte(test.passphrase))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decrypted, plain.Bytes) {
+				t.Fatalf("decrypted key doesn't match key.pem")
+			}
+		})
+	}
+}
+
+func TestDecryptPKCS8IterationLimit(t *testing.T) {
+	block := readPEMFixture(t, filepath.Join("testdata", "pkcs8", "aes256-sha256.pem"))
+	info, err := parseEncryptedPKCS8(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, iterations := range []int{0, maxPBKDF2Iterations + 1} {
+		params := pbes2Params{}
+		if _, err := asn1.Unmarshal(info.EncryptionAlgorithm.Parameters.FullBytes, &params); err != nil {
+			t.Fatal(err)
+		}
+		kdfParams := pbkdf2Params{}
+		if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+			t.Fatal(err)
+		}
+		kdfParams.IterationCount = iterations
+		params.KeyDerivationFunc.Parameters = mustMarshalRaw(t, kdfParams)
+		tampered := &encryptedPrivateKeyInfo{
+			EncryptionAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  oidPBES2,
+				Parameters: mustMarshalRaw(t, params),
+			},
+			EncryptedData: info.EncryptedData,
+		}
+
+		_, err := decryptPKCS8(tampered, []byte(testPKCS8Passphrase))
+		if err == nil || !strings.Contains(err.Error(), "outside the supported range") {
+			t.Errorf("%d iterations: got error %v, want a range error", iterations, err)
+		}
+	}
+}
+
+func mustMarshalRaw(t *testing.T, value interface{}) asn1.RawValue {
+	t.Helper()
+	der, err := asn1.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := asn1.RawValue{}
+	if _, err := asn1.Unmarshal(der, &raw); err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
@@ -0,0 +1,277 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AuditTarget is one chain to include in a report. Hostname is checked during
+// verification when not empty, as for servers. Err is set instead of Chain
+// when the chain couldn't be loaded, and the target is reported as failed.
+type AuditTarget struct {
+	Source   string
+	Hostname string
+	Chain    *CertificateChain
+	Err      error
+}
+
+// Report is a certificate audit over many chains: verification outcomes per
+// chain, and details, warnings and expiry for every distinct certificate.
+type Report struct {
+	GeneratedAt  time.Time
+	Summary      ReportSummary
+	Chains       []*ReportChain
+	Certificates []*ReportCertificate
+	Timeline     []*ReportMonth
+	Warnings     []*ReportWarningGroup
+}
+
+type ReportSummary struct {
+	Chains       int
+	Passed       int
+	Failed       int
+	Certificates int
+	Expired      int
+	ExpiringSoon int
+	Errors       int
+	Warnings     int
+	Infos        int
+}
+
+// ReportChain is the verification outcome of a chain. Reason is a one-line
+// version of Error.
+type ReportChain struct {
+	Source       string
+	Passed       bool
+	Reason       string
+	Error        string
+	Leaf         *ReportCertificate
+	Certificates []*ReportCertificate
+}
+
+// ReportCertificate is a distinct certificate, which may appear in several
+// chains. Its position-dependent warnings come from the first one.
+type ReportCertificate struct {
+	ID           string
+	Subject      string
+	Position     string
+	Sources      []string
+	NotAfter     time.Time
+	DaysToExpire float64
+	Info         []string
+	Warnings     []Warning
+}
+
+// ReportMonth groups certificates by the month they expire in. Expired
+// certificates all go in a single "expired" group.
+type ReportMonth struct {
+	Month        string
+	Certificates []*ReportCertificate
+}
+
+type ReportWarningGroup struct {
+	ID           string
+	Title        string
+	Severity     Severity
+	Certificates []*ReportCertificate
+}
+
+// Anchor identifies the certificate's detail section.
+func (c *ReportCertificate) Anchor() string {
+	return "cert-" + c.ID[:16]
+}
+
+func (c *ReportCertificate) ShortID() string {
+	return c.ID[:8]
+}
+
+// Status is "expired", "expiring" (within the expiring-soon threshold) or
+// "valid".
+func (c *ReportCertificate) Status() string {
+	switch {
+	case c.DaysToExpire < 0:
+		return "expired"
+	case c.DaysToExpire < float64(ExpirationThreshold()):
+		return "expiring"
+	default:
+		return "valid"
+	}
+}
+
+// WorstSeverity is the severity of the certificate's most severe warning, or
+// "none".
+func (c *ReportCertificate) WorstSeverity() string {
+	if len(c.Warnings) == 0 {
+		return "none"
+	}
+	worst := SeverityInfo
+	for _, warning := range c.Warnings {
+		if warning.Severity() > worst {
+			worst = warning.Severity()
+		}
+	}
+	return worst.String()
+}
+
+// NewReport verifies every target and gathers the report data. The
+// certificate details are wrapped to wrapLength.
+func NewReport(targets []*AuditTarget, wrapLength int) *Report {
+	report := &Report{GeneratedAt: time.Now().UTC()}
+	certificates := map[string]*ReportCertificate{}
+
+	for _, target := range targets {
+		if target.Err != nil {
+			report.Chains = append(report.Chains, &ReportChain{
+				Source: target.Source,
+				Reason: verificationReason(target.Err),
+				Error:  strings.TrimSpace(target.Err.Error()),
+			})
+			continue
+		}
+
+		err := target.Chain.Verify(target.Hostname)
+		reportChain := &ReportChain{Source: target.Source, Passed: err == nil}
+		if err != nil {
+			reportChain.Reason = verificationReason(err)
+			reportChain.Error = strings.TrimSpace(err.Error())
+		}
+
+		for _, cert := range target.Chain.Certificates() {
+			reportCert, ok := certificates[cert.ID()]
+			if !ok {
+				reportCert = &ReportCertificate{
+					ID:           cert.ID(),
+					Subject:      cert.ReadableSubject(),
+					Position:     target.Chain.PositionName(cert),
+					NotAfter:     cert.Certificate.NotAfter,
+					DaysToExpire: cert.DaysToExpire(),
+					Info:         target.Chain.CertificateInfoLines(cert, wrapLength, true).Lines,
+					Warnings:     target.Chain.CertificateWarnings(cert),
+				}
+				certificates[cert.ID()] = reportCert
+				report.Certificates = append(report.Certificates, reportCert)
+			}
+			reportCert.addSource(target.Source)
+
+			if cert == target.Chain.Leaf {
+				reportChain.Leaf = reportCert
+			}
+			reportChain.Certificates = append(reportChain.Certificates, reportCert)
+		}
+
+		report.Chains = append(report.Chains, reportChain)
+	}
+
+	sort.SliceStable(report.Certificates, func(i, j int) bool {
+		return report.Certificates[i].NotAfter.Before(report.Certificates[j].NotAfter)
+	})
+
+	report.Timeline = reportTimeline(report.Certificates)
+	report.Warnings = reportWarningGroups(report.Certificates)
+	report.Summary = report.summary()
+	return report
+}
+
+func verificationReason(err error) string {
+	switch err := err.(type) {
+	case HostnameMismatchError:
+		return fmt.Sprintf("The certificate isn't valid for %s", err.Hostname)
+	case UnknownAuthorityError:
+		return "No chain to a trusted root: self-signed, or missing intermediates"
+	default:
+		return strings.SplitN(strings.TrimSpace(err.Error()), "\n", 2)[0]
+	}
+}
+
+func (c *ReportCertificate) addSource(source string) {
+	for _, existing := range c.Sources {
+		if existing == source {
+			return
+		}
+	}
+	c.Sources = append(c.Sources, source)
+}
+
+// reportTimeline expects certificates sorted by expiration.
+func reportTimeline(certificates []*ReportCertificate) []*ReportMonth {
+	months := []*ReportMonth{}
+	for _, cert := range certificates {
+		month := cert.NotAfter.UTC().Format("2006-01")
+		if cert.Status() == "expired" {
+			month = "expired"
+		}
+		if len(months) == 0 || months[len(months)-1].Month != month {
+			months = append(months, &ReportMonth{Month: month})
+		}
+		last := months[len(months)-1]
+		last.Certificates = append(last.Certificates, cert)
+	}
+	return months
+}
+
+// reportWarningGroups groups warnings by lint, most severe first.
+func reportWarningGroups(certificates []*ReportCertificate) []*ReportWarningGroup {
+	groups := map[string]*ReportWarningGroup{}
+	rv := []*ReportWarningGroup{}
+	for _, cert := range certificates {
+		for _, warning := range cert.Warnings {
+			group, ok := groups[warning.ID()]
+			if !ok {
+				group = &ReportWarningGroup{
+					ID:       warning.ID(),
+					Title:    warning.Title(),
+					Severity: warning.Severity(),
+				}
+				groups[warning.ID()] = group
+				rv = append(rv, group)
+			}
+			if warning.Severity() > group.Severity {
+				group.Severity = warning.Severity()
+			}
+			group.Certificates = append(group.Certificates, cert)
+		}
+	}
+
+	sort.SliceStable(rv, func(i, j int) bool {
+		if rv[i].Severity != rv[j].Severity {
+			return rv[i].Severity > rv[j].Severity
+		}
+		return rv[i].ID < rv[j].ID
+	})
+	return rv
+}
+
+func (r *Report) summary() ReportSummary {
+	summary := ReportSummary{
+		Chains:       len(r.Chains),
+		Certificates: len(r.Certificates),
+	}
+	for _, chain := range r.Chains {
+		if chain.Passed {
+			summary.Passed++
+		} else {
+			summary.Failed++
+		}
+	}
+	for _, cert := range r.Certificates {
+		switch cert.Status() {
+		case "expired":
+			summary.Expired++
+		case "expiring":
+			summary.ExpiringSoon++
+		}
+		for _, warning := range cert.Warnings {
+			switch warning.Severity() {
+			case SeverityError:
+				summary.Errors++
+			case SeverityWarning:
+				summary.Warnings++
+			default:
+				summary.Infos++
+			}
+		}
+	}
+	return summary
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteHTML writes the report as a single HTML page, with its styles inline
+// so it can be mailed or archived as is.
+func (r *Report) WriteHTML(out io.Writer) error {
+	return reportHTMLTemplate.Execute(out, r)
+}
+
+var reportTemplateFuncs = map[string]interface{}{
+	"date": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02")
+	},
+	"datetime": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02 15:04 UTC")
+	},
+	"join":     strings.Join,
+	"barWidth": timelineBarWidth,
+}
+
+// timelineBarWidth is the width, in percent, of a month's bar in the
+// timeline, relative to the busiest month.
+func timelineBarWidth(r *Report, month *ReportMonth) int {
+	busiest := 0
+	for _, other := range r.Timeline {
+		if len(other.Certificates) > busiest {
+			busiest = len(other.Certificates)
+		}
+	}
+	return 100 * len(month.Certificates) / busiest
+}
+
+var reportHTMLTemplate = template.Must(template.New("report").Funcs(reportTemplateFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Certificate audit, {{date .GeneratedAt}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 70em; padding: 0 1em; color: #222; }
+h1 { margin-bottom: 0; }
+.generated { color: #666; margin-top: .2em; }
+table { border-collapse: collapse; width: 100%; margin: 1em 0; }
+th, td { border-bottom: 1px solid #ddd; padding: .4em .6em; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+pre { background: #f7f7f7; border: 1px solid #ddd; padding: 1em; overflow-x: auto; font-size: .85em; }
+a { color: #0550ae; }
+.dashboard { display: flex; flex-wrap: wrap; gap: 1em; }
+.tile { border: 1px solid #ddd; border-radius: 6px; padding: .8em 1.2em; min-width: 8em; }
+.tile .value { font-size: 2em; font-weight: bold; }
+.tile .label { color: #666; }
+.pass, .valid { color: #1a7f37; }
+.fail, .expired, .error { color: #cf222e; font-weight: bold; }
+.expiring, .warning { color: #9a6700; }
+.info { color: #0969da; }
+.bar { background: #0969da; height: 1em; min-width: 2px; }
+.bar.expired { background: #cf222e; }
+</style>
+</head>
+<body>
+<h1>Certificate audit</h1>
+<p class="generated">Generated {{datetime .GeneratedAt}}</p>
+
+<h2 id="summary">Summary</h2>
+<div class="dashboard">
+<div class="tile"><div class="value">{{.Summary.Chains}}</div><div class="label">chains</div></div>
+<div class="tile"><div class="value pass">{{.Summary.Passed}}</div><div class="label">passed verification</div></div>
+<div class="tile"><div class="value{{if .Summary.Failed}} fail{{end}}">{{.Summary.Failed}}</div><div class="label">failed verification</div></div>
+<div class="tile"><div class="value">{{.Summary.Certificates}}</div><div class="label">certificates</div></div>
+<div class="tile"><div class="value{{if .Summary.Expired}} expired{{end}}">{{.Summary.Expired}}</div><div class="label">expired</div></div>
+<div class="tile"><div class="value{{if .Summary.ExpiringSoon}} expiring{{end}}">{{.Summary.ExpiringSoon}}</div><div class="label">expiring soon</div></div>
+<div class="tile"><div class="value{{if .Summary.Errors}} error{{end}}">{{.Summary.Errors}}</div><div class="label">errors</div></div>
+<div class="tile"><div class="value{{if .Summary.Warnings}} warning{{end}}">{{.Summary.Warnings}}</div><div class="label">warnings</div></div>
+<div class="tile"><div class="value">{{.Summary.Infos}}</div><div class="label">info</div></div>
+</div>
+
+<h2 id="verification">Verification</h2>
+<table>
+<tr><th>Source</th><th>Leaf</th><th>Result</th><th>Details</th></tr>
+{{- range .Chains}}
+<tr>
+<td>{{.Source}}</td>
+<td>{{with .Leaf}}<a href="#{{.Anchor}}">{{.Subject}}</a>{{else}}none{{end}}</td>
+<td>{{if .Passed}}<span class="pass">PASS</span>{{else}}<span class="fail">FAIL</span>{{end}}</td>
+<td>{{.Reason}}{{if .Error}}<details><summary>Details</summary><pre>{{.Error}}</pre></details>{{end}}</td>
+</tr>
+{{- end}}
+</table>
+
+<h2 id="timeline">Expiry timeline</h2>
+<table>
+<tr><th>Month</th><th>Certificates</th><th style="width: 30%"></th></tr>
+{{- range .Timeline}}
+<tr>
+<td>{{if eq .Month "expired"}}<span class="expired">Expired</span>{{else}}{{.Month}}{{end}}</td>
+<td>{{range $index, $cert := .Certificates}}{{if $index}}<br>{{end}}<a href="#{{.Anchor}}">{{.Subject}}</a> <span class="{{.Status}}">{{date .NotAfter}}</span>{{end}}</td>
+<td><div class="bar{{if eq .Month "expired"}} expired{{end}}" style="width: {{barWidth $ .}}%"></div></td>
+</tr>
+{{- end}}
+</table>
+
+<h2 id="warnings">Warnings</h2>
+{{- if .Warnings}}
+<table>
+<tr><th>Lint</th><th>Severity</th><th>Count</th><th>Certificates</th></tr>
+{{- range .Warnings}}
+<tr>
+<td><strong>{{.ID}}</strong><br>{{.Title}}</td>
+<td><span class="{{.Severity}}">{{.Severity}}</span></td>
+<td>{{len .Certificates}}</td>
+<td>{{range $index, $cert := .Certificates}}{{if $index}}<br>{{end}}<a href="#{{.Anchor}}">{{.Subject}}</a>{{end}}</td>
+</tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No warnings.</p>
+{{- end}}
+
+<h2 id="certificates">Certificates</h2>
+{{- range .Certificates}}
+<h3 id="{{.Anchor}}">{{.Subject}}</h3>
+<p>
+{{.Position}}, expires <span class="{{.Status}}">{{date .NotAfter}}</span>,
+worst warning: <span class="{{.WorstSeverity}}">{{.WorstSeverity}}</span><br>
+Found in: {{join .Sources ", "}}
+</p>
+<pre>{{join .Info "\n"}}</pre>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"io"
+	"strings"
+	"text/template"
+)
+
+// WriteMarkdown writes the report as a Markdown document, for wikis and
+// tickets.
+func (r *Report) WriteMarkdown(out io.Writer) error {
+	return reportMarkdownTemplate.Execute(out, r)
+}
+
+// markdownEscaper keeps subjects, SANs and sources from turning into
+// Markdown or HTML, or from breaking out of a table cell.
+var markdownEscaper = strings.NewReplacer(
+	"&", "&amp;", "<", "&lt;", ">", "&gt;",
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"(", `\(`, ")", `\)`, "#", `\#`, "!", `\!`, "~", `\~`, "|", `\|`,
+	"\n", " ",
+)
+
+func markdownText(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// markdownFence returns a code fence longer than any run of backticks in
+// text, so the text can't close it.
+func markdownFence(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+var reportMarkdownTemplate = template.Must(template.New("report").Funcs(reportTemplateFuncs).Funcs(
+	map[string]interface{}{
+		"md":    markdownText,
+		"fence": markdownFence,
+	}).Parse(`# Certificate audit
+
+Generated {{datetime .GeneratedAt}}
+
+## Summary
+
+| Chains | Passed | Failed | Certificates | Expired | Expiring soon | Errors | Warnings | Info |
+|-------:|-------:|-------:|-------------:|--------:|--------------:|-------:|---------:|-----:|
+| {{.Summary.Chains}} | {{.Summary.Passed}} | {{.Summary.Failed}} | {{.Summary.Certificates}} | {{.Summary.Expired}} | {{.Summary.ExpiringSoon}} | {{.Summary.Errors}} | {{.Summary.Warnings}} | {{.Summary.Infos}} |
+
+## Verification
+
+| Source | Leaf | Result | Details |
+|--------|------|--------|---------|
+{{- range .Chains}}
+| {{md .Source}} | {{with .Leaf}}[{{md .Subject}}](#{{.Anchor}}){{else}}none{{end}} | {{if .Passed}}PASS{{else}}**FAIL**{{end}} | {{md .Reason}} |
+{{- end}}
+
+## Expiry timeline
+
+| Month | Certificates |
+|-------|--------------|
+{{- range .Timeline}}
+| {{if eq .Month "expired"}}**Expired**{{else}}{{.Month}}{{end}} | {{range $index, $cert := .Certificates}}{{if $index}}<br>{{end}}[{{md .Subject}}](#{{.Anchor}}) {{date .NotAfter}}{{end}} |
+{{- end}}
+
+## Warnings
+{{if .Warnings}}
+| Lint | Severity | Count | Certificates |
+|------|----------|------:|--------------|
+{{- range .Warnings}}
+| **{{.ID}}**: {{md .Title}} | {{.Severity}} | {{len .Certificates}} | {{range $index, $cert := .Certificates}}{{if $index}}<br>{{end}}[{{md .Subject}}](#{{.Anchor}}){{end}} |
+{{- end}}
+{{else}}
+No warnings.
+{{end}}
+## Certificates
+{{range .Certificates}}
+### <a id="{{.Anchor}}"></a>{{md .Subject}}
+
+{{.Position}}, expires {{date .NotAfter}} ({{.Status}}), worst warning: {{.WorstSeverity}}.
+Found in: {{md (join .Sources ", ")}}
+{{$info := join .Info "\n"}}
+{{fence $info}}
+{{$info}}
+{{fence $info}}
+{{end}}`))
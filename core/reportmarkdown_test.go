@@ -0,0 +1,91 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarkdownText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"www.example.com", "www.example.com"},
+		{"*.example.com", `\*.example.com`},
+		{"CN=a|b", `CN=a\|b`},
+		{"[click](http://evil.test)", `\[click\]\(http://evil.test\)`},
+		{`<img src=x onerror="alert(1)">`, `&lt;img src=x onerror="alert\(1\)"&gt;`},
+		{"AT&T", "AT&amp;T"},
+		{"two\nlines", "two lines"},
+		{"# `code` _em_ ~x~ !", "\\# \\`code\\` \\_em\\_ \\~x\\~ \\!"},
+		{`back\slash`, `back\\slash`},
+	}
+	for _, test := range tests {
+		if got := markdownText(test.text); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestMarkdownFence(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain", "```"},
+		{"a `b` c", "```"},
+		{"a ``` b", "````"},
+		{"a ````` b ``", "``````"},
+	}
+	for _, test := range tests {
+		if got := markdownFence(test.text); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestWriteMarkdownEscapes(t *testing.T) {
+	cert := &ReportCertificate{
+		ID:       "0123456789abcdef0123456789abcdef",
+		Subject:  `<script>alert(1)</script> [x](http://evil.test)`,
+		Position: "leaf",
+		Sources:  []string{"certs/*_new_*.pem", "a|b.pem"},
+		NotAfter: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		Info:     []string{"Subject: evil", "```", "</pre>"},
+	}
+	report := &Report{
+		GeneratedAt:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Chains:       []*ReportChain{{Source: "a|b.pem", Passed: true, Leaf: cert, Certificates: []*ReportCertificate{cert}}},
+		Certificates: []*ReportCertificate{cert},
+		Timeline:     []*ReportMonth{{Month: "2030-01", Certificates: []*ReportCertificate{cert}}},
+		Warnings: []*ReportWarningGroup{{
+			ID: "missing-san", Title: "No SANs <b>", Severity: SeverityError,
+			Certificates: []*ReportCertificate{cert, cert},
+		}},
+	}
+
+	out := &bytes.Buffer{}
+	if err := report.WriteMarkdown(out); err != nil {
+		t.Fatal(err)
+	}
+	markdown := out.String()
+
+	subject := `&lt;script&gt;alert\(1\)&lt;/script&gt; \[x\]\(http://evil.test\)`
+	for _, want := range []string{
+		`| a\|b.pem | [` + subject + `](#cert-0123456789abcdef) | PASS |`,
+		`### <a id="cert-0123456789abcdef"></a>` + subject + "\n",
+		`[` + subject + `](#cert-0123456789abcdef)<br>[` + subject + `](#cert-0123456789abcdef) |`,
+		`| **missing-san**: No SANs &lt;b&gt; |`,
+		`Found in: certs/\*\_new\_\*.pem, a\|b.pem`,
+		"````\nSubject: evil\n```\n</pre>\n````\n",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("missing %q in:\n%s", want, markdown)
+		}
+	}
+	if strings.Contains(markdown, "<script>") {
+		t.Errorf("unescaped HTML in:\n%s", markdown)
+	}
+}
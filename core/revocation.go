@@ -0,0 +1,201 @@
+package core
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	RevocationGood    = "good"
+	RevocationRevoked = "revoked"
+	RevocationUnknown = "unknown"
+)
+
+var revocationHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// RevocationResult is the revocation status of one certificate, as told by
+// the first OCSP responder or CRL that gave an answer.
+type RevocationResult struct {
+	Certificate *Certificate
+	Status      string
+	Source      string
+	RevokedAt   time.Time
+	Reason      int
+	Errors      []error
+}
+
+func (r *RevocationResult) Description() string {
+	switch r.Status {
+	case RevocationGood:
+		return fmt.Sprintf("good (%s)", r.Source)
+	case RevocationRevoked:
+		return fmt.Sprintf(
+			"REVOKED at %s, reason: %s (%s)",
+			r.RevokedAt.UTC(), RevocationReasonName(r.Reason), r.Source)
+	default:
+		if len(r.Errors) == 0 {
+			return "unknown (no OCSP responder or CRL listed)"
+		}
+		return "unknown (no responder or CRL gave an answer)"
+	}
+}
+
+// CheckRevocation checks every certificate in the verified chain, except the
+// root, against its issuer's OCSP responder, falling back to CRLs.
+func (c *CertificateChain) CheckRevocation() ([]*RevocationResult, error) {
+	verifiedChains, err := c.Leaf.Certificate.Verify(c.verifyOptions(""))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to check revocation of an unverified chain: %s", err)
+	}
+	verifiedChain := verifiedChains[0]
+
+	results := []*RevocationResult{}
+	for i := 0; i+1 < len(verifiedChain); i++ {
+		results = append(results, (&Certificate{Certificate: verifiedChain[i]}).CheckRevocation(
+			&Certificate{Certificate: verifiedChain[i+1]}))
+	}
+	return results, nil
+}
+
+func (c *Certificate) CheckRevocation(issuer *Certificate) *RevocationResult {
+	result := &RevocationResult{
+		Certificate: c,
+		Status:      RevocationUnknown,
+	}
+
+	for _, url := range c.Certificate.OCSPServer {
+		if err := checkOCSP(result, c.Certificate, issuer.Certificate, url); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("OCSP %s: %s", url, err))
+		} else if result.Status != RevocationUnknown {
+			return result
+		}
+	}
+
+	for _, url := range c.Certificate.CRLDistributionPoints {
+		if err := checkCRL(result, c.Certificate, issuer.Certificate, url); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("CRL %s: %s", url, err))
+		} else {
+			return result
+		}
+	}
+
+	return result
+}
+
+func checkOCSP(result *RevocationResult, cert, issuer *x509.Certificate, url string) error {
+	_, response, err := fetchOCSPResponse(cert, issuer, url)
+	if err != nil {
+		return err
+	}
+	if !response.NextUpdate.IsZero() && response.NextUpdate.Before(time.Now()) {
+		return fmt.Errorf("response is stale (next update was %s)", response.NextUpdate)
+	}
+
+	result.Source = "OCSP " + url
+	switch response.Status {
+	case ocsp.Good:
+		result.Status = RevocationGood
+	case ocsp.Revoked:
+		result.Status = RevocationRevoked
+		result.RevokedAt = response.RevokedAt
+		result.Reason = response.RevocationReason
+	default:
+		return fmt.Errorf("responder doesn't know this certificate")
+	}
+	return nil
+}
+
+func fetchOCSPResponse(cert, issuer *x509.Certificate, url string) ([]byte, *ocsp.Response, error) {
+	request, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpResponse, err := revocationHTTPClient.Post(
+		url, "application/ocsp-request", bytes.NewReader(request))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("HTTP status %s", httpResponse.Status)
+	}
+	body, err := ioutil.ReadAll(httpResponse.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response, err := ocsp.ParseResponseForCert(body, cert, issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, response, nil
+}
+
+// FetchOCSPStaple gets a response from the certificate's OCSP responders,
+// suitable for stapling.
+func (c *Certificate) FetchOCSPStaple(issuer *Certificate) ([]byte, error) {
+	if len(c.Certificate.OCSPServer) == 0 {
+		return nil, fmt.Errorf("Certificate doesn't list an OCSP responder")
+	}
+
+	var err error
+	for _, url := range c.Certificate.OCSPServer {
+		var raw []byte
+		raw, _, err = fetchOCSPResponse(c.Certificate, issuer.Certificate, url)
+		if err == nil {
+			return raw, nil
+		}
+		err = fmt.Errorf("Unable to fetch OCSP response from %s: %s", url, err)
+	}
+	return nil, err
+}
+
+func checkCRL(result *RevocationResult, cert, issuer *x509.Certificate, url string) error {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return fmt.Errorf("unsupported URL scheme")
+	}
+
+	httpResponse, err := revocationHTTPClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP status %s", httpResponse.Status)
+	}
+	body, err := ioutil.ReadAll(httpResponse.Body)
+	if err != nil {
+		return err
+	}
+
+	crl, err := x509.ParseRevocationList(body)
+	if err != nil {
+		return err
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return err
+	}
+	if !crl.NextUpdate.IsZero() && crl.NextUpdate.Before(time.Now()) {
+		return fmt.Errorf("CRL is stale (next update was %s)", crl.NextUpdate)
+	}
+
+	result.Source = "CRL " + url
+	result.Status = RevocationGood
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			result.Status = RevocationRevoked
+			result.RevokedAt = entry.RevocationTime
+			result.Reason = entry.ReasonCode
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,404 @@
+package core
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const testPKIHostname = "www.example.test"
+
+// TestPKIFixture is a small generated PKI with a deliberate defect, used to
+// reproduce chain handling issues without real certificates.
+type TestPKIFixture struct {
+	Defect      string
+	Description string
+	Expected    string
+	Hostname    string
+
+	// Roots are the trust anchors; Chain is what a server would send after
+	// the leaf, defects included.
+	Roots []*Certificate
+	Leaf  *Certificate
+	Chain []*Certificate
+
+	// Extra holds other certificates worth having around, by file name.
+	Extra map[string]*Certificate
+}
+
+type testPKIDefect struct {
+	name        string
+	description string
+	expected    string
+	build       func(f *TestPKIFixture) error
+}
+
+var testPKIDefects = []testPKIDefect{
+	{
+		"expired-intermediate",
+		"The intermediate certificate expired yesterday.",
+		"Verification fails: the intermediate has expired, and it has an expired warning.",
+		buildExpiredIntermediatePKI,
+	},
+	{
+		"missing-ski",
+		"The leaf has neither a subject nor an authority key identifier, so its issuer can only be found by name.",
+		"Verification passes; the leaf's key ID is computed from its key, and its issuer is shown as [no key ID].",
+		buildMissingSKIPKI,
+	},
+	{
+		"wrong-order",
+		"There are two intermediates and the server sends them in reverse order.",
+		"Verification passes, since most clients don't enforce the order, with chain-out-of-order warnings.",
+		buildWrongOrderPKI,
+	},
+	{
+		"included-root",
+		"The served chain includes the root certificate.",
+		"Verification passes, with a root-in-chain note: the root is unnecessary and wastes handshake bytes.",
+		buildIncludedRootPKI,
+	},
+	{
+		"sha1-signature",
+		"The leaf is signed with SHA-1 (SHA1WithRSA).",
+		"Verification fails because the leaf's SHA-1 signature is rejected, and the leaf has an obsolete algorithm warning.",
+		buildSHA1SignaturePKI,
+	},
+	{
+		"short-rsa-key",
+		"The leaf has a 1024-bit RSA key.",
+		"Verification passes, with a key too short warning.",
+		buildShortRSAKeyPKI,
+	},
+	{
+		"cross-signed-root",
+		"The intermediate is issued by a new root, which is cross-signed by the trusted old root; the server sends the cross-signed certificate.",
+		"Verification passes through the old root; new-root.crt can be used as a trust store to check the short path.",
+		buildCrossSignedRootPKI,
+	},
+	{
+		"hostname-mismatch",
+		"The leaf is valid for other.example.test only.",
+		"Verification against www.example.test fails with a hostname mismatch.",
+		buildHostnameMismatchPKI,
+	},
+	{
+		"name-constraint-violation",
+		"The intermediate may only issue for example.org, but the leaf is for www.example.test.",
+		"Verification fails: the name isn't permitted by the intermediate's name constraints.",
+		buildNameConstraintViolationPKI,
+	},
+}
+
+func TestPKIDefects() []string {
+	names := []string{}
+	for _, defect := range testPKIDefects {
+		names = append(names, defect.name)
+	}
+	return names
+}
+
+func GenerateTestPKI(defectName string) (*TestPKIFixture, error) {
+	for _, defect := range testPKIDefects {
+		if defect.name != defectName {
+			continue
+		}
+
+		fixture := &TestPKIFixture{
+			Defect:      defect.name,
+			Description: defect.description,
+			Expected:    defect.expected,
+			Hostname:    testPKIHostname,
+		}
+		if err := defect.build(fixture); err != nil {
+			return nil, fmt.Errorf("Unable to generate %s: %s", defect.name, err)
+		}
+		return fixture, nil
+	}
+
+	return nil, fmt.Errorf(
+		"Unknown defect '%s', expected one of %s", defectName, strings.Join(TestPKIDefects(), ", "))
+}
+
+// WriteTo writes the fixture files to dir:
+//
+//	root.crt       trust anchors, for --trust-store
+//	leaf.crt       the leaf certificate
+//	leaf.key       its private key
+//	chain.pem      the certificates served after the leaf
+//	fullchain.pem  leaf.crt followed by chain.pem
+//	README.txt     the defect, the expected behavior and how to check it
+func (f *TestPKIFixture) WriteTo(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	roots := &bytes.Buffer{}
+	for _, root := range f.Roots {
+		roots.Write(root.CertificateToPEM())
+	}
+	chain := &bytes.Buffer{}
+	for _, cert := range f.Chain {
+		chain.Write(cert.CertificateToPEM())
+	}
+	key, err := f.Leaf.PrivateKeyToPEM()
+	if err != nil {
+		return err
+	}
+
+	readme := fmt.Sprintf(`%s
+
+%s
+
+Expected: %s
+
+To check:
+
+  chaintool --trust-store root.crt --suppress internal-name-in-san \
+    inspect --hostname %s fullchain.pem leaf.key
+
+The .test names are reserved for testing, so internal-name-in-san would
+flag every leaf; it's suppressed to leave only the defect.
+`, f.Defect, f.Description, f.Expected, f.Hostname)
+
+	files := []fixtureFile{
+		{"root.crt", roots.Bytes(), 0644},
+		{"leaf.crt", f.Leaf.CertificateToPEM(), 0644},
+		{"leaf.key", key, 0600},
+		{"chain.pem", chain.Bytes(), 0644},
+		{"fullchain.pem", append(f.Leaf.CertificateToPEM(), chain.Bytes()...), 0644},
+		{"README.txt", []byte(readme), 0644},
+	}
+	for name, cert := range f.Extra {
+		files = append(files, fixtureFile{name, cert.CertificateToPEM(), 0644})
+	}
+	for _, file := range files {
+		if err := writeFileWithPerm(filepath.Join(dir, file.name), file.data, file.perm); err != nil {
+			return fmt.Errorf("Unable to write %s: %s", file.name, err)
+		}
+	}
+	return nil
+}
+
+type fixtureFile struct {
+	name string
+	data []byte
+	perm os.FileMode
+}
+
+// testCertificateOptions tweaks the defaults of newTestCertificate.
+type testCertificateOptions struct {
+	key      KeySpec
+	mutate   func(template *x509.Certificate)
+	noKeyIDs bool
+}
+
+// newTestCertificate issues a CA certificate (when isCA is set) or a leaf
+// for testPKIHostname. A nil issuer means self-signed.
+func newTestCertificate(
+	commonName string,
+	isCA bool,
+	issuer *Certificate,
+	options testCertificateOptions,
+) (*Certificate, error) {
+	if options.key.Type == "" {
+		options.key.Type = KeyTypeECDSA
+	}
+	privateKey, err := GeneratePrivateKey(options.key)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := publicKeyFromPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:   commonName,
+			Organization: []string{"chaintool Test PKI"},
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		BasicConstraintsValid: true,
+	}
+	if isCA {
+		template.IsCA = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+		template.NotAfter = now.AddDate(10, 0, 0)
+	} else {
+		template.NotAfter = now.AddDate(0, 0, 180)
+		template.KeyUsage = leafKeyUsage(publicKey)
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		template.DNSNames = []string{commonName}
+	}
+	if options.mutate != nil {
+		options.mutate(template)
+	}
+
+	issuerKey := privateKey
+	if issuer != nil {
+		issuerKey = issuer.PrivateKey
+		if options.noKeyIDs {
+			// The authority key ID is copied from the parent's subject key ID.
+			parent := *issuer.Certificate
+			parent.SubjectKeyId = nil
+			issuer = &Certificate{Certificate: &parent}
+		}
+	}
+
+	cert, err := createCertificate(template, issuer, publicKey, issuerKey)
+	if err != nil {
+		return nil, err
+	}
+	cert.PrivateKey = privateKey
+	return cert, nil
+}
+
+// buildTestPKI creates a root, intermediates issued in sequence and a leaf
+// for testPKIHostname, filling in a fixture served in the correct order.
+func buildTestPKI(
+	f *TestPKIFixture,
+	intermediateCount int,
+	intermediateOptions testCertificateOptions,
+	leafOptions testCertificateOptions,
+) error {
+	root, err := newTestCertificate("Test Root", true, nil, testCertificateOptions{})
+	if err != nil {
+		return err
+	}
+
+	issuer := root
+	intermediates := []*Certificate{}
+	for i := 0; i < intermediateCount; i++ {
+		name := "Test Intermediate"
+		if intermediateCount > 1 {
+			name = fmt.Sprintf("Test Intermediate %d", i+1)
+		}
+		intermediate, err := newTestCertificate(name, true, issuer, intermediateOptions)
+		if err != nil {
+			return err
+		}
+		intermediates = append([]*Certificate{intermediate}, intermediates...)
+		issuer = intermediate
+	}
+
+	leaf, err := newTestCertificate(testPKIHostname, false, issuer, leafOptions)
+	if err != nil {
+		return err
+	}
+
+	f.Roots = []*Certificate{root}
+	f.Leaf = leaf
+	f.Chain = intermediates
+	return nil
+}
+
+func buildExpiredIntermediatePKI(f *TestPKIFixture) error {
+	return buildTestPKI(f, 1, testCertificateOptions{
+		mutate: func(template *x509.Certificate) {
+			template.NotBefore = time.Now().AddDate(-2, 0, 0)
+			template.NotAfter = time.Now().AddDate(0, 0, -1)
+		},
+	}, testCertificateOptions{})
+}
+
+func buildMissingSKIPKI(f *TestPKIFixture) error {
+	return buildTestPKI(f, 1, testCertificateOptions{}, testCertificateOptions{noKeyIDs: true})
+}
+
+func buildWrongOrderPKI(f *TestPKIFixture) error {
+	if err := buildTestPKI(f, 2, testCertificateOptions{}, testCertificateOptions{}); err != nil {
+		return err
+	}
+	f.Chain[0], f.Chain[1] = f.Chain[1], f.Chain[0]
+	return nil
+}
+
+func buildIncludedRootPKI(f *TestPKIFixture) error {
+	if err := buildTestPKI(f, 1, testCertificateOptions{}, testCertificateOptions{}); err != nil {
+		return err
+	}
+	f.Chain = append(f.Chain, f.Roots[0])
+	return nil
+}
+
+func buildSHA1SignaturePKI(f *TestPKIFixture) error {
+	return buildTestPKI(f, 1, testCertificateOptions{
+		key: KeySpec{Type: KeyTypeRSA},
+	}, testCertificateOptions{
+		mutate: func(template *x509.Certificate) {
+			template.SignatureAlgorithm = x509.SHA1WithRSA
+		},
+	})
+}
+
+func buildShortRSAKeyPKI(f *TestPKIFixture) error {
+	return buildTestPKI(f, 1, testCertificateOptions{}, testCertificateOptions{
+		key: KeySpec{Type: KeyTypeRSA, Bits: 1024},
+	})
+}
+
+func buildCrossSignedRootPKI(f *TestPKIFixture) error {
+	oldRoot, err := newTestCertificate("Test Old Root", true, nil, testCertificateOptions{})
+	if err != nil {
+		return err
+	}
+	newRoot, err := newTestCertificate("Test New Root", true, nil, testCertificateOptions{})
+	if err != nil {
+		return err
+	}
+
+	// The cross-signed certificate has the new root's subject and key, but
+	// is issued by the old root.
+	crossSigned, err := createCertificate(&x509.Certificate{
+		Subject:               newRoot.Certificate.Subject,
+		NotBefore:             newRoot.Certificate.NotBefore,
+		NotAfter:              newRoot.Certificate.NotAfter,
+		KeyUsage:              newRoot.Certificate.KeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SubjectKeyId:          newRoot.Certificate.SubjectKeyId,
+	}, oldRoot, newRoot.Certificate.PublicKey, oldRoot.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	intermediate, err := newTestCertificate("Test Intermediate", true, newRoot, testCertificateOptions{})
+	if err != nil {
+		return err
+	}
+	leaf, err := newTestCertificate(testPKIHostname, false, intermediate, testCertificateOptions{})
+	if err != nil {
+		return err
+	}
+
+	f.Roots = []*Certificate{oldRoot}
+	f.Leaf = leaf
+	f.Chain = []*Certificate{intermediate, crossSigned}
+	f.Extra = map[string]*Certificate{"new-root.crt": newRoot}
+	return nil
+}
+
+func buildHostnameMismatchPKI(f *TestPKIFixture) error {
+	return buildTestPKI(f, 1, testCertificateOptions{}, testCertificateOptions{
+		mutate: func(template *x509.Certificate) {
+			template.Subject.CommonName = "other.example.test"
+			template.DNSNames = []string{"other.example.test"}
+		},
+	})
+}
+
+func buildNameConstraintViolationPKI(f *TestPKIFixture) error {
+	return buildTestPKI(f, 1, testCertificateOptions{
+		mutate: func(template *x509.Certificate) {
+			template.PermittedDNSDomains = []string{"example.org"}
+		},
+	}, testCertificateOptions{})
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"crypto/x509"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestGenerateTestPKI checks that every fixture shows what its README
+// promises.
+func TestGenerateTestPKI(t *testing.T) {
+	tests := []struct {
+		defect   string
+		warnings []string
+		err      string
+	}{
+		{defect: "expired-intermediate", warnings: []string{"expired"}, err: "expired"},
+		{defect: "missing-ski"},
+		{defect: "wrong-order", warnings: []string{"chain-out-of-order", "chain-out-of-order"}},
+		{defect: "included-root", warnings: []string{"root-in-chain"}},
+		{
+			defect:   "sha1-signature",
+			warnings: []string{"obsolete-signature-algorithm"},
+			err:      "Is signed with SHA1-RSA, which clients no longer accept",
+		},
+		{defect: "short-rsa-key", warnings: []string{"key-too-short"}},
+		{defect: "cross-signed-root"},
+		{defect: "hostname-mismatch", err: "Doesn't match the target hostname"},
+		{defect: "name-constraint-violation", err: "is not permitted by any constraint"},
+	}
+	if len(tests) != len(TestPKIDefects()) {
+		t.Errorf("got %d defects, want %d", len(TestPKIDefects()), len(tests))
+	}
+
+	defer func(roots []*x509.Certificate) {
+		trustedRoots = roots
+		certPoolCache = nil
+	}(trustedRoots)
+
+	for _, test := range tests {
+		fixture, err := GenerateTestPKI(test.defect)
+		if err != nil {
+			t.Errorf("%s: %s", test.defect, err)
+			continue
+		}
+		trustedRoots = nil
+		AddTrustedRoots(fixture.Roots)
+
+		chain, remaining, err := ChainFromCertificates(append([]*Certificate{fixture.Leaf}, fixture.Chain...))
+		if err != nil {
+			t.Errorf("%s: %s", test.defect, err)
+			continue
+		}
+		if len(remaining) > 0 {
+			t.Errorf("%s: got %d unrelated certificates", test.defect, len(remaining))
+		}
+
+		warnings := []string{}
+		for _, w := range chain.Warnings() {
+			if w.ID() != "internal-name-in-san" {
+				warnings = append(warnings, w.ID())
+			}
+		}
+		if len(warnings) > 0 || len(test.warnings) > 0 {
+			if !reflect.DeepEqual(warnings, test.warnings) {
+				t.Errorf("%s: got warnings %v, want %v", test.defect, warnings, test.warnings)
+			}
+		}
+
+		err = chain.Verify(fixture.Hostname)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %s", test.defect, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: got error %v, want %q", test.defect, err, test.err)
+		}
+	}
+}
+
+func TestMissingSKIFixture(t *testing.T) {
+	fixture, err := GenerateTestPKI("missing-ski")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := fixture.Leaf.Certificate
+	if len(leaf.SubjectKeyId) > 0 || len(leaf.AuthorityKeyId) > 0 {
+		t.Errorf("got SKI %x and AKI %x, want neither", leaf.SubjectKeyId, leaf.AuthorityKeyId)
+	}
+	if fixture.Leaf.ReadableIssuer() != "[no key ID] (Test Intermediate)" {
+		t.Errorf("got issuer %s", fixture.Leaf.ReadableIssuer())
+	}
+}
@@ -0,0 +1,395 @@
+package core
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kinds of trace steps.
+const (
+	TraceSearch    = "search"
+	TraceCandidate = "candidate"
+	TraceFetch     = "fetch"
+	TraceIssuer    = "issuer"
+	TraceRoot      = "root"
+	TraceFailure   = "failure"
+	TraceVerify    = "verify"
+)
+
+// Outcomes of the checks on a candidate issuer.
+const (
+	TraceMatch      = "match"
+	TraceMismatch   = "mismatch"
+	TraceAbsent     = "absent"
+	TraceValid      = "valid"
+	TraceInvalid    = "invalid"
+	TraceNotChecked = "not checked"
+)
+
+// maxTraceDepth bounds chain building, in case issuers loop.
+const maxTraceDepth = 10
+
+// Trace records the decisions made while building and verifying a chain:
+// which issuers were considered for each certificate, how they compared and
+// why they were accepted or rejected. Tracing into a nil *Trace records
+// nothing, so the code building chains can trace unconditionally.
+type Trace struct {
+	Steps []*TraceStep `json:"steps"`
+}
+
+// TraceStep is one decision. Certificate is the one whose issuer was being
+// looked for. Candidate and the checks are set for candidate issuers, URL
+// and the HTTP fields for AIA fetches.
+type TraceStep struct {
+	Kind        string `json:"kind"`
+	Certificate string `json:"certificate,omitempty"`
+	Candidate   string `json:"candidate,omitempty"`
+	Origin      string `json:"origin,omitempty"`
+	URL         string `json:"url,omitempty"`
+	HTTPStatus  int    `json:"http_status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Cached      bool   `json:"cached,omitempty"`
+	Name        string `json:"name,omitempty"`
+	KeyID       string `json:"key_id,omitempty"`
+	Signature   string `json:"signature,omitempty"`
+	Accepted    bool   `json:"accepted"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+func (t *Trace) record(step *TraceStep) {
+	if t != nil {
+		t.Steps = append(t.Steps, step)
+	}
+}
+
+func (t *Trace) search(cert *Certificate) {
+	t.record(&TraceStep{
+		Kind:        TraceSearch,
+		Certificate: cert.ReadableSubject(),
+		Reason:      fmt.Sprintf("issued by %s", cert.ReadableIssuer()),
+	})
+}
+
+func (t *Trace) failure(cert *Certificate, format string, a ...interface{}) {
+	t.record(&TraceStep{
+		Kind:        TraceFailure,
+		Certificate: cert.ReadableSubject(),
+		Reason:      fmt.Sprintf(format, a...),
+	})
+}
+
+// considerIssuer compares cert's issuer name and authority key ID with the
+// candidate, checks the signature, and records the outcome. Candidates are
+// accepted when the name matches, the signature verifies and they haven't
+// expired; a key ID mismatch alone doesn't reject them, as verifiers only
+// use key IDs to pick candidates.
+func (t *Trace) considerIssuer(cert, candidate *Certificate, origin string) bool {
+	step := &TraceStep{
+		Kind:        TraceCandidate,
+		Certificate: cert.ReadableSubject(),
+		Candidate:   candidate.ReadableSubject(),
+		Origin:      origin,
+		Name:        TraceMismatch,
+		KeyID:       TraceAbsent,
+		Signature:   TraceNotChecked,
+	}
+	defer t.record(step)
+
+	if bytes.Equal(cert.Certificate.RawIssuer, candidate.Certificate.RawSubject) {
+		step.Name = TraceMatch
+	}
+	if len(cert.Certificate.AuthorityKeyId) > 0 {
+		if bytes.Equal(cert.Certificate.AuthorityKeyId, candidate.SubjectKeyID()) {
+			step.KeyID = TraceMatch
+		} else if candidate.HasSubjectKeyIDExtension() {
+			step.KeyID = TraceMismatch
+		}
+	}
+
+	if step.Name != TraceMatch {
+		step.Reason = fmt.Sprintf(
+			"its subject %q isn't the issuer name %q",
+			candidate.Certificate.Subject.String(), cert.Certificate.Issuer.String())
+		return false
+	}
+
+	err := candidate.Certificate.CheckSignature(
+		cert.Certificate.SignatureAlgorithm,
+		cert.Certificate.RawTBSCertificate,
+		cert.Certificate.Signature)
+	if err != nil {
+		step.Signature = TraceInvalid
+		step.Reason = fmt.Sprintf("the signature doesn't verify with its key: %s", err)
+		return false
+	}
+	step.Signature = TraceValid
+
+	now := time.Now()
+	if now.After(candidate.Certificate.NotAfter) {
+		step.Reason = fmt.Sprintf("it expired on %s", candidate.Certificate.NotAfter.UTC().Format("2006-01-02"))
+		return false
+	}
+	if now.Before(candidate.Certificate.NotBefore) {
+		step.Reason = fmt.Sprintf("it isn't valid until %s", candidate.Certificate.NotBefore.UTC().Format("2006-01-02"))
+		return false
+	}
+
+	step.Accepted = true
+	return true
+}
+
+// fetchIssuer downloads a candidate issuer from an AIA URL, recording the
+// HTTP status and content type.
+func (t *Trace) fetchIssuer(cert *Certificate, url string) (*Certificate, error) {
+	step := &TraceStep{
+		Kind:        TraceFetch,
+		Certificate: cert.ReadableSubject(),
+		URL:         url,
+	}
+	defer t.record(step)
+
+	x509Cert, fetch, err := fetchCertificate(url)
+	if fetch != nil {
+		step.HTTPStatus = fetch.Status
+		step.ContentType = fetch.ContentType
+		step.Cached = fetch.Cached
+	}
+	if err != nil {
+		step.Reason = err.Error()
+		return nil, err
+	}
+
+	step.Accepted = true
+	issuer := &Certificate{Certificate: x509Cert, Source: "AIA " + url}
+	step.Candidate = issuer.ReadableSubject()
+	return issuer, nil
+}
+
+// Explain retraces how the chain is built and verified, from the leaf up:
+// the served certificates, trust store and bundled roots considered as
+// issuers at each step, then the intermediate database and, with fetchAIA,
+// the issuers the certificates' AIA URLs point to when no other candidate
+// fits, giving up after maxTraceDepth certificates. It ends with the outcome
+// of verifying the chain, checking dnsName if not empty.
+func (c *CertificateChain) Explain(dnsName string, fetchAIA bool) *Trace {
+	t := &Trace{}
+	used := map[*Certificate]bool{}
+	usedAIA := false
+	current := c.Leaf
+
+	for depth := 0; ; depth++ {
+		if depth == maxTraceDepth {
+			t.failure(current, "depth limit reached, with no root within %d certificates", maxTraceDepth)
+			break
+		}
+		used[current] = true
+
+		if current.IsSelfSigned() {
+			if current.IsTrusted() {
+				t.record(&TraceStep{
+					Kind:        TraceRoot,
+					Certificate: current.ReadableSubject(),
+					Accepted:    true,
+					Reason:      "it's a self-signed trusted root",
+				})
+			} else {
+				t.failure(current, "it's self-signed, but not a trusted root")
+			}
+			break
+		}
+
+		t.search(current)
+
+		var issuer *Certificate
+		for _, candidate := range c.Intermediates {
+			if !used[candidate] && t.considerIssuer(current, candidate, "served") && issuer == nil {
+				issuer = candidate
+			}
+		}
+
+		if root := t.considerRoots(current); root != nil {
+			t.record(&TraceStep{
+				Kind:        TraceRoot,
+				Certificate: current.ReadableSubject(),
+				Candidate:   root.ReadableSubject(),
+				Accepted:    true,
+			})
+			break
+		}
+
+		if issuer == nil {
+			issuer = t.considerDatabase(current)
+		}
+
+		if issuer == nil && fetchAIA {
+			for _, url := range current.Certificate.IssuingCertificateURL {
+				fetched, err := t.fetchIssuer(current, url)
+				if err == nil && t.considerIssuer(current, fetched, "AIA") {
+					issuer = fetched
+					usedAIA = true
+					break
+				}
+			}
+		}
+
+		if issuer == nil {
+			switch {
+			case len(current.Certificate.IssuingCertificateURL) == 0:
+				t.failure(current, "no candidate issuer fits, and it has no AIA URL to fetch one from")
+			case !fetchAIA:
+				t.failure(current, "no candidate issuer fits, and AIA fetching is disabled")
+			default:
+				t.failure(current, "no candidate issuer fits, including the ones its AIA URLs point to")
+			}
+			break
+		}
+
+		t.record(&TraceStep{
+			Kind:        TraceIssuer,
+			Certificate: current.ReadableSubject(),
+			Candidate:   issuer.ReadableSubject(),
+			Accepted:    true,
+		})
+		current = issuer
+	}
+
+	step := &TraceStep{Kind: TraceVerify, Certificate: c.Leaf.ReadableSubject()}
+	if _, err := c.Leaf.Certificate.Verify(c.verifyOptions(dnsName)); err != nil {
+		step.Reason = err.Error()
+		if _, ok := err.(x509.UnknownAuthorityError); ok && usedAIA {
+			step.Reason += ". The path above needs certificates fetched from AIA URLs, " +
+				"which many clients don't do: include them in the served chain"
+		}
+	} else {
+		step.Accepted = true
+	}
+	t.record(step)
+	return t
+}
+
+// considerRoots records the trusted roots that could have issued cert and
+// returns one that did, if any. Roots from the trust store are all compared;
+// bundled roots can't be listed, so the ones that issued cert are found by
+// verifying it against the pool.
+func (t *Trace) considerRoots(cert *Certificate) *Certificate {
+	var issuer *Certificate
+	for _, root := range trustedRoots {
+		candidate := &Certificate{Certificate: root}
+		if bytes.Equal(cert.Certificate.RawIssuer, root.RawSubject) || bytes.Equal(cert.Certificate.AuthorityKeyId, candidate.SubjectKeyID()) {
+			if t.considerIssuer(cert, candidate, "trust store") && issuer == nil {
+				issuer = candidate
+			}
+		}
+	}
+	if issuer != nil {
+		return issuer
+	}
+
+	paths, _ := cert.Certificate.Verify(x509.VerifyOptions{
+		Roots:       MustCertPool(),
+		CurrentTime: time.Now(),
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	for _, path := range paths {
+		if len(path) == 2 {
+			candidate := &Certificate{Certificate: path[1]}
+			if t.considerIssuer(cert, candidate, "bundled") {
+				return candidate
+			}
+		}
+	}
+
+	if _, bundled := certPoolSubjectSet()[string(cert.Certificate.RawIssuer)]; bundled && len(paths) == 0 {
+		t.record(&TraceStep{
+			Kind:        TraceCandidate,
+			Certificate: cert.ReadableSubject(),
+			Candidate:   fmt.Sprintf("bundled root named %q", cert.Certificate.Issuer.String()),
+			Origin:      "bundled",
+			Name:        TraceMatch,
+			Signature:   TraceInvalid,
+			Reason:      "the certificate doesn't verify against it, so its key or validity don't fit",
+		})
+	}
+	return nil
+}
+
+// Lines narrates the trace, one paragraph per certificate whose issuer was
+// looked for.
+func (t *Trace) Lines(wrapLength int) *Lines {
+	lines := NewLines()
+	for _, step := range t.Steps {
+		prefix, indent := "  ", "    "
+		if step.Kind == TraceSearch || step.Kind == TraceVerify {
+			if len(lines.Lines) > 0 {
+				lines.Print("")
+			}
+			prefix, indent = "", "  "
+		}
+		for _, line := range wordWrapLines(step.Narrative(), wrapLength-len(indent)) {
+			lines.Print("%s%s", prefix, line)
+			prefix = indent
+		}
+	}
+	return lines
+}
+
+// Narrative describes the step in a sentence or two.
+func (s *TraceStep) Narrative() string {
+	switch s.Kind {
+	case TraceSearch:
+		return fmt.Sprintf("Looking for the issuer of %s, %s:", s.Certificate, s.Reason)
+	case TraceCandidate:
+		checks := []string{
+			fmt.Sprintf("name %s", s.Name),
+			fmt.Sprintf("key ID %s", s.KeyID),
+			fmt.Sprintf("signature %s", s.Signature),
+		}
+		if s.KeyID == "" {
+			checks = append(checks[:1], checks[2])
+		}
+		verdict := "Accepted."
+		if !s.Accepted {
+			verdict = fmt.Sprintf("Rejected: %s.", s.Reason)
+		}
+		return fmt.Sprintf("Candidate %s (%s): %s. %s", s.Candidate, s.Origin, strings.Join(checks, ", "), verdict)
+	case TraceFetch:
+		var response string
+		switch {
+		case s.Cached && s.HTTPStatus == 0:
+			response = "from the AIA cache"
+		case s.Cached:
+			response = fmt.Sprintf("HTTP %d, cached copy still valid", s.HTTPStatus)
+		case s.HTTPStatus != 0:
+			contentType := s.ContentType
+			if contentType == "" {
+				contentType = "no content type"
+			}
+			response = fmt.Sprintf("HTTP %d, %s", s.HTTPStatus, contentType)
+		default:
+			return fmt.Sprintf("%s.", s.Reason)
+		}
+		if !s.Accepted {
+			return fmt.Sprintf("Fetched %s: %s. Failed: %s.", s.URL, response, s.Reason)
+		}
+		return fmt.Sprintf("Fetched %s: %s, got %s.", s.URL, response, s.Candidate)
+	case TraceIssuer:
+		return fmt.Sprintf("Using %s as the issuer.", s.Candidate)
+	case TraceRoot:
+		if s.Candidate != "" {
+			return fmt.Sprintf("Reached trusted root %s.", s.Candidate)
+		}
+		return fmt.Sprintf("Stopping at %s: %s.", s.Certificate, s.Reason)
+	case TraceFailure:
+		return fmt.Sprintf("Giving up on %s: %s.", s.Certificate, s.Reason)
+	case TraceVerify:
+		if s.Accepted {
+			return "Verification: PASSED!"
+		}
+		return fmt.Sprintf("Verification: FAILED. %s", s.Reason)
+	default:
+		return s.Reason
+	}
+}
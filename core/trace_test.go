@@ -0,0 +1,244 @@
+package core
+
+import (
+	"crypto/x509"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTestRoots trusts only roots for the rest of the test.
+func useTestRoots(t *testing.T, roots ...*Certificate) {
+	t.Helper()
+	previous := trustedRoots
+	t.Cleanup(func() {
+		trustedRoots = previous
+		certPoolCache = nil
+	})
+	trustedRoots = nil
+	for _, root := range roots {
+		trustedRoots = append(trustedRoots, root.Certificate)
+	}
+	certPoolCache = nil
+}
+
+func traceKinds(trace *Trace) []string {
+	rv := []string{}
+	for _, step := range trace.Steps {
+		rv = append(rv, step.Kind)
+	}
+	return rv
+}
+
+func TestExplain(t *testing.T) {
+	f := &TestPKIFixture{}
+	if err := buildTestPKI(f, 2, testCertificateOptions{}, testCertificateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	useTestRoots(t, f.Roots...)
+
+	chain := &CertificateChain{Leaf: f.Leaf, Intermediates: f.Chain}
+	trace := chain.Explain(testPKIHostname, false)
+	want := []string{
+		TraceSearch, TraceCandidate, TraceCandidate, TraceIssuer,
+		TraceSearch, TraceCandidate, TraceIssuer,
+		TraceSearch, TraceCandidate, TraceRoot,
+		TraceVerify,
+	}
+	if got := traceKinds(trace); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got steps %v, want %v", got, want)
+	}
+	// The leaf's search compares both served intermediates.
+	if step := trace.Steps[2]; step.Accepted || step.Name != TraceMismatch {
+		t.Errorf("got %+v, want a rejected name mismatch", step)
+	}
+	if step := trace.Steps[len(trace.Steps)-1]; !step.Accepted {
+		t.Errorf("verification failed: %s", step.Reason)
+	}
+
+	// Without the intermediates, and without AIA, there's nowhere to look.
+	trace = (&CertificateChain{Leaf: f.Leaf}).Explain(testPKIHostname, false)
+	want = []string{TraceSearch, TraceFailure, TraceVerify}
+	if got := traceKinds(trace); !reflect.DeepEqual(got, want) {
+		t.Fatalf("without intermediates: got steps %v, want %v", got, want)
+	}
+	if reason := trace.Steps[1].Reason; !strings.Contains(reason, "no AIA URL") {
+		t.Errorf("got failure %q", reason)
+	}
+}
+
+func TestExplainDepthLimit(t *testing.T) {
+	f := &TestPKIFixture{}
+	if err := buildTestPKI(f, maxTraceDepth+2, testCertificateOptions{}, testCertificateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	useTestRoots(t, f.Roots...)
+
+	trace := (&CertificateChain{Leaf: f.Leaf, Intermediates: f.Chain}).Explain("", false)
+	failure := trace.Steps[len(trace.Steps)-2]
+	if failure.Kind != TraceFailure || !strings.HasPrefix(failure.Reason, "depth limit reached") {
+		t.Fatalf("got %+v, want a depth limit failure", failure)
+	}
+	// Intermediates are numbered from the root down.
+	want := fmt.Sprintf("Test Intermediate %d", len(f.Chain)-maxTraceDepth+1)
+	if !strings.Contains(failure.Certificate, want) {
+		t.Errorf("gave up on %s, want %s", failure.Certificate, want)
+	}
+	issuers := 0
+	for _, step := range trace.Steps {
+		if step.Kind == TraceIssuer {
+			issuers++
+		}
+	}
+	if issuers != maxTraceDepth {
+		t.Errorf("got %d issuers, want %d", issuers, maxTraceDepth)
+	}
+}
+
+// cacheAIA stores cert in the AIA cache as the response for url.
+func cacheAIA(t *testing.T, url string, cert *Certificate) {
+	t.Helper()
+	entry := &aiaCacheEntry{
+		URL:         url,
+		Certificate: aiaCacheHash(cert.Certificate.Raw),
+		FetchedAt:   time.Now(),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	if err := writeAIACacheFile(aiaCacheCertificatePath(entry.Certificate), cert.Certificate.Raw); err != nil {
+		t.Fatal(err)
+	}
+	writeAIACacheEntry(entry)
+}
+
+func TestChainFromCertificateAndInternetWithTrace(t *testing.T) {
+	defer ConfigureAIACache(aiaCacheDir, aiaCacheBypass, aiaOffline)
+	ConfigureAIACache(t.TempDir(), false, true)
+
+	withAIA := func(url string) testCertificateOptions {
+		return testCertificateOptions{mutate: func(template *x509.Certificate) {
+			template.IssuingCertificateURL = []string{url}
+		}}
+	}
+	certificate := func(name string, isCA bool, issuer *Certificate, options testCertificateOptions) *Certificate {
+		cert, err := newTestCertificate(name, isCA, issuer, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	root := certificate("Test Root", true, nil, testCertificateOptions{})
+	intermediate := certificate("Test Intermediate", true, root, withAIA("http://aia.test/root.crt"))
+	expired := certificate("Test Expired Intermediate", true, root, testCertificateOptions{
+		mutate: func(template *x509.Certificate) {
+			template.NotBefore = time.Now().AddDate(-2, 0, 0)
+			template.NotAfter = time.Now().AddDate(0, 0, -1)
+			template.IssuingCertificateURL = []string{"http://aia.test/root.crt"}
+		},
+	})
+	untrusted := certificate("Test Untrusted Root", true, nil, testCertificateOptions{})
+	cacheAIA(t, "http://aia.test/root.crt", root)
+	cacheAIA(t, "http://aia.test/intermediate.crt", intermediate)
+	cacheAIA(t, "http://aia.test/expired.crt", expired)
+	cacheAIA(t, "http://aia.test/untrusted.crt", untrusted)
+	useTestRoots(t, root)
+	useTestIntermediateDatabase(t)
+
+	leaf := certificate(testPKIHostname, false, intermediate, withAIA("http://aia.test/intermediate.crt"))
+	trace := &Trace{}
+	chain, err := ChainFromCertificateAndInternetWithTrace(leaf, trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain.Intermediates) != 1 || chain.Intermediates[0].ID() != intermediate.ID() {
+		t.Errorf("got intermediates %v, want %s", chain.Intermediates, intermediate.ReadableSubject())
+	}
+	want := []string{
+		TraceSearch, TraceFetch, TraceCandidate, TraceIssuer,
+		TraceSearch, TraceFetch, TraceCandidate, TraceIssuer,
+		TraceRoot,
+	}
+	if got := traceKinds(trace); !reflect.DeepEqual(got, want) {
+		t.Errorf("got steps %v, want %v", got, want)
+	}
+	if trace.Steps[1].Candidate != intermediate.ReadableSubject() || !trace.Steps[1].Cached {
+		t.Errorf("got fetch %+v", trace.Steps[1])
+	}
+	if step := trace.Steps[2]; !step.Accepted || step.Origin != "AIA" ||
+		step.Name != TraceMatch || step.Signature != TraceValid {
+		t.Errorf("got candidate %+v", step)
+	}
+
+	// Fetched issuers are checked like any other candidate.
+	leaf = certificate(testPKIHostname, false, expired, withAIA("http://aia.test/expired.crt"))
+	trace = &Trace{}
+	if _, err := ChainFromCertificateAndInternetWithTrace(leaf, trace); err == nil ||
+		!strings.Contains(err.Error(), "isn't a valid issuer") {
+		t.Errorf("got error %v", err)
+	}
+	want = []string{TraceSearch, TraceFetch, TraceCandidate, TraceFailure}
+	if got := traceKinds(trace); !reflect.DeepEqual(got, want) {
+		t.Errorf("got steps %v, want %v", got, want)
+	}
+	if step := trace.Steps[2]; step.Accepted || !strings.HasPrefix(step.Reason, "it expired on") {
+		t.Errorf("got candidate %+v", step)
+	}
+
+	// Building stops at an untrusted self-signed issuer.
+	leaf = certificate(testPKIHostname, false, untrusted, withAIA("http://aia.test/untrusted.crt"))
+	trace = &Trace{}
+	if _, err := ChainFromCertificateAndInternetWithTrace(leaf, trace); err == nil ||
+		!strings.Contains(err.Error(), "isn't a trusted root") {
+		t.Errorf("got error %v", err)
+	}
+	want = []string{TraceSearch, TraceFetch, TraceCandidate, TraceIssuer, TraceFailure}
+	if got := traceKinds(trace); !reflect.DeepEqual(got, want) {
+		t.Errorf("got steps %v, want %v", got, want)
+	}
+
+	// Even when the database offers it as its own issuer.
+	subject := string(untrusted.Certificate.RawSubject)
+	intermediatesBySubject[subject] = append(intermediatesBySubject[subject], untrusted)
+	leaf = certificate(testPKIHostname, false, untrusted, testCertificateOptions{})
+	trace = &Trace{}
+	if _, err := ChainFromCertificateAndInternetWithTrace(leaf, trace); err == nil ||
+		!strings.Contains(err.Error(), "isn't a trusted root") {
+		t.Errorf("got error %v", err)
+	}
+	want = []string{TraceSearch, TraceCandidate, TraceIssuer, TraceFailure}
+	if got := traceKinds(trace); !reflect.DeepEqual(got, want) {
+		t.Errorf("got steps %v, want %v", got, want)
+	}
+
+	// Fetch failures end the chain, tracing into nil.
+	leaf = certificate(testPKIHostname, false, root, withAIA("http://aia.test/missing.crt"))
+	if _, err := ChainFromCertificateAndInternetWithTrace(leaf, nil); err == nil ||
+		!strings.Contains(err.Error(), "running offline") {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestChainFromCertificateAndInternetWithTraceDepthLimit(t *testing.T) {
+	useTestIntermediateDatabase(t)
+	f := &TestPKIFixture{}
+	if err := buildTestPKI(f, maxTraceDepth+2, testCertificateOptions{}, testCertificateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	useTestRoots(t, f.Roots...)
+	for _, cert := range f.Chain {
+		subject := string(cert.Certificate.RawSubject)
+		intermediatesBySubject[subject] = append(intermediatesBySubject[subject], cert)
+	}
+
+	trace := &Trace{}
+	if _, err := ChainFromCertificateAndInternetWithTrace(f.Leaf, trace); err == nil ||
+		!strings.Contains(err.Error(), fmt.Sprintf("no trusted root within %d certificates", maxTraceDepth)) {
+		t.Errorf("got error %v", err)
+	}
+	failure := trace.Steps[len(trace.Steps)-1]
+	if failure.Kind != TraceFailure || !strings.HasPrefix(failure.Reason, "depth limit reached") {
+		t.Errorf("got %+v, want a depth limit failure", failure)
+	}
+}
@@ -0,0 +1,265 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WarningPolicy adjusts the built-in lints: Thresholds override the limits
+// of lints that have one (days for expiring-soon and validity-too-long, bits
+// for key-too-short), Severities override the severity of lints by ID and
+// Suppressions hide warnings that were accepted.
+type WarningPolicy struct {
+	Thresholds   map[string]int       `mapstructure:"thresholds"`
+	Severities   map[string]string    `mapstructure:"severities"`
+	Suppressions []WarningSuppression `mapstructure:"suppressions"`
+
+	severities map[string]Severity
+}
+
+// WarningSuppression hides the warnings matching all of its non-empty
+// fields. ID is a lint ID; an empty ID matches every lint. Name and Source
+// are shell patterns, matched against the certificate's DNS names and
+// Common Name, and against its source. Expires is a YYYY-MM-DD date after
+// which the suppression stops applying.
+type WarningSuppression struct {
+	ID          string `mapstructure:"id"`
+	Fingerprint string `mapstructure:"fingerprint"`
+	Name        string `mapstructure:"name"`
+	Source      string `mapstructure:"source"`
+	Expires     string `mapstructure:"expires"`
+	Reason      string `mapstructure:"reason"`
+
+	expiresAt time.Time
+}
+
+var warningPolicy = &WarningPolicy{}
+
+// lintIDs lists the ID of every lint, so policies can't silently refer to
+// one that doesn't exist. The value tells whether the lint has a threshold.
+var lintIDs = map[string]bool{
+	// Any certificate
+	"expiring-soon":                true,
+	"expired":                      false,
+	"obsolete-signature-algorithm": false,
+	"key-too-short":                true,
+	"debian-weak-key":              false,
+	"roca-key":                     false,
+	"small-rsa-exponent":           false,
+	"compromised-key":              false,
+	"shared-rsa-factor":            false,
+
+	// Leaves
+	"validity-too-long":       true,
+	"missing-san":             false,
+	"cn-not-in-san":           false,
+	"missing-server-auth-eku": false,
+	"key-usage-mismatch":      false,
+	"reserved-ip-in-san":      false,
+	"ip-address-in-san":       false,
+	"internal-name-in-san":    false,
+	"bad-wildcard":            false,
+	"non-positive-serial":     false,
+	"serial-too-long":         false,
+	"leaf-is-ca":              false,
+	"weak-ecdsa-curve":        false,
+
+	// Intermediates
+	"ca-not-ca":              false,
+	"chain-out-of-order":     false,
+	"path-length-exceeded":   false,
+	"ca-missing-cert-sign":   false,
+	"key-id-mismatch":        false,
+	"eku-incompatible":       false,
+	"signature-key-mismatch": false,
+	"distrusted-issuer":      false,
+	"root-in-chain":          false,
+
+	// Certificate requests
+	"csr-invalid-signature": false,
+	"csr-key-mismatch":      false,
+	"csr-names-added":       false,
+	"csr-names-dropped":     false,
+	"csr-subject-changed":   false,
+}
+
+// LintIDs returns the IDs of every lint, sorted.
+func LintIDs() []string {
+	ids := []string{}
+	for id := range lintIDs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func checkLintID(id string) error {
+	if _, ok := lintIDs[id]; !ok {
+		return fmt.Errorf("unknown lint ID '%s', expected one of %s", id, strings.Join(LintIDs(), ", "))
+	}
+	return nil
+}
+
+// SetWarningPolicy validates the policy and applies it to all warnings
+// reported from then on.
+func SetWarningPolicy(policy *WarningPolicy) error {
+	policy.severities = map[string]Severity{}
+	for id, name := range policy.Severities {
+		if err := checkLintID(id); err != nil {
+			return fmt.Errorf("Invalid severity override: %s", err)
+		}
+		severity, err := ParseSeverity(name)
+		if err != nil {
+			return fmt.Errorf("Invalid severity for %s: %s", id, err)
+		}
+		policy.severities[id] = severity
+	}
+
+	for id, threshold := range policy.Thresholds {
+		if err := checkLintID(id); err != nil {
+			return fmt.Errorf("Invalid threshold: %s", err)
+		}
+		if !lintIDs[id] {
+			thresholdIDs := []string{}
+			for _, other := range LintIDs() {
+				if lintIDs[other] {
+					thresholdIDs = append(thresholdIDs, other)
+				}
+			}
+			return fmt.Errorf("Invalid threshold: %s has none, only %s do",
+				id, strings.Join(thresholdIDs, ", "))
+		}
+		if threshold <= 0 {
+			return fmt.Errorf("Invalid threshold for %s: %d isn't positive", id, threshold)
+		}
+	}
+
+	for index := range policy.Suppressions {
+		suppression := &policy.Suppressions[index]
+		if suppression.ID == "" && suppression.Fingerprint == "" &&
+			suppression.Name == "" && suppression.Source == "" {
+			return fmt.Errorf(
+				"Invalid suppression #%d: it needs an id, fingerprint, name or source, "+
+					"or it would hide every warning", index+1)
+		}
+		if suppression.ID != "" {
+			if err := checkLintID(suppression.ID); err != nil {
+				return fmt.Errorf("Invalid suppression #%d: %s", index+1, err)
+			}
+		}
+		for _, pattern := range []string{suppression.Name, suppression.Source} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("Invalid pattern '%s' in suppression #%d: %s", pattern, index+1, err)
+			}
+		}
+		if suppression.Expires != "" {
+			expiresAt, err := time.Parse("2006-01-02", suppression.Expires)
+			if err != nil {
+				return fmt.Errorf("Invalid expiry date '%s' in suppression #%d, expected YYYY-MM-DD",
+					suppression.Expires, index+1)
+			}
+			// The suppression applies during the whole expiry day.
+			suppression.expiresAt = expiresAt.AddDate(0, 0, 1)
+		}
+	}
+
+	warningPolicy = policy
+	return nil
+}
+
+func ParseSeverity(name string) (Severity, error) {
+	for severity, severityName := range severityNames {
+		if strings.EqualFold(name, severityName) {
+			return severity, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity '%s', expected info, warning or error", name)
+}
+
+// threshold returns the configured threshold for a lint, or defaultValue.
+func (p *WarningPolicy) threshold(id string, defaultValue int) int {
+	if value, ok := p.Thresholds[id]; ok {
+		return value
+	}
+	return defaultValue
+}
+
+type severityOverride struct {
+	Warning
+	severity Severity
+}
+
+func (w severityOverride) Severity() Severity {
+	return w.severity
+}
+
+// apply drops suppressed warnings and overrides severities. The certificate
+// is nil for warnings not about a certificate, such as request warnings,
+// which only unscoped suppressions match.
+func (p *WarningPolicy) apply(c *Certificate, warnings []Warning) []Warning {
+	rv := []Warning{}
+	for _, warning := range warnings {
+		if p.suppressed(c, warning) {
+			continue
+		}
+		if severity, ok := p.severities[warning.ID()]; ok {
+			warning = severityOverride{Warning: warning, severity: severity}
+		}
+		rv = append(rv, warning)
+	}
+	return rv
+}
+
+func (p *WarningPolicy) suppressed(c *Certificate, warning Warning) bool {
+	for _, suppression := range p.Suppressions {
+		if suppression.matches(c, warning) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *WarningSuppression) matches(c *Certificate, warning Warning) bool {
+	if s.ID != "" && s.ID != warning.ID() {
+		return false
+	}
+	if !s.expiresAt.IsZero() && !time.Now().Before(s.expiresAt) {
+		return false
+	}
+	if s.Fingerprint == "" && s.Name == "" && s.Source == "" {
+		return true
+	}
+	if c == nil {
+		return false
+	}
+
+	if s.Fingerprint != "" {
+		fingerprint := strings.ToLower(strings.Replace(s.Fingerprint, ":", "", -1))
+		if fingerprint != c.ID() {
+			return false
+		}
+	}
+	if s.Name != "" && !s.matchesName(c) {
+		return false
+	}
+	if s.Source != "" {
+		if matched, _ := path.Match(s.Source, c.Source); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *WarningSuppression) matchesName(c *Certificate) bool {
+	pattern := strings.ToLower(s.Name)
+	names := append([]string{c.Certificate.Subject.CommonName}, c.Certificate.DNSNames...)
+	for _, name := range names {
+		if matched, _ := path.Match(pattern, strings.ToLower(name)); matched && name != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetWarningPolicy(t *testing.T) {
+	defer func(policy *WarningPolicy) { warningPolicy = policy }(warningPolicy)
+
+	tests := []struct {
+		name   string
+		policy WarningPolicy
+		err    string
+	}{
+		{name: "empty policy", policy: WarningPolicy{}},
+		{
+			name: "valid policy",
+			policy: WarningPolicy{
+				Thresholds:   map[string]int{"expiring-soon": 30},
+				Severities:   map[string]string{"internal-name-in-san": "INFO"},
+				Suppressions: []WarningSuppression{{ID: "missing-san", Expires: "2030-01-31"}, {Source: "*.pem"}},
+			},
+		},
+		{
+			name:   "unknown severity lint",
+			policy: WarningPolicy{Severities: map[string]string{"nope": "info"}},
+			err:    "Invalid severity override: unknown lint ID 'nope'",
+		},
+		{
+			name:   "unknown severity",
+			policy: WarningPolicy{Severities: map[string]string{"missing-san": "fatal"}},
+			err:    "Invalid severity for missing-san: unknown severity 'fatal'",
+		},
+		{
+			name:   "lint without threshold",
+			policy: WarningPolicy{Thresholds: map[string]int{"missing-san": 3}},
+			err:    "Invalid threshold: missing-san has none",
+		},
+		{
+			name:   "negative threshold",
+			policy: WarningPolicy{Thresholds: map[string]int{"expiring-soon": -1}},
+			err:    "Invalid threshold for expiring-soon: -1 isn't positive",
+		},
+		{
+			name:   "unknown suppression lint",
+			policy: WarningPolicy{Suppressions: []WarningSuppression{{ID: "missing-san"}, {ID: "nope"}}},
+			err:    "Invalid suppression #2: unknown lint ID 'nope'",
+		},
+		{
+			name: "suppression without criteria",
+			policy: WarningPolicy{Suppressions: []WarningSuppression{
+				{ID: "missing-san"},
+				{Expires: "2030-01-31", Reason: "Everything is fine"},
+			}},
+			err: "Invalid suppression #2: it needs an id, fingerprint, name or source",
+		},
+		{
+			name:   "bad pattern",
+			policy: WarningPolicy{Suppressions: []WarningSuppression{{Name: "[www"}}},
+			err:    "Invalid pattern '[www' in suppression #1",
+		},
+		{
+			name:   "bad expiry",
+			policy: WarningPolicy{Suppressions: []WarningSuppression{{ID: "missing-san", Expires: "31/01/2030"}}},
+			err:    "Invalid expiry date '31/01/2030' in suppression #1",
+		},
+	}
+	for _, test := range tests {
+		policy := test.policy
+		err := SetWarningPolicy(&policy)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %s", test.name, err)
+		case test.err != "" && (err == nil || !strings.HasPrefix(err.Error(), test.err)):
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestWarningPolicyApply(t *testing.T) {
+	defer func(policy *WarningPolicy) { warningPolicy = policy }(warningPolicy)
+
+	cert := loadCertificateFixture(t, filepath.Join("testdata", "pkcs12", "leaf.crt"))
+	cert.Source = "certs/leaf.pem"
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	warnings := []Warning{MissingSANWarning{}, InternalNameWarning{}, LeafIsCAWarning{}}
+
+	tests := []struct {
+		name         string
+		suppressions []WarningSuppression
+		want         []string
+	}{
+		{"no suppressions", nil, []string{"missing-san", "internal-name-in-san", "leaf-is-ca"}},
+		{"by ID", []WarningSuppression{{ID: "missing-san"}}, []string{"internal-name-in-san", "leaf-is-ca"}},
+		{"by fingerprint", []WarningSuppression{{Fingerprint: strings.ToUpper(cert.ID())}}, []string{}},
+		{"other fingerprint", []WarningSuppression{{Fingerprint: "00"}}, []string{"missing-san", "internal-name-in-san", "leaf-is-ca"}},
+		{"by name", []WarningSuppression{{ID: "leaf-is-ca", Name: "*.EXAMPLE.test"}}, []string{"missing-san", "internal-name-in-san"}},
+		{"other name", []WarningSuppression{{ID: "leaf-is-ca", Name: "*.example.org"}}, []string{"missing-san", "internal-name-in-san", "leaf-is-ca"}},
+		{"by source", []WarningSuppression{{Source: "certs/*.pem"}}, []string{}},
+		{"expired", []WarningSuppression{{ID: "missing-san", Expires: yesterday}}, []string{"missing-san", "internal-name-in-san", "leaf-is-ca"}},
+	}
+	for _, test := range tests {
+		if err := SetWarningPolicy(&WarningPolicy{Suppressions: test.suppressions}); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		got := []string{}
+		for _, w := range warningPolicy.apply(cert, warnings) {
+			got = append(got, w.ID())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// Scoped suppressions don't match warnings that aren't about a
+	// certificate.
+	if err := SetWarningPolicy(&WarningPolicy{Suppressions: []WarningSuppression{{ID: "missing-san", Source: "*"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := warningPolicy.apply(nil, warnings[:1]); len(got) != 1 {
+		t.Errorf("scoped suppression matched a request warning")
+	}
+
+	if err := SetWarningPolicy(&WarningPolicy{Severities: map[string]string{"leaf-is-ca": "info"}}); err != nil {
+		t.Fatal(err)
+	}
+	got := warningPolicy.apply(cert, warnings)
+	if got[2].Severity() != SeverityInfo || got[0].Severity() != SeverityError {
+		t.Errorf("got severities %s and %s, want info and error", got[2].Severity(), got[0].Severity())
+	}
+}
@@ -0,0 +1,433 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+)
+
+// LoadCompromisedKeys adds the keys listed in a file to the compromised key
+// list. Each line holds a base64 SHA-256 SPKI hash, optionally followed by
+// a description; blank lines and lines starting with # are ignored.
+func LoadCompromisedKeys(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read compromised key list %s: %s", path, err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if hash, err := base64.StdEncoding.DecodeString(fields[0]); err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("Compromised key list %s, line %d: '%s' isn't a base64 SHA-256 hash",
+				path, lineNumber, fields[0])
+		}
+		description := path
+		if len(fields) > 1 {
+			description = strings.TrimSpace(fields[1])
+		}
+		compromisedKeys[fields[0]] = description
+	}
+	return scanner.Err()
+}
+
+// debianWeakKeyPaths are where Debian's openssl-blacklist package installs
+// its lists. They're several megabytes, so they aren't built in.
+const debianWeakKeyPaths = "/usr/share/openssl-blacklist/blacklist.RSA-*"
+
+var debianWeakKeysCache map[string]struct{}
+
+// debianWeakKeys returns the fingerprints of the RSA keys generated by
+// Debian's OpenSSL between 2006 and 2008, loading the system lists the first
+// time.
+func debianWeakKeys() map[string]struct{} {
+	if debianWeakKeysCache == nil {
+		debianWeakKeysCache = map[string]struct{}{}
+		paths, _ := filepath.Glob(debianWeakKeyPaths)
+		for _, path := range paths {
+			// Unreadable system lists shouldn't stop the other checks.
+			_ = loadDebianWeakKeys(path)
+		}
+	}
+	return debianWeakKeysCache
+}
+
+// LoadDebianWeakKeys adds a blocklist in openssl-blacklist format: one
+// fingerprint per line, being the last 20 hex digits of the SHA-1 hash of
+// "Modulus=<hex modulus>\n".
+func LoadDebianWeakKeys(path string) error {
+	debianWeakKeys()
+	return loadDebianWeakKeys(path)
+}
+
+func loadDebianWeakKeys(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read Debian weak key list %s: %s", path, err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := hex.DecodeString(line); err != nil || len(line) != 20 {
+			return fmt.Errorf("Debian weak key list %s, line %d: '%s' isn't a 20 digit hex fingerprint",
+				path, lineNumber, line)
+		}
+		debianWeakKeysCache[line] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+func debianWeakKeyFingerprint(n *big.Int) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("Modulus=%X\n", n)))
+	return hex.EncodeToString(sum[:])[20:]
+}
+
+// rocaPrimes are the small primes used to detect keys generated by the
+// Infineon library affected by ROCA (CVE-2017-15361). Those keys' moduli
+// are, modulo each of these primes, a power of 65537.
+var rocaPrimes = []int64{
+	3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67, 71,
+	73, 79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131, 137, 139, 149, 151,
+	157, 163, 167,
+}
+
+var rocaSubgroups = func() map[int64]map[int64]bool {
+	rv := map[int64]map[int64]bool{}
+	for _, prime := range rocaPrimes {
+		subgroup := map[int64]bool{}
+		for power := int64(1); !subgroup[power]; power = power * (65537 % prime) % prime {
+			subgroup[power] = true
+		}
+		rv[prime] = subgroup
+	}
+	return rv
+}()
+
+func hasROCAFingerprint(n *big.Int) bool {
+	remainder := new(big.Int)
+	for _, prime := range rocaPrimes {
+		remainder.Mod(n, big.NewInt(prime))
+		if !rocaSubgroups[prime][remainder.Int64()] {
+			return false
+		}
+	}
+	return true
+}
+
+type DebianWeakKeyWarning struct{}
+
+func (w DebianWeakKeyWarning) ID() string {
+	return "debian-weak-key"
+}
+
+func (w DebianWeakKeyWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w DebianWeakKeyWarning) Title() string {
+	return "Key generated by Debian's broken OpenSSL."
+}
+
+func (w DebianWeakKeyWarning) Description() string {
+	return formatDescription(`
+This key is on the Debian weak key blocklist: it was generated by the
+OpenSSL shipped in Debian and Ubuntu between 2006 and 2008, which could
+only produce a few thousand distinct keys (DSA-1571). Anyone can derive
+the private key. Generate a new key and replace the certificate.
+`)
+}
+
+func tryDebianWeakKeyWarning(publicKey crypto.PublicKey) Warning {
+	rsaPubKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil
+	}
+	if _, found := debianWeakKeys()[debianWeakKeyFingerprint(rsaPubKey.N)]; found {
+		return DebianWeakKeyWarning{}
+	}
+	return nil
+}
+
+func TryDebianWeakKeyWarning(c *Certificate) Warning {
+	if c.IsBundled() {
+		return nil
+	}
+	return tryDebianWeakKeyWarning(c.Certificate.PublicKey)
+}
+
+type ROCAWarning struct{}
+
+func (w ROCAWarning) ID() string {
+	return "roca-key"
+}
+
+func (w ROCAWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w ROCAWarning) Title() string {
+	return "Key vulnerable to ROCA."
+}
+
+func (w ROCAWarning) Description() string {
+	return formatDescription(`
+This key has the structure of keys generated by Infineon smart cards and
+TPMs affected by ROCA (CVE-2017-15361), whose private key can be
+recovered from the public key. Generate a new key, outside the affected
+hardware, and replace the certificate.
+`)
+}
+
+func tryROCAWarning(publicKey crypto.PublicKey) Warning {
+	rsaPubKey, ok := publicKey.(*rsa.PublicKey)
+	if ok && hasROCAFingerprint(rsaPubKey.N) {
+		return ROCAWarning{}
+	}
+	return nil
+}
+
+func TryROCAWarning(c *Certificate) Warning {
+	if c.IsBundled() {
+		return nil
+	}
+	return tryROCAWarning(c.Certificate.PublicKey)
+}
+
+type SmallExponentWarning struct {
+	exponent int
+}
+
+func (w SmallExponentWarning) ID() string {
+	return "small-rsa-exponent"
+}
+
+func (w SmallExponentWarning) Severity() Severity {
+	return SeverityWarning
+}
+
+func (w SmallExponentWarning) Title() string {
+	return "Small RSA public exponent."
+}
+
+func (w SmallExponentWarning) Description() string {
+	return formatDescription(`
+This key's public exponent is %d. Small exponents make implementation
+mistakes, such as bad padding, exploitable, and the CA/B Forum requires
+at least 65537, so public CAs won't sign this key.
+`, w.exponent)
+}
+
+func trySmallExponentWarning(publicKey crypto.PublicKey) Warning {
+	rsaPubKey, ok := publicKey.(*rsa.PublicKey)
+	if ok && rsaPubKey.E < 65537 {
+		return SmallExponentWarning{exponent: rsaPubKey.E}
+	}
+	return nil
+}
+
+func TrySmallExponentWarning(c *Certificate) Warning {
+	if c.IsBundled() {
+		return nil
+	}
+	return trySmallExponentWarning(c.Certificate.PublicKey)
+}
+
+type CompromisedKeyWarning struct {
+	origin string
+}
+
+func (w CompromisedKeyWarning) ID() string {
+	return "compromised-key"
+}
+
+func (w CompromisedKeyWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w CompromisedKeyWarning) Title() string {
+	return "Key known to be compromised."
+}
+
+func (w CompromisedKeyWarning) Description() string {
+	return formatDescription(`
+This key's private half is publicly known (%s), so anyone can
+impersonate this certificate. Generate a new key and replace the
+certificate.
+`, w.origin)
+}
+
+func tryCompromisedKeyWarning(rawSubjectPublicKeyInfo []byte) Warning {
+	sum := sha256.Sum256(rawSubjectPublicKeyInfo)
+	if origin, ok := compromisedKeys[base64.StdEncoding.EncodeToString(sum[:])]; ok {
+		return CompromisedKeyWarning{origin: origin}
+	}
+	return nil
+}
+
+func TryCompromisedKeyWarning(c *Certificate) Warning {
+	if c.IsBundled() {
+		return nil
+	}
+	return tryCompromisedKeyWarning(c.Certificate.RawSubjectPublicKeyInfo)
+}
+
+// sharedFactors maps SPKI hashes to the certificates whose RSA modulus
+// shares a prime factor with that key, as found by FindSharedFactors.
+var sharedFactors = map[string][]*Certificate{}
+
+// FindSharedFactors runs batch GCD over the RSA keys of the certificates,
+// finding keys that share a prime factor with another one, usually because
+// they were generated with too little entropy. Both keys can then be
+// factored. Affected certificates get a shared-rsa-factor warning; the
+// number of affected keys is returned.
+func FindSharedFactors(certs []*Certificate) int {
+	moduli := []*big.Int{}
+	certsByModulus := map[string][]*Certificate{}
+	for _, cert := range certs {
+		rsaPubKey, ok := cert.Certificate.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		key := rsaPubKey.N.Text(16)
+		if _, seen := certsByModulus[key]; !seen {
+			moduli = append(moduli, rsaPubKey.N)
+		}
+		certsByModulus[key] = append(certsByModulus[key], cert)
+	}
+
+	weak := batchGCD(moduli)
+
+	// Batch GCD only says which moduli share a factor with some other one,
+	// so pair the few weak ones up.
+	gcd := new(big.Int)
+	for i, a := range weak {
+		for _, b := range weak[i+1:] {
+			if gcd.GCD(nil, nil, a, b).Cmp(big.NewInt(1)) == 0 {
+				continue
+			}
+			addSharedFactors(certsByModulus[a.Text(16)], certsByModulus[b.Text(16)])
+			addSharedFactors(certsByModulus[b.Text(16)], certsByModulus[a.Text(16)])
+		}
+	}
+	return len(weak)
+}
+
+// addSharedFactors records others against each key of certs, once per key
+// even if several certificates hold it.
+func addSharedFactors(certs []*Certificate, others []*Certificate) {
+	seen := map[string]bool{}
+	for _, cert := range certs {
+		hash := cert.SPKIHash()
+		if !seen[hash] {
+			seen[hash] = true
+			sharedFactors[hash] = append(sharedFactors[hash], others...)
+		}
+	}
+}
+
+// batchGCD returns the moduli that share a factor with any other, using
+// Bernstein's product and remainder trees.
+func batchGCD(moduli []*big.Int) []*big.Int {
+	if len(moduli) < 2 {
+		return nil
+	}
+
+	levels := [][]*big.Int{moduli}
+	for len(levels[len(levels)-1]) > 1 {
+		previous := levels[len(levels)-1]
+		level := []*big.Int{}
+		for i := 0; i < len(previous); i += 2 {
+			if i+1 < len(previous) {
+				level = append(level, new(big.Int).Mul(previous[i], previous[i+1]))
+			} else {
+				level = append(level, previous[i])
+			}
+		}
+		levels = append(levels, level)
+	}
+
+	remainders := levels[len(levels)-1]
+	for depth := len(levels) - 2; depth >= 0; depth-- {
+		level := levels[depth]
+		next := make([]*big.Int, len(level))
+		for i, product := range level {
+			square := new(big.Int).Mul(product, product)
+			next[i] = new(big.Int).Mod(remainders[i/2], square)
+		}
+		remainders = next
+	}
+
+	rv := []*big.Int{}
+	one := big.NewInt(1)
+	for i, modulus := range moduli {
+		quotient := new(big.Int).Quo(remainders[i], modulus)
+		if new(big.Int).GCD(nil, nil, quotient, modulus).Cmp(one) != 0 {
+			rv = append(rv, modulus)
+		}
+	}
+	return rv
+}
+
+type SharedFactorWarning struct {
+	others []*Certificate
+}
+
+func (w SharedFactorWarning) ID() string {
+	return "shared-rsa-factor"
+}
+
+func (w SharedFactorWarning) Severity() Severity {
+	return SeverityError
+}
+
+func (w SharedFactorWarning) Title() string {
+	return "RSA key shares a factor with another key."
+}
+
+func (w SharedFactorWarning) Description() string {
+	others := []string{}
+	seen := map[string]bool{}
+	for _, other := range w.others {
+		if seen[other.ID()] {
+			continue
+		}
+		seen[other.ID()] = true
+		description := other.ReadableSubject()
+		if other.Source != "" {
+			description += " [" + other.Source + "]"
+		}
+		others = append(others, description)
+	}
+
+	return formatDescription(`
+This key's modulus shares a prime factor with the key of %s, so both
+private keys can be computed. The keys were probably generated with too
+little entropy. Generate new keys on a healthy system and replace the
+certificates.
+`, strings.Join(others, ", "))
+}
+
+func TrySharedFactorWarning(c *Certificate) Warning {
+	if others := sharedFactors[c.SPKIHash()]; len(others) > 0 {
+		return SharedFactorWarning{others: others}
+	}
+	return nil
+}
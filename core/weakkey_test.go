@@ -0,0 +1,221 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func rsaFixturePublicKey(t *testing.T) *rsa.PublicKey {
+	t.Helper()
+	return &loadKeyFixture(t, "rsa.pem").(*rsa.PrivateKey).PublicKey
+}
+
+func TestDebianWeakKeys(t *testing.T) {
+	defer func(cache map[string]struct{}) { debianWeakKeysCache = cache }(debianWeakKeysCache)
+	debianWeakKeysCache = map[string]struct{}{}
+
+	publicKey := rsaFixturePublicKey(t)
+	// openssl rsa -noout -modulus | sha1sum, as the blocklist is built.
+	if got, want := debianWeakKeyFingerprint(publicKey.N), "6a89c0d88df92b275766"; got != want {
+		t.Fatalf("got fingerprint %s, want %s", got, want)
+	}
+	if w := tryDebianWeakKeyWarning(publicKey); w != nil {
+		t.Errorf("empty blocklist: got %v", w)
+	}
+
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad")
+	if err := ioutil.WriteFile(bad, []byte("# comment\n6a89c0d88df92b2757\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDebianWeakKeys(bad); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got error %v, want one about line 2", err)
+	}
+
+	good := filepath.Join(dir, "good")
+	if err := ioutil.WriteFile(good, []byte("# comment\n\n6A89C0D88DF92B275766\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDebianWeakKeys(good); err != nil {
+		t.Fatal(err)
+	}
+	if w := tryDebianWeakKeyWarning(publicKey); w == nil || w.ID() != "debian-weak-key" {
+		t.Errorf("got %v, want a debian-weak-key warning", w)
+	}
+	if w := tryDebianWeakKeyWarning(loadKeyFixture(t, "ec.pem")); w != nil {
+		t.Errorf("EC key: got %v", w)
+	}
+}
+
+func TestROCAFingerprint(t *testing.T) {
+	// Any power of 65537 has the fingerprint, being in every subgroup by
+	// construction.
+	roca := new(big.Int).Exp(big.NewInt(65537), big.NewInt(40), nil)
+	tests := []struct {
+		name string
+		n    *big.Int
+		want bool
+	}{
+		{"power of 65537", roca, true},
+		{"power of 65537, plus one", new(big.Int).Add(roca, big.NewInt(1)), false},
+		{"fixture key", rsaFixturePublicKey(t).N, false},
+	}
+	for _, test := range tests {
+		if got := hasROCAFingerprint(test.n); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+	if w := tryROCAWarning(&rsa.PublicKey{N: roca, E: 65537}); w == nil || w.ID() != "roca-key" {
+		t.Errorf("got %v, want a roca-key warning", w)
+	}
+}
+
+func TestSmallExponentWarning(t *testing.T) {
+	n := rsaFixturePublicKey(t).N
+	tests := []struct {
+		exponent int
+		want     bool
+	}{
+		{3, true},
+		{65535, true},
+		{65537, false},
+		{1<<31 - 1, false},
+	}
+	for _, test := range tests {
+		w := trySmallExponentWarning(&rsa.PublicKey{N: n, E: test.exponent})
+		if got := w != nil; got != test.want {
+			t.Errorf("exponent %d: got %v, want %v", test.exponent, w, test.want)
+		}
+	}
+	if w := trySmallExponentWarning(loadKeyFixture(t, "ed25519.pem")); w != nil {
+		t.Errorf("Ed25519 key: got %v", w)
+	}
+}
+
+func TestCompromisedKeys(t *testing.T) {
+	defer func(keys map[string]string) { compromisedKeys = keys }(compromisedKeys)
+	compromisedKeys = map[string]string{}
+
+	spki, err := x509.MarshalPKIXPublicKey(rsaFixturePublicKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := tryCompromisedKeyWarning(spki); w != nil {
+		t.Errorf("empty list: got %v", w)
+	}
+
+	tests := []struct {
+		name   string
+		list   string
+		err    string
+		origin string
+	}{
+		{name: "not base64", list: "not-a-hash\n", err: "line 1: 'not-a-hash' isn't a base64 SHA-256 hash"},
+		{name: "short hash", list: "# comment\nAAAA\n", err: "line 2: 'AAAA' isn't a base64 SHA-256 hash"},
+		{name: "no description", list: "sjfyDoNX3kj0pYEKzdkp8aEPRkM58i1zw+xwwSaBOaQ=\n", origin: "keys"},
+		{name: "description", list: "sjfyDoNX3kj0pYEKzdkp8aEPRkM58i1zw+xwwSaBOaQ=  Test key \n", origin: "Test key"},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "keys")
+		if err := ioutil.WriteFile(path, []byte(test.list), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := LoadCompromisedKeys(path)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		w, ok := tryCompromisedKeyWarning(spki).(CompromisedKeyWarning)
+		if !ok || !strings.HasSuffix(w.origin, test.origin) {
+			t.Errorf("%s: got %v, want origin %q", test.name, w, test.origin)
+		}
+	}
+}
+
+func TestBatchGCD(t *testing.T) {
+	moduli := []*big.Int{
+		big.NewInt(101 * 103),
+		big.NewInt(107 * 109),
+		big.NewInt(113 * 127),
+		big.NewInt(101 * 131),
+		big.NewInt(137 * 139),
+	}
+	want := []*big.Int{moduli[0], moduli[3]}
+	if got := batchGCD(moduli); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := batchGCD(moduli[:1]); len(got) != 0 {
+		t.Errorf("single modulus: got %v", got)
+	}
+}
+
+func TestFindSharedFactors(t *testing.T) {
+	defer func(factors map[string][]*Certificate) { sharedFactors = factors }(sharedFactors)
+	sharedFactors = map[string][]*Certificate{}
+
+	prime := func() *big.Int {
+		p, err := rand.Prime(rand.Reader, 256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	rsaCertificate := func(cn string, p, q *big.Int) *Certificate {
+		publicKey := &rsa.PublicKey{N: new(big.Int).Mul(p, q), E: 65537}
+		spki, err := x509.MarshalPKIXPublicKey(publicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert := &x509.Certificate{PublicKey: publicKey, RawSubjectPublicKeyInfo: spki}
+		cert.Subject.CommonName = cn
+		return &Certificate{Certificate: cert}
+	}
+
+	shared := prime()
+	a := rsaCertificate("a.example.test", shared, prime())
+	b := rsaCertificate("b.example.test", shared, prime())
+	// The same key as a, in another certificate.
+	aCopy := &Certificate{Certificate: a.Certificate, Source: "copy.pem"}
+	healthy := rsaCertificate("c.example.test", prime(), prime())
+	ec, err := newTestCertificate("d.example.test", false, nil, testCertificateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := FindSharedFactors([]*Certificate{a, healthy, b, aCopy, ec}); got != 2 {
+		t.Errorf("got %d weak keys, want 2", got)
+	}
+	tests := []struct {
+		cert   *Certificate
+		others []*Certificate
+	}{
+		{a, []*Certificate{b}},
+		{b, []*Certificate{a, aCopy}},
+		{healthy, nil},
+		{ec, nil},
+	}
+	for _, test := range tests {
+		w := TrySharedFactorWarning(test.cert)
+		if test.others == nil {
+			if w != nil {
+				t.Errorf("%s: got %v", test.cert.ReadableSubject(), w)
+			}
+			continue
+		}
+		if w, ok := w.(SharedFactorWarning); !ok || !reflect.DeepEqual(w.others, test.others) {
+			t.Errorf("%s: got %v, want others %v", test.cert.ReadableSubject(), w, test.others)
+		}
+	}
+}